@@ -0,0 +1,177 @@
+package cmds
+
+import (
+	"context"
+	"github.com/go-go-golems/glazed/pkg/cli"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/go-go-golems/sqleton/pkg/cache"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"time"
+)
+
+type CacheListCommand struct {
+	*cmds.CommandDescription
+}
+
+var _ cmds.GlazeCommand = (*CacheListCommand)(nil)
+
+type CacheClearCommand struct {
+	*cmds.CommandDescription
+}
+
+var _ cmds.GlazeCommand = (*CacheClearCommand)(nil)
+
+type CacheSettings struct {
+	CacheDir string `glazed.parameter:"cache-dir"`
+	Expired  bool   `glazed.parameter:"expired"`
+}
+
+func newCacheDirFlag() *parameters.ParameterDefinition {
+	return parameters.NewParameterDefinition(
+		"cache-dir",
+		parameters.ParameterTypeString,
+		parameters.WithHelp("Directory containing the cache database (default: ~/.sqleton/cache)"),
+	)
+}
+
+func NewCacheListCommand() (*CacheListCommand, error) {
+	glazedParameterLayer, err := settings.NewGlazedParameterLayers()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create Glazed parameter layer")
+	}
+
+	return &CacheListCommand{
+		CommandDescription: cmds.NewCommandDescription(
+			"ls",
+			cmds.WithShort("List the cached query results"),
+			cmds.WithFlags(newCacheDirFlag()),
+			cmds.WithLayersList(glazedParameterLayer),
+		),
+	}, nil
+}
+
+func (c *CacheListCommand) RunIntoGlazeProcessor(
+	ctx context.Context,
+	parsedLayers *layers.ParsedLayers,
+	gp middlewares.Processor,
+) error {
+	s := &CacheSettings{}
+	err := parsedLayers.InitializeStruct(layers.DefaultSlug, s)
+	if err != nil {
+		return err
+	}
+
+	store, err := cache.Open(s.CacheDir)
+	if err != nil {
+		return err
+	}
+	defer func(store *cache.Cache) {
+		_ = store.Close()
+	}(store)
+
+	entries, err := store.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, entry := range entries {
+		err = gp.AddRow(ctx, entry.ToRow(now))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func NewCacheClearCommand() (*CacheClearCommand, error) {
+	glazedParameterLayer, err := settings.NewGlazedParameterLayers()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create Glazed parameter layer")
+	}
+
+	return &CacheClearCommand{
+		CommandDescription: cmds.NewCommandDescription(
+			"clear",
+			cmds.WithShort("Delete the cached query results"),
+			cmds.WithFlags(
+				newCacheDirFlag(),
+				parameters.NewParameterDefinition(
+					"expired",
+					parameters.ParameterTypeBool,
+					parameters.WithHelp("Only delete the expired entries"),
+					parameters.WithDefault(false),
+				),
+			),
+			cmds.WithLayersList(glazedParameterLayer),
+		),
+	}, nil
+}
+
+func (c *CacheClearCommand) RunIntoGlazeProcessor(
+	ctx context.Context,
+	parsedLayers *layers.ParsedLayers,
+	gp middlewares.Processor,
+) error {
+	s := &CacheSettings{}
+	err := parsedLayers.InitializeStruct(layers.DefaultSlug, s)
+	if err != nil {
+		return err
+	}
+
+	store, err := cache.Open(s.CacheDir)
+	if err != nil {
+		return err
+	}
+	defer func(store *cache.Cache) {
+		_ = store.Close()
+	}(store)
+
+	deleted, err := store.Clear(ctx, s.Expired)
+	if err != nil {
+		return err
+	}
+
+	return gp.AddRow(ctx, types.NewRow(
+		types.MRP("cache_dir", store.Dir()),
+		types.MRP("deleted", deleted),
+	))
+}
+
+// NewCacheGroupCommand creates the `cache` command group, to inspect and clear
+// the results cached by the commands declaring a `cache:` block (or run with --cache).
+func NewCacheGroupCommand() (*cobra.Command, error) {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Inspect and clear the query result cache",
+	}
+
+	listCommand, err := NewCacheListCommand()
+	if err != nil {
+		return nil, err
+	}
+	cobraListCommand, err := cli.BuildCobraCommandFromGlazeCommand(listCommand)
+	if err != nil {
+		return nil, err
+	}
+	cmd.AddCommand(cobraListCommand)
+
+	clearCommand, err := NewCacheClearCommand()
+	if err != nil {
+		return nil, err
+	}
+	cobraClearCommand, err := cli.BuildCobraCommandFromGlazeCommand(clearCommand)
+	if err != nil {
+		return nil, err
+	}
+	cmd.AddCommand(cobraClearCommand)
+
+	return cmd, nil
+}
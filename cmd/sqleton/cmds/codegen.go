@@ -1,31 +1,98 @@
 package cmds
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"github.com/dave/jennifer/jen"
+	sql2 "github.com/go-go-golems/clay/pkg/sql"
 	"github.com/go-go-golems/glazed/pkg/cmds"
 	"github.com/go-go-golems/glazed/pkg/cmds/alias"
 	"github.com/go-go-golems/glazed/pkg/cmds/loaders"
 	cmds2 "github.com/go-go-golems/sqleton/pkg/cmds"
 	"github.com/go-go-golems/sqleton/pkg/codegen"
+	"github.com/go-go-golems/sqleton/pkg/connection"
+	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"io/fs"
 	"os"
 	"path"
 	"strings"
 )
 
+// registerFileName is the name of the file holding the Register function generated with --dir.
+const registerFileName = "register.go"
+
+// handlersFileName is the name of the file holding the RegisterHandlers function generated with --http.
+const handlersFileName = "handlers.go"
+
 func NewCodegenCommand() *cobra.Command {
 	ret := &cobra.Command{
 		Use:   "codegen [file...]",
 		Short: "A program to convert Sqleton YAML commands into Go code",
-		Args:  cobra.MinimumNArgs(1),
+		Args:  cobra.ArbitraryArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			packageName := cmd.Flag("package-name").Value.String()
 			outputDir := cmd.Flag("output-dir").Value.String()
+			dir := cmd.Flag("dir").Value.String()
+			inferFromDB, err := cmd.Flags().GetBool("infer-from-db")
+			if err != nil {
+				return err
+			}
+			generateHTTP, err := cmd.Flags().GetBool("http")
+			if err != nil {
+				return err
+			}
+			lang := cmd.Flag("lang").Value.String()
+			if lang != "go" && lang != "typescript" {
+				return errors.Errorf("unsupported language %s, expected go or typescript", lang)
+			}
+			if lang == "typescript" && generateHTTP {
+				return errors.New("--http generates Go code and can't be used with --lang typescript")
+			}
+
+			if dir == "" && len(args) == 0 {
+				return errors.New("expected command files or a --dir to convert")
+			}
+			if dir != "" && len(args) > 0 {
+				return errors.New("command files can't be converted along with a --dir")
+			}
+
+			var db *sqlx.DB
+			if inferFromDB {
+				config := createConfigFromCobra(cmd)
+				db, err = connection.Connect(config)
+				if err != nil {
+					return err
+				}
+				defer func(db *sqlx.DB) {
+					_ = db.Close()
+				}(db)
+			}
 
 			s := &codegen.SqlCommandCodeGenerator{
 				PackageName: packageName,
+				HTTP:        generateHTTP,
+			}
+
+			// the commands to convert, along with the files they are loaded from and converted to
+			var commands []*cmds2.SqlCommand
+			var sourceFileNames []string
+			var fileNames []string
+			if dir != "" {
+				commands, sourceFileNames, err = loadSelectCommandsFromDir(dir)
+				if err != nil {
+					return err
+				}
+				for i, fileName := range sourceFileNames {
+					// posts/ls.yaml is converted to posts-ls.go, the package being flat
+					fileNames = append(fileNames, strings.ReplaceAll(trimExtension(fileName), "/", "-")+".go")
+					sourceFileNames[i] = path.Join(dir, fileName)
+				}
 			}
+
 			for _, fileName := range args {
 				loader := &cmds2.SqlCommandLoader{
 					DBConnectionFactory: nil,
@@ -42,21 +109,106 @@ func NewCodegenCommand() *cobra.Command {
 				if len(cmds_) != 1 {
 					return errors.Errorf("expected exactly one command, got %d", len(cmds_))
 				}
-				cmd := cmds_[0].(*cmds2.SqlCommand)
+				cmd, ok := cmds_[0].(*cmds2.SqlCommand)
+				if !ok {
+					return errors.Errorf("%s: code generation is only supported for select commands, got %T", fileName, cmds_[0])
+				}
 
-				f, err := s.GenerateCommandCode(cmd)
+				commands = append(commands, cmd)
+				sourceFileNames = append(sourceFileNames, fileName)
+				fileNames = append(fileNames, trimExtension(path.Base(fileName))+".go")
+			}
+
+			if db != nil {
+				for i, cmd := range commands {
+					cmd.Columns, err = codegen.InferColumns(context.Background(), db, cmd)
+					if err != nil {
+						return errors.Wrapf(err, "could not infer the columns of %s", sourceFileNames[i])
+					}
+				}
+			}
+
+			err = codegen.CheckCommandNames(commands)
+			if err != nil {
+				return err
+			}
+
+			// the source of each generated file, to catch two of them being written to the same file
+			sources := map[string]string{}
+			save := func(source string, fileName string, content []byte) error {
+				if other, ok := sources[fileName]; ok {
+					return errors.Errorf("%s and %s would both be converted to %s", other, source, fileName)
+				}
+				sources[fileName] = source
+
+				p := path.Join(outputDir, fileName)
+				fmt.Printf("Converting %s to %s\n", source, p)
+				err := os.WriteFile(p, content, 0644)
+				if err != nil {
+					return errors.Wrapf(err, "could not write %s", p)
+				}
+				return nil
+			}
+			saveCode := func(source string, fileName string, f *jen.File) error {
+				buf := &bytes.Buffer{}
+				err := f.Render(buf)
+				if err != nil {
+					return errors.Wrapf(err, "could not convert %s", source)
+				}
+				return save(source, fileName, buf.Bytes())
+			}
+
+			if lang == "typescript" {
+				client, err := s.GenerateTypeScriptClient(commands)
 				if err != nil {
 					return err
 				}
+				source := dir
+				if source == "" {
+					source = strings.Join(sourceFileNames, ", ")
+				}
+				return save(source, codegen.TypeScriptClientFileName, client)
+			}
 
-				s := f.GoString()
-				// store in path.go after removing .yaml
-				p, _ := strings.CutSuffix(path.Base(fileName), ".yaml")
-				p = p + ".go"
-				p = path.Join(outputDir, p)
+			// the files generated for all the commands come first, so that a command file with the same name is reported
+			if dir != "" {
+				register, err := s.GenerateRegisterCode(commands)
+				if err != nil {
+					return err
+				}
+				err = saveCode(dir, registerFileName, register)
+				if err != nil {
+					return err
+				}
+			}
+			if generateHTTP {
+				handlers, err := s.GenerateHandlersCode(commands)
+				if err != nil {
+					return err
+				}
+				err = saveCode("handlers", handlersFileName, handlers)
+				if err != nil {
+					return err
+				}
+				document, err := s.GenerateOpenAPIDocument(commands)
+				if err != nil {
+					return err
+				}
+				err = save("OpenAPI document", codegen.OpenAPIFileName, document)
+				if err != nil {
+					return err
+				}
+			}
 
-				fmt.Printf("Converting %s to %s\n", fileName, p)
-				_ = os.WriteFile(p, []byte(s), 0644)
+			for i, cmd := range commands {
+				f, err := s.GenerateCommandCode(cmd)
+				if err != nil {
+					return errors.Wrapf(err, "could not convert %s", sourceFileNames[i])
+				}
+				err = saveCode(sourceFileNames[i], fileNames[i], f)
+				if err != nil {
+					return err
+				}
 			}
 
 			return nil
@@ -65,5 +217,99 @@ func NewCodegenCommand() *cobra.Command {
 
 	ret.PersistentFlags().StringP("output-dir", "o", ".", "Output directory for generated code")
 	ret.PersistentFlags().StringP("package-name", "p", "main", "Package name for generated code")
+	ret.Flags().String("dir", "",
+		"Convert all the commands of a repository directory, along with a Register function adding them to a cobra command")
+	ret.Flags().Bool("http", false,
+		"Generate net/http handlers for the commands, along with a RegisterHandlers function and an OpenAPI document")
+	ret.Flags().String("lang", "go",
+		"Language of the generated code: go, or typescript for a client of the commands served by sqleton serve")
+	ret.Flags().Bool("infer-from-db", false,
+		"Infer the result columns of the commands from the database, running their query with the default parameters")
+	// --package is accepted as a shorthand for --package-name
+	ret.Flags().SetNormalizeFunc(func(f *pflag.FlagSet, name string) pflag.NormalizedName {
+		if name == "package" {
+			name = "package-name"
+		}
+		return pflag.NormalizedName(name)
+	})
+
+	connectionLayer, err := sql2.NewSqlConnectionParameterLayer()
+	cobra.CheckErr(err)
+	dbtParameterLayer, err := sql2.NewDbtParameterLayer()
+	cobra.CheckErr(err)
+	err = connectionLayer.AddLayerToCobraCommand(ret)
+	cobra.CheckErr(err)
+	err = dbtParameterLayer.AddLayerToCobraCommand(ret)
+	cobra.CheckErr(err)
+
 	return ret
 }
+
+func trimExtension(fileName string) string {
+	return strings.TrimSuffix(fileName, path.Ext(fileName))
+}
+
+// loadSelectCommandsFromDir loads the select commands of the repository in dir, along with the files they
+// were loaded from. The directories of the commands become their parents.
+// Aliases, exec commands and paginated commands can't be converted and are skipped.
+func loadSelectCommandsFromDir(dir string) ([]*cmds2.SqlCommand, []string, error) {
+	loader := &cmds2.SqlCommandLoader{}
+	f := os.DirFS(dir)
+
+	var commands []*cmds2.SqlCommand
+	var fileNames []string
+	err := fs.WalkDir(f, ".", func(fileName string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if fileName != "." && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !loader.IsFileSupported(f, fileName) {
+			return nil
+		}
+
+		var parents []string
+		if p := path.Dir(fileName); p != "." {
+			parents = strings.Split(p, "/")
+		}
+		commands_, err := loader.LoadCommands(f, fileName,
+			[]cmds.CommandDescriptionOption{
+				cmds.WithSource(path.Join(dir, fileName)),
+				cmds.WithParents(parents...),
+			},
+			[]alias.Option{
+				alias.WithSource(path.Join(dir, fileName)),
+				alias.WithParents(parents...),
+			},
+		)
+		if err != nil {
+			return errors.Wrapf(err, "could not load %s", path.Join(dir, fileName))
+		}
+
+		for _, c := range commands_ {
+			cmd, ok := c.(*cmds2.SqlCommand)
+			if !ok {
+				fmt.Printf("Skipping %s: code generation is only supported for select commands, got %T\n",
+					path.Join(dir, fileName), c)
+				continue
+			}
+			if cmd.Pagination != nil {
+				fmt.Printf("Skipping %s: code generation is not supported for paginated commands\n",
+					path.Join(dir, fileName))
+				continue
+			}
+			commands = append(commands, cmd)
+			fileNames = append(fileNames, fileName)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return commands, fileNames, nil
+}
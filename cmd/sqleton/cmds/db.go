@@ -9,20 +9,16 @@ import (
 	"github.com/go-go-golems/glazed/pkg/cmds/layers"
 	"github.com/go-go-golems/glazed/pkg/middlewares/row"
 	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/go-go-golems/sqleton/pkg/connection"
+	"github.com/go-go-golems/sqleton/pkg/schema"
 	"github.com/jmoiron/sqlx"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"io"
 	"os"
-
-	_ "github.com/go-sql-driver/mysql" // MySQL driver for database/sql
+	"strings"
 )
 
-// From chatGPT:
-// To run SQL commands against a PostgreSQL or SQLite database, you can use a similar
-// approach, but you will need to use the appropriate driver for the database.
-// For example, to use PostgreSQL, you can use the github.com/lib/pq driver, and to use SQLite,
-// you can use the github.com/mattn/go-sqlite3
-
 var DbCmd = &cobra.Command{
 	Use:   "db",
 	Short: "Manage databases",
@@ -51,7 +47,7 @@ func createConfigFromCobra(cmd *cobra.Command) *sql2.DatabaseConfig {
 
 	sqlParsedLayer := parsedLayers.GetOrCreate(connectionLayer)
 	dbtParsedLayer := parsedLayers.GetOrCreate(dbtLayer)
-	config, err := sql2.NewConfigFromParsedLayers(dbtParsedLayer, sqlParsedLayer)
+	config, err := connection.NewConfigFromParsedLayers(sqlParsedLayer, dbtParsedLayer)
 	cobra.CheckErr(err)
 
 	return config
@@ -63,8 +59,8 @@ var dbTestConnectionCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		config := createConfigFromCobra(cmd)
 
-		fmt.Printf("Testing connection to %s\n", config.ToString())
-		db, err := config.Connect()
+		fmt.Printf("Testing connection to %s\n", connection.ToString(config))
+		db, err := connection.Connect(config)
 		cobra.CheckErr(err)
 
 		cobra.CheckErr(err)
@@ -87,8 +83,8 @@ var dbTestConnectionCmdWithPrefix = &cobra.Command{
 	Short: "Test the connection to a database, but all sqleton flags have the test- prefix",
 	Run: func(cmd *cobra.Command, args []string) {
 		config := createConfigFromCobra(cmd)
-		fmt.Printf("Testing connection to %s\n", config.ToString())
-		db, err := config.Connect()
+		fmt.Printf("Testing connection to %s\n", connection.ToString(config))
+		db, err := connection.Connect(config)
 		cobra.CheckErr(err)
 
 		cobra.CheckErr(err)
@@ -111,7 +107,7 @@ var dbPrintEvidenceSettingsCmd = &cobra.Command{
 	Short: "Output the settings to connect to a database for evidence.dev",
 	Run: func(cmd *cobra.Command, args []string) {
 		config := createConfigFromCobra(cmd)
-		source, err := config.GetSource()
+		source, err := connection.GetSource(config)
 		cobra.CheckErr(err)
 
 		gitRepo, _ := cmd.Flags().GetString("git-repo")
@@ -156,7 +152,7 @@ var dbPrintEnvCmd = &cobra.Command{
 	Short: "Output the settings to connect to a database as environment variables",
 	Run: func(cmd *cobra.Command, args []string) {
 		config := createConfigFromCobra(cmd)
-		source, err := config.GetSource()
+		source, err := connection.GetSource(config)
 		cobra.CheckErr(err)
 
 		isEnvRc, _ := cmd.Flags().GetBool("envrc")
@@ -174,6 +170,8 @@ var dbPrintEnvCmd = &cobra.Command{
 		fmt.Printf("%s%s=%s\n", prefix, "USER", source.Username)
 		fmt.Printf("%s%s=%s\n", prefix, "PASSWORD", source.Password)
 		fmt.Printf("%s%s=%s\n", prefix, "SCHEMA", source.Schema)
+		fmt.Printf("%s%s=%s\n", prefix, "DSN", connection.MaskDSN(config.Driver, config.DSN))
+		fmt.Printf("%s%s=%s\n", prefix, "DRIVER", config.Driver)
 		if config.UseDbtProfiles {
 			fmt.Printf("%s%s=1\n", prefix, "USE_DBT_PROFILES")
 		} else {
@@ -189,7 +187,7 @@ var dbPrintSettingsCmd = &cobra.Command{
 	Short: "Output the settings to connect to a database using glazed",
 	Run: func(cmd *cobra.Command, args []string) {
 		config := createConfigFromCobra(cmd)
-		source, err := config.GetSource()
+		source, err := connection.GetSource(config)
 		cobra.CheckErr(err)
 
 		gp, _, err := cli.CreateGlazedProcessorFromCobra(cmd)
@@ -211,6 +209,8 @@ var dbPrintSettingsCmd = &cobra.Command{
 		password := "password"
 		type_ := "type"
 		schema := "schema"
+		dsn := "dsn"
+		driver := "driver"
 		dbtProfile := "dbtProfile"
 		useDbtProfiles := "useDbtProfiles"
 		dbtProfilesPath := "dbtProfilesPath"
@@ -223,6 +223,8 @@ var dbPrintSettingsCmd = &cobra.Command{
 			password = "SQLETON_PASSWORD"
 			type_ = "SQLETON_TYPE"
 			schema = "SQLETON_SCHEMA"
+			dsn = "SQLETON_DSN"
+			driver = "SQLETON_DRIVER"
 			dbtProfile = "SQLETON_DBT_PROFILE"
 			useDbtProfiles = "SQLETON_USE_DBT_PROFILES"
 			dbtProfilesPath = "SQLETON_DBT_PROFILES_PATH"
@@ -234,11 +236,16 @@ var dbPrintSettingsCmd = &cobra.Command{
 			password = fmt.Sprintf("%sPASSWORD", withEnvPrefix)
 			type_ = fmt.Sprintf("%sTYPE", withEnvPrefix)
 			schema = fmt.Sprintf("%sSCHEMA", withEnvPrefix)
+			dsn = fmt.Sprintf("%sDSN", withEnvPrefix)
+			driver = fmt.Sprintf("%sDRIVER", withEnvPrefix)
 			dbtProfile = fmt.Sprintf("%sDBT_PROFILE", withEnvPrefix)
 			useDbtProfiles = fmt.Sprintf("%sUSE_DBT_PROFILES", withEnvPrefix)
 			dbtProfilesPath = fmt.Sprintf("%sDBT_PROFILES_PATH", withEnvPrefix)
 		}
 
+		// never output the password contained in the DSN
+		maskedDSN := connection.MaskDSN(config.Driver, config.DSN)
+
 		addRow := func(name string, value interface{}) {
 			_ = gp.AddRow(ctx, types.NewRow(
 				types.MRP("name", name),
@@ -253,6 +260,8 @@ var dbPrintSettingsCmd = &cobra.Command{
 			addRow(password, source.Password)
 			addRow(type_, source.Type)
 			addRow(schema, source.Schema)
+			addRow(dsn, maskedDSN)
+			addRow(driver, config.Driver)
 			addRow(dbtProfile, config.DbtProfile)
 			addRow(useDbtProfiles, config.UseDbtProfiles)
 			addRow(dbtProfilesPath, config.DbtProfilesPath)
@@ -265,6 +274,8 @@ var dbPrintSettingsCmd = &cobra.Command{
 				types.MRP(password, source.Password),
 				types.MRP(type_, source.Type),
 				types.MRP(schema, source.Schema),
+				types.MRP(dsn, maskedDSN),
+				types.MRP(driver, config.Driver),
 				types.MRP(dbtProfile, config.DbtProfile),
 				types.MRP(useDbtProfiles, config.UseDbtProfiles),
 				types.MRP(dbtProfilesPath, config.DbtProfilesPath),
@@ -309,7 +320,13 @@ var dbLsCmd = &cobra.Command{
 		gp.AddRowMiddleware(row.NewReorderColumnOrderMiddleware([]string{"name", "type", "hostname", "port", "database", "schema"}))
 
 		for _, source := range sources {
+			source.Type = connection.NormalizeType(source.Type)
 			row := types.NewRowFromStruct(source, true)
+			// sqlite sources are only described by the path to the database file
+			if source.Type == connection.TypeSqlite {
+				row.Set("hostname", "")
+				row.Set("port", "")
+			}
 			err := gp.AddRow(ctx, row)
 			cobra.CheckErr(err)
 		}
@@ -323,6 +340,45 @@ var dbLsCmd = &cobra.Command{
 	},
 }
 
+var dbDiagramCmd = &cobra.Command{
+	Use:   "diagram",
+	Short: "Output an ER diagram (mermaid, dot or plantuml) of the database schema",
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		config := createConfigFromCobra(cmd)
+
+		format, _ := cmd.Flags().GetString("format")
+		tables, _ := cmd.Flags().GetStringSlice("tables")
+		exclude, _ := cmd.Flags().GetStringSlice("exclude")
+		outputFile, _ := cmd.Flags().GetString("output-file")
+
+		db, err := connection.Connect(config)
+		cobra.CheckErr(err)
+		defer func(db *sqlx.DB) {
+			_ = db.Close()
+		}(db)
+
+		introspector, err := schema.NewIntrospector(db, config.Schema)
+		cobra.CheckErr(err)
+
+		diagram, err := schema.LoadDiagram(ctx, introspector, tables, exclude)
+		cobra.CheckErr(err)
+
+		var w io.Writer = os.Stdout
+		if outputFile != "" {
+			f, err := os.Create(outputFile)
+			cobra.CheckErr(err)
+			defer func(f *os.File) {
+				_ = f.Close()
+			}(f)
+			w = f
+		}
+
+		err = diagram.Render(w, format)
+		cobra.CheckErr(err)
+	},
+}
+
 func init() {
 	err := cli.AddGlazedProcessorFlagsToCobraCommand(dbLsCmd)
 	cobra.CheckErr(err)
@@ -363,6 +419,17 @@ func init() {
 	cobra.CheckErr(err)
 	DbCmd.AddCommand(dbPrintSettingsCmd)
 
+	err = connectionLayer.AddLayerToCobraCommand(dbDiagramCmd)
+	cobra.CheckErr(err)
+	err = dbtParameterLayer.AddLayerToCobraCommand(dbDiagramCmd)
+	cobra.CheckErr(err)
+	dbDiagramCmd.Flags().String("format", schema.DiagramFormatMermaid,
+		fmt.Sprintf("Diagram format (%s)", strings.Join(schema.DiagramFormats, ", ")))
+	dbDiagramCmd.Flags().StringSlice("tables", []string{}, "Only include these tables (glob patterns like wp_* are supported)")
+	dbDiagramCmd.Flags().StringSlice("exclude", []string{}, "Exclude these tables (glob patterns like wp_* are supported)")
+	dbDiagramCmd.Flags().String("output-file", "", "Write the diagram to this file instead of stdout")
+	DbCmd.AddCommand(dbDiagramCmd)
+
 	connectionLayer, err = sql2.NewSqlConnectionParameterLayer(
 		layers.WithPrefix("test-"),
 	)
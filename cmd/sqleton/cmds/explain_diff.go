@@ -0,0 +1,281 @@
+package cmds
+
+import (
+	"context"
+	"github.com/go-go-golems/clay/pkg/sql"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	cmd_middlewares "github.com/go-go-golems/glazed/pkg/cmds/middlewares"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	sqleton_cmds "github.com/go-go-golems/sqleton/pkg/cmds"
+	"github.com/go-go-golems/sqleton/pkg/connection"
+	"github.com/go-go-golems/sqleton/pkg/explain"
+	"github.com/go-go-golems/sqleton/pkg/shell"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// ExplainDiffCommand explains a repository command twice, with two sets of parameters
+// or against two dbt profiles, and compares the plans node by node.
+type ExplainDiffCommand struct {
+	*cmds.CommandDescription
+	commands []cmds.Command
+}
+
+var _ cmds.GlazeCommand = (*ExplainDiffCommand)(nil)
+
+type ExplainDiffSettings struct {
+	Command     []string `glazed.parameter:"command"`
+	AParams     []string `glazed.parameter:"a-params"`
+	BParams     []string `glazed.parameter:"b-params"`
+	AProfile    string   `glazed.parameter:"a-profile"`
+	BProfile    string   `glazed.parameter:"b-profile"`
+	Analyze     bool     `glazed.parameter:"analyze"`
+	Threshold   float64  `glazed.parameter:"threshold"`
+	OnlyChanges bool     `glazed.parameter:"only-changes"`
+}
+
+func NewExplainDiffCommand(
+	commands []cmds.Command,
+	options ...cmds.CommandDescriptionOption,
+) (*ExplainDiffCommand, error) {
+	glazedParameterLayer, err := settings.NewGlazedParameterLayers()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create Glazed parameter layer")
+	}
+
+	options_ := append([]cmds.CommandDescriptionOption{
+		cmds.WithShort("Compare the query plans of a command between two parameter sets or two databases"),
+		cmds.WithLong("Explain a repository command twice and report, for each plan node, the differences in\n" +
+			"operation (access type for MySQL), index, estimated rows and cost.\n\n" +
+			"Side A and B use the connection flags, overridden by --a-profile and --b-profile (dbt profiles),\n" +
+			"and the defaults of the command, overridden by --a-params and --b-params (name=value).\n\n" +
+			"Example: sqleton explain-diff wp posts-counts --a-profile staging --b-profile prod"),
+		cmds.WithFlags(
+			parameters.NewParameterDefinition(
+				"a-params",
+				parameters.ParameterTypeStringList,
+				parameters.WithHelp("Parameters of side A, as name=value (list values are comma separated)"),
+			),
+			parameters.NewParameterDefinition(
+				"b-params",
+				parameters.ParameterTypeStringList,
+				parameters.WithHelp("Parameters of side B, as name=value (list values are comma separated)"),
+			),
+			parameters.NewParameterDefinition(
+				"a-profile",
+				parameters.ParameterTypeString,
+				parameters.WithHelp("dbt profile to connect to for side A"),
+			),
+			parameters.NewParameterDefinition(
+				"b-profile",
+				parameters.ParameterTypeString,
+				parameters.WithHelp("dbt profile to connect to for side B"),
+			),
+			parameters.NewParameterDefinition(
+				"analyze",
+				parameters.ParameterTypeBool,
+				parameters.WithHelp("Run the queries with EXPLAIN ANALYZE (PostgreSQL only)"),
+				parameters.WithDefault(false),
+			),
+			parameters.NewParameterDefinition(
+				"threshold",
+				parameters.ParameterTypeFloat,
+				parameters.WithHelp("Only report estimated rows and costs differing by more than this percentage"),
+				parameters.WithDefault(10.0),
+			),
+			parameters.NewParameterDefinition(
+				"only-changes",
+				parameters.ParameterTypeBool,
+				parameters.WithHelp("Only output the nodes that differ"),
+				parameters.WithDefault(false),
+			),
+		),
+		cmds.WithArguments(
+			parameters.NewParameterDefinition(
+				"command",
+				parameters.ParameterTypeStringList,
+				parameters.WithHelp("Path of the command to explain (for example: wp posts-counts)"),
+				parameters.WithRequired(true),
+			),
+		),
+		cmds.WithLayersList(glazedParameterLayer),
+	}, options...)
+
+	return &ExplainDiffCommand{
+		CommandDescription: cmds.NewCommandDescription("explain-diff", options_...),
+		commands:           commands,
+	}, nil
+}
+
+// nodesExplainer is implemented by the SQL and exec commands.
+type nodesExplainer interface {
+	ExplainNodes(ctx context.Context, parsedLayers *layers.ParsedLayers, analyze bool) ([]*explain.Node, error)
+}
+
+func (c *ExplainDiffCommand) RunIntoGlazeProcessor(
+	ctx context.Context,
+	parsedLayers *layers.ParsedLayers,
+	gp middlewares.Processor,
+) error {
+	s := &ExplainDiffSettings{}
+	err := parsedLayers.InitializeStruct(layers.DefaultSlug, s)
+	if err != nil {
+		return err
+	}
+
+	command, rest, err := shell.FindCommand(c.commands, s.Command)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return errors.Errorf("unexpected arguments after command: %s", strings.Join(rest, " "))
+	}
+
+	// exec commands are explained statement by statement in a rolled back transaction,
+	// since --analyze executes them
+	var sq *sqleton_cmds.SqlCommand
+	var explainer nodesExplainer
+	switch command_ := command.(type) {
+	case *sqleton_cmds.SqlCommand:
+		sq = command_
+		explainer = command_
+	case *sqleton_cmds.ExecCommand:
+		sq = command_.SqlCommand
+		explainer = command_
+	default:
+		return errors.Errorf("%s is not a SQL command", strings.Join(shell.CommandPath(command), " "))
+	}
+
+	layersA, err := c.parseSide(sq, parsedLayers, s.AParams, s.AProfile)
+	if err != nil {
+		return errors.Wrap(err, "side A")
+	}
+	layersB, err := c.parseSide(sq, parsedLayers, s.BParams, s.BProfile)
+	if err != nil {
+		return errors.Wrap(err, "side B")
+	}
+
+	nodesA, err := explainer.ExplainNodes(ctx, layersA, s.Analyze)
+	if err != nil {
+		return errors.Wrap(err, "could not explain side A")
+	}
+	nodesB, err := explainer.ExplainNodes(ctx, layersB, s.Analyze)
+	if err != nil {
+		return errors.Wrap(err, "could not explain side B")
+	}
+
+	for _, d := range explain.DiffPlans(nodesA, nodesB, s.Threshold) {
+		if s.OnlyChanges && !d.IsChanged() {
+			continue
+		}
+		err = gp.AddRow(ctx, d.ToRow())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseSide computes the parameters of one side of the diff: the defaults of the command,
+// overridden by the connection settings of explain-diff, the dbt profile and the name=value params.
+func (c *ExplainDiffCommand) parseSide(
+	sq *sqleton_cmds.SqlCommand,
+	parsedLayers *layers.ParsedLayers,
+	params []string,
+	profile string,
+) (*layers.ParsedLayers, error) {
+	description := sq.Description()
+
+	values := map[string]map[string]interface{}{}
+	for _, slug := range []string{sql.SqlConnectionSlug, sql.DbtSlug} {
+		values[slug] = map[string]interface{}{}
+		if layer, ok := parsedLayers.Get(slug); ok {
+			values[slug] = connection.ExplicitValues(layer)
+		}
+	}
+	if profile != "" {
+		values[sql.DbtSlug]["use-dbt-profiles"] = true
+		values[sql.DbtSlug]["dbt-profile"] = profile
+	}
+
+	for _, param := range joinListValues(params) {
+		name, value, ok := strings.Cut(param, "=")
+		if !ok {
+			return nil, errors.Errorf("invalid parameter %s, expected name=value", param)
+		}
+
+		var slug string
+		var pd *parameters.ParameterDefinition
+		description.Layers.ForEach(func(slug_ string, l layers.ParameterLayer) {
+			if pd != nil {
+				return
+			}
+			if pd_, ok := l.GetParameterDefinitions().Get(name); ok {
+				slug, pd = slug_, pd_
+			}
+		})
+		if pd == nil {
+			return nil, errors.Errorf("unknown parameter %s", name)
+		}
+
+		parts := []string{value}
+		if pd.Type.IsList() {
+			parts = strings.Split(value, ",")
+		}
+		parsed, err := pd.ParseParameter(parts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid value for parameter %s", name)
+		}
+		if values[slug] == nil {
+			values[slug] = map[string]interface{}{}
+		}
+		values[slug][name] = parsed.Value
+	}
+
+	// NOTE: the layers are copied into a new container, because the glazed layers can't be cloned
+	layers_ := layers.NewParameterLayers(layers.WithLayers(description.Layers.AsList()...))
+	ret := layers.NewParsedLayers()
+	err := cmd_middlewares.ExecuteMiddlewares(layers_, ret,
+		cmd_middlewares.UpdateFromMap(values,
+			parameters.WithParseStepSource("explain-diff"),
+		),
+		cmd_middlewares.SetFromDefaults(
+			parameters.WithParseStepSource("defaults"),
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	err = layers_.ForEachE(func(slug string, l layers.ParameterLayer) error {
+		return l.GetParameterDefinitions().ForEachE(func(pd *parameters.ParameterDefinition) error {
+			if _, ok := ret.GetParameter(slug, pd.Name); pd.Required && !ok {
+				return errors.Errorf("missing required parameter %s", pd.Name)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// joinListValues undoes the splitting of the flag values on commas,
+// so that ids=1,2 is seen as a single parameter.
+func joinListValues(params []string) []string {
+	ret := []string{}
+	for _, param := range params {
+		if !strings.Contains(param, "=") && len(ret) > 0 {
+			ret[len(ret)-1] += "," + param
+			continue
+		}
+		ret = append(ret, param)
+	}
+	return ret
+}
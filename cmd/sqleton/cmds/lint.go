@@ -0,0 +1,111 @@
+package cmds
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	sqleton_cmds "github.com/go-go-golems/sqleton/pkg/cmds"
+	"github.com/go-go-golems/sqleton/pkg/lint"
+	"github.com/pkg/errors"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// LintCommand validates the command files of the repositories, or of the given paths.
+type LintCommand struct {
+	*cmds.CommandDescription
+	repositoryPaths []string
+}
+
+var _ cmds.WriterCommand = (*LintCommand)(nil)
+
+type LintSettings struct {
+	Paths       []string `glazed.parameter:"paths"`
+	PrintSchema bool     `glazed.parameter:"print-schema"`
+}
+
+func NewLintCommand(repositoryPaths []string) (*LintCommand, error) {
+	return &LintCommand{
+		CommandDescription: cmds.NewCommandDescription(
+			"lint",
+			cmds.WithShort("Validate command files"),
+			cmds.WithLong("Validate the YAML command files of the repositories (or of the given files and directories):\n"+
+				"unknown keys, invalid settings, query templates referencing unknown flags and unused flags.\n\n"+
+				"Exits with an error if any error was found, warnings are only reported."),
+			cmds.WithFlags(
+				parameters.NewParameterDefinition(
+					"print-schema",
+					parameters.ParameterTypeBool,
+					parameters.WithHelp("Print the JSON Schema of the command files and exit"),
+					parameters.WithDefault(false),
+				),
+			),
+			cmds.WithArguments(
+				parameters.NewParameterDefinition(
+					"paths",
+					parameters.ParameterTypeStringList,
+					parameters.WithHelp("Command files or repository directories to lint (default: the configured repositories)"),
+				),
+			),
+		),
+		repositoryPaths: repositoryPaths,
+	}, nil
+}
+
+func (c *LintCommand) RunIntoWriter(
+	ctx context.Context,
+	parsedLayers *layers.ParsedLayers,
+	w io.Writer,
+) error {
+	s := &LintSettings{}
+	err := parsedLayers.InitializeStruct(layers.DefaultSlug, s)
+	if err != nil {
+		return err
+	}
+
+	if s.PrintSchema {
+		_, err = w.Write(sqleton_cmds.CommandJSONSchema)
+		return err
+	}
+
+	paths := s.Paths
+	if len(paths) == 0 {
+		paths = c.repositoryPaths
+	}
+	if len(paths) == 0 {
+		return errors.New("no repository configured, pass the files or directories to lint")
+	}
+
+	problems := []*lint.Problem{}
+	for _, p := range paths {
+		fi, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			problems_, err := lint.LintFS(os.DirFS(p), ".", p)
+			if err != nil {
+				return err
+			}
+			problems = append(problems, problems_...)
+		} else {
+			problems = append(problems, lint.LintFile(os.DirFS(filepath.Dir(p)), filepath.Base(p), p)...)
+		}
+	}
+
+	errorCount := 0
+	for _, p := range problems {
+		if p.Level == lint.LevelError {
+			errorCount++
+		}
+		_, _ = fmt.Fprintln(w, p.String())
+	}
+
+	if errorCount > 0 {
+		return errors.Errorf("found %d errors and %d warnings", errorCount, len(problems)-errorCount)
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/go-go-golems/clay/pkg/sql"
 	glazed_cmds "github.com/go-go-golems/glazed/pkg/cmds"
 	sqleton "github.com/go-go-golems/sqleton/pkg/cmds"
+	"github.com/go-go-golems/sqleton/pkg/connection"
 	"github.com/spf13/cobra"
 )
 
@@ -19,7 +20,7 @@ func init() {
 			glazed_cmds.WithShort("List MySQL processes"),
 			glazed_cmds.WithLong("SHOW PROCESSLIST"),
 		),
-		sqleton.WithDbConnectionFactory(sql.OpenDatabaseFromDefaultSqlConnectionLayer),
+		sqleton.WithDbConnectionFactory(connection.OpenDatabaseFromDefaultSqlConnectionLayer),
 		sqleton.WithQuery("SHOW PROCESSLIST"),
 	)
 	if err != nil {
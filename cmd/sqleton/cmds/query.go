@@ -2,12 +2,15 @@ package cmds
 
 import (
 	"context"
+	"fmt"
 	"github.com/go-go-golems/clay/pkg/sql"
 	"github.com/go-go-golems/glazed/pkg/cmds"
 	"github.com/go-go-golems/glazed/pkg/cmds/layers"
 	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
 	"github.com/go-go-golems/glazed/pkg/middlewares"
 	"github.com/go-go-golems/glazed/pkg/settings"
+	sqleton_cmds "github.com/go-go-golems/sqleton/pkg/cmds"
+	"github.com/go-go-golems/sqleton/pkg/flags"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -26,6 +29,14 @@ func NewQueryCommand(
 	if err != nil {
 		return nil, err
 	}
+	sqlParamsParameterLayer, err := flags.NewSqlParamsParameterLayer()
+	if err != nil {
+		return nil, err
+	}
+	sqlHelpersParameterLayer, err := flags.NewSqlHelpersParameterLayer()
+	if err != nil {
+		return nil, err
+	}
 	options_ := append([]cmds.CommandDescriptionOption{
 		cmds.WithShort("Run a SQL query passed as a CLI argument"),
 		cmds.WithArguments(parameters.NewParameterDefinition(
@@ -35,7 +46,7 @@ func NewQueryCommand(
 			parameters.WithRequired(true),
 		),
 		),
-		cmds.WithLayersList(glazeParameterLayer),
+		cmds.WithLayersList(glazeParameterLayer, sqlParamsParameterLayer, sqlHelpersParameterLayer),
 	}, options...)
 
 	return &QueryCommand{
@@ -60,6 +71,22 @@ func (q *QueryCommand) RunIntoGlazeProcessor(
 		return err
 	}
 
+	ps := &flags.SqlParamsSettings{}
+	err = parsedLayers.InitializeStruct(flags.SqlParamsSlug, ps)
+	if err != nil {
+		return err
+	}
+	params, err := sqleton_cmds.ParseNamedParameters(ps.Params, ps.ParamsFile)
+	if err != nil {
+		return err
+	}
+
+	ss := &flags.SqlHelpersSettings{}
+	err = parsedLayers.InitializeStruct(flags.SqlHelpersSlug, ss)
+	if err != nil {
+		return err
+	}
+
 	db, err := q.dbConnectionFactory(parsedLayers)
 	if err != nil {
 		return err
@@ -73,10 +100,49 @@ func (q *QueryCommand) RunIntoGlazeProcessor(
 		return err
 	}
 
-	err = sql.RunNamedQueryIntoGlaze(ctx, db, s.Query, map[string]interface{}{}, gp)
+	query := s.Query
+	var args []interface{}
+	if ps.RenderTemplate {
+		query, args, err = sqleton_cmds.RenderQueryWithArgs(ctx, db, s.Query, map[string]string{}, params, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	if ss.PrintQuery {
+		fmt.Println(query)
+		if len(args) > 0 {
+			fmt.Println("Args:")
+			fmt.Println(args)
+		}
+		return &cmds.ExitWithoutGlazeError{}
+	}
+
+	explainer, err := sqleton_cmds.GetExplainer(parsedLayers, db.DriverName())
+	if err != nil {
+		return err
+	}
+
+	timeout, err := sqleton_cmds.ParseTimeout(ss.Timeout)
 	if err != nil {
 		return err
 	}
+	connect := func() (*sqlx.DB, error) {
+		return q.dbConnectionFactory(parsedLayers)
+	}
 
-	return nil
+	return sqleton_cmds.RunWithTimeout(ctx, db, timeout, connect, func(ctx context.Context) error {
+		if explainer != nil {
+			explainDB, rollback, err := explainQueryer(ctx, db, explainer)
+			if err != nil {
+				return err
+			}
+			defer rollback()
+			return explainStatement(ctx, db, explainDB, explainer, query, args, params, gp)
+		}
+		if len(args) > 0 {
+			return sql.RunQueryIntoGlaze(ctx, db, query, args, gp)
+		}
+		return sqleton_cmds.RunNamedQueryIntoGlaze(ctx, db, query, params, gp)
+	})
 }
@@ -2,19 +2,26 @@ package cmds
 
 import (
 	"context"
+	"fmt"
 	"github.com/go-go-golems/clay/pkg/sql"
 	"github.com/go-go-golems/glazed/pkg/cmds"
 	"github.com/go-go-golems/glazed/pkg/cmds/layers"
 	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
 	"github.com/go-go-golems/glazed/pkg/middlewares"
 	cli "github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/go-go-golems/glazed/pkg/types"
+	sqleton_cmds "github.com/go-go-golems/sqleton/pkg/cmds"
+	"github.com/go-go-golems/sqleton/pkg/explain"
 	"github.com/go-go-golems/sqleton/pkg/flags"
+	"github.com/go-go-golems/sqleton/pkg/statements"
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
-
-	"github.com/spf13/cobra"
+	"github.com/rs/zerolog/log"
 	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 type RunCommand struct {
@@ -25,7 +32,9 @@ type RunCommand struct {
 var _ cmds.GlazeCommand = (*RunCommand)(nil)
 
 type RunSettings struct {
-	InputFiles []string `glazed.parameter:"input-files"`
+	InputFiles      []string `glazed.parameter:"input-files"`
+	StopOnError     bool     `glazed.parameter:"stop-on-error"`
+	ContinueOnError bool     `glazed.parameter:"continue-on-error"`
 }
 
 func (c *RunCommand) RunIntoGlazeProcessor(
@@ -38,11 +47,31 @@ func (c *RunCommand) RunIntoGlazeProcessor(
 	if err != nil {
 		return err
 	}
+	if s.StopOnError && s.ContinueOnError {
+		return errors.New("--stop-on-error and --continue-on-error can't be used together")
+	}
 	ss := &flags.SqlHelpersSettings{}
 	err = parsedLayers.InitializeStruct(flags.SqlHelpersSlug, ss)
 	if err != nil {
 		return errors.Wrap(err, "could not initialize sql-helpers settings")
 	}
+	ps := &flags.SqlParamsSettings{}
+	err = parsedLayers.InitializeStruct(flags.SqlParamsSlug, ps)
+	if err != nil {
+		return errors.Wrap(err, "could not initialize sql-params settings")
+	}
+	params, err := sqleton_cmds.ParseNamedParameters(ps.Params, ps.ParamsFile)
+	if err != nil {
+		return errors.Wrap(err, "could not parse named parameters")
+	}
+
+	stream := sqleton_cmds.IsStream(parsedLayers) && !ss.Explain
+	if stream {
+		err = sqleton_cmds.CheckStreamable(gp)
+		if err != nil {
+			return err
+		}
+	}
 
 	db, err := c.dbConnectionFactory(parsedLayers)
 	if err != nil {
@@ -57,34 +86,214 @@ func (c *RunCommand) RunIntoGlazeProcessor(
 		return errors.Wrapf(err, "Could not ping database")
 	}
 
-	for _, arg := range s.InputFiles {
-		query := ""
+	explainer, err := sqleton_cmds.GetExplainer(parsedLayers, db.DriverName())
+	if err != nil {
+		return err
+	}
+
+	timeout, err := sqleton_cmds.ParseTimeout(ss.Timeout)
+	if err != nil {
+		return err
+	}
+	connect := func() (*sqlx.DB, error) {
+		return c.dbConnectionFactory(parsedLayers)
+	}
 
-		if arg == "-" {
-			inBytes, err := io.ReadAll(os.Stdin)
-			cobra.CheckErr(err)
-			query = string(inBytes)
-		} else {
-			// read file
-			queryBytes, err := os.ReadFile(arg)
-			cobra.CheckErr(err)
+	// the statements that failed with --continue-on-error
+	var failed []string
+	err = sqleton_cmds.RunWithTimeout(ctx, db, timeout, connect, func(ctx context.Context) error {
+		if stream {
+			progress := sqleton_cmds.NewProgressProcessor(gp, os.Stderr)
+			defer progress.Finish()
+			gp = progress
+		}
 
-			query = string(queryBytes)
+		explainDB, rollback, err := explainQueryer(ctx, db, explainer)
+		if err != nil {
+			return err
 		}
+		defer rollback()
+
+		for _, arg := range s.InputFiles {
+			query := ""
+
+			if arg == "-" {
+				inBytes, err := io.ReadAll(os.Stdin)
+				if err != nil {
+					return errors.Wrap(err, "could not read query from stdin")
+				}
+				query = string(inBytes)
+			} else {
+				// read file
+				queryBytes, err := os.ReadFile(arg)
+				if err != nil {
+					return errors.Wrapf(err, "could not read query file %s", arg)
+				}
+
+				query = string(queryBytes)
+			}
+
+			// the front matter of sql files that double as commands is a comment, leave it out of the statements,
+			// but use the defaults of the parameters it declares, overridden by --param and --params-file
+			fileParams := params
+			if sf, err := sqleton_cmds.ParseSqlFile(query); err == nil && sf != nil {
+				fileParams, err = sqlFileParameters(arg, query, params)
+				if err != nil {
+					return err
+				}
+				query = sf.Query
+			}
+
+			var args []interface{}
+			if ps.RenderTemplate {
+				query, args, err = sqleton_cmds.RenderQueryWithArgs(ctx, db, query, map[string]string{}, fileParams, false)
+				if err != nil {
+					return errors.Wrapf(err, "could not render %s", arg)
+				}
+			}
 
-		if ss.Explain {
-			query = "EXPLAIN " + query
+			// bound arguments can't be distributed over multiple statements,
+			// so a rendered query with bound arguments is run as a single statement
+			statements_ := []string{query}
+			if len(args) == 0 {
+				statements_, err = statements.Split(query, statements.DriverOptions(db.DriverName())...)
+				if err != nil {
+					return errors.Wrapf(err, "could not split %s into statements", arg)
+				}
+			}
+
+			for i, statement := range statements_ {
+				// when running a multi-statement script, tag each row with the statement it comes from
+				var gp_ middlewares.Processor = gp
+				if len(statements_) > 1 {
+					gp_ = &statementTaggingProcessor{
+						Processor: gp,
+						index:     i,
+						statement: statement,
+					}
+				}
+
+				if explainer != nil {
+					err = explainStatement(ctx, db, explainDB, explainer, statement, args, fileParams, gp_)
+				} else if len(args) > 0 {
+					err = sql.RunQueryIntoGlaze(ctx, db, statement, args, gp_)
+				} else {
+					err = sqleton_cmds.RunNamedQueryIntoGlaze(ctx, db, statement, fileParams, gp_)
+				}
+				if err != nil {
+					// there is no point in continuing once the command timed out or was interrupted
+					if !s.ContinueOnError || ctx.Err() != nil {
+						return errors.Wrapf(err, "%s: statement %d failed", arg, i)
+					}
+					log.Error().Err(err).
+						Str("file", arg).
+						Int("statement_index", i).
+						Msg("Statement failed, continuing")
+					failed = append(failed, fmt.Sprintf("%s: statement %d", arg, i))
+				}
+			}
 		}
 
-		// TODO(2022-12-20, manuel): collect named parameters here, maybe through prerun?
-		// See: https://github.com/wesen/sqleton/issues/40
-		err = sql.RunNamedQueryIntoGlaze(ctx, db, query, map[string]interface{}{}, gp)
-		cobra.CheckErr(err)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(failed) > 0 {
+		// the rows of the statements that succeeded are still output. The glazed runner exits
+		// on the returned error without closing the processor (and thus without rendering
+		// the table), so close it here, before returning the summary of the failures
+		err = gp.Close(ctx)
+		if err != nil {
+			return err
+		}
+		return errors.Errorf("%d statements failed: %s", len(failed), strings.Join(failed, ", "))
 	}
 
 	return nil
 }
 
+// sqlFileParameters returns the default values of the parameters declared by the front matter
+// of the .sql file arg, overridden by the named parameters passed on the command line.
+func sqlFileParameters(arg string, content string, params map[string]interface{}) (map[string]interface{}, error) {
+	var f fs.FS
+	fileName := arg
+	if arg != "-" {
+		f = os.DirFS(filepath.Dir(arg))
+		fileName = filepath.Base(arg)
+	}
+	ret, err := sqleton_cmds.SqlFileDefaults(f, fileName, content)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not load the front matter of %s", arg)
+	}
+	for k, v := range params {
+		ret[k] = v
+	}
+	return ret, nil
+}
+
+// explainQueryer returns the queryer the statements are explained on. EXPLAIN ANALYZE executes
+// the statements, so they are explained in a transaction that is always rolled back by the returned
+// function, like the statements of exec commands.
+func explainQueryer(
+	ctx context.Context,
+	db *sqlx.DB,
+	explainer *explain.Explainer,
+) (sqlx.QueryerContext, func(), error) {
+	if explainer == nil || !explainer.Analyze() {
+		return db, func() {}, nil
+	}
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not start transaction")
+	}
+	return tx, func() {
+		_ = tx.Rollback()
+	}, nil
+}
+
+// explainStatement outputs the plan of a statement, binding its named parameters first.
+// The statement is explained on queryer, which is a transaction with --explain-analyze.
+func explainStatement(
+	ctx context.Context,
+	db *sqlx.DB,
+	queryer sqlx.QueryerContext,
+	explainer *explain.Explainer,
+	statement string,
+	args []interface{},
+	params map[string]interface{},
+	gp middlewares.Processor,
+) error {
+	if len(args) == 0 && len(params) > 0 {
+		var err error
+		statement, args, err = sqleton_cmds.BindNamedQuery(db, statement, params)
+		if err != nil {
+			return err
+		}
+	}
+	return explainer.Explain(ctx, queryer, statement, args, gp)
+}
+
+// statementTaggingProcessor prepends the index and the text of the statement that
+// produced each row, so that the result sets of a multi-statement script can be told apart.
+type statementTaggingProcessor struct {
+	middlewares.Processor
+	index     int
+	statement string
+}
+
+func (p *statementTaggingProcessor) AddRow(ctx context.Context, row types.Row) error {
+	ret := types.NewRow(
+		types.MRP("statement_index", p.index),
+		types.MRP("statement", p.statement),
+	)
+	for pair := row.Oldest(); pair != nil; pair = pair.Next() {
+		ret.Set(pair.Key, pair.Value)
+	}
+	return p.Processor.AddRow(ctx, ret)
+}
+
 func NewRunCommand(
 	dbConnectionFactory sql.DBConnectionFactory,
 	options ...cmds.CommandDescriptionOption,
@@ -97,9 +306,32 @@ func NewRunCommand(
 	if err != nil {
 		return nil, errors.Wrap(err, "could not create SQL helpers parameter layer")
 	}
+	sqlParamsParameterLayer, err := flags.NewSqlParamsParameterLayer()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create SQL params parameter layer")
+	}
 
 	options_ := append([]cmds.CommandDescriptionOption{
 		cmds.WithShort("Run a SQL query from sql files"),
+		cmds.WithLong("Run the SQL statements contained in the given files (use - to read from stdin).\n" +
+			"Files containing multiple statements are split and run statement by statement,\n" +
+			"and the resulting rows are tagged with statement_index and statement columns.\n\n" +
+			"Named parameters passed with --param and --params-file can be referenced as :name.\n" +
+			"With --render-template, the files are rendered like YAML query commands first, with the parameters as template data."),
+		cmds.WithFlags(
+			parameters.NewParameterDefinition(
+				"stop-on-error",
+				parameters.ParameterTypeBool,
+				parameters.WithHelp("Stop at the first statement that fails (the default, unless --continue-on-error is passed)"),
+				parameters.WithDefault(false),
+			),
+			parameters.NewParameterDefinition(
+				"continue-on-error",
+				parameters.ParameterTypeBool,
+				parameters.WithHelp("Continue with the next statement when a statement fails, instead of stopping"),
+				parameters.WithDefault(false),
+			),
+		),
 		cmds.WithArguments(
 			parameters.NewParameterDefinition(
 				"input-files",
@@ -110,6 +342,7 @@ func NewRunCommand(
 		cmds.WithLayersList(
 			glazedParameterLayer,
 			sqlHelpersParameterLayer,
+			sqlParamsParameterLayer,
 		),
 	}, options...)
 
@@ -0,0 +1,252 @@
+package cmds
+
+import (
+	"context"
+	"github.com/go-go-golems/clay/pkg/sql"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/go-go-golems/sqleton/pkg/schema"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type schemaRunFunc func(
+	ctx context.Context,
+	introspector schema.Introspector,
+	table string,
+	gp middlewares.Processor,
+) error
+
+// SchemaCommand outputs one kind of schema object (tables, columns, ...) as glazed rows.
+// The rows have the same shape for all the supported databases.
+type SchemaCommand struct {
+	*cmds.CommandDescription
+	dbConnectionFactory sql.DBConnectionFactory
+	run                 schemaRunFunc
+}
+
+var _ cmds.GlazeCommand = (*SchemaCommand)(nil)
+
+type SchemaSettings struct {
+	Table string `glazed.parameter:"table"`
+}
+
+func (sc *SchemaCommand) RunIntoGlazeProcessor(
+	ctx context.Context,
+	parsedLayers *layers.ParsedLayers,
+	gp middlewares.Processor,
+) error {
+	s := &SchemaSettings{}
+	err := parsedLayers.InitializeStruct(layers.DefaultSlug, s)
+	if err != nil {
+		return err
+	}
+
+	schemaName := ""
+	if p, ok := parsedLayers.GetParameter(sql.SqlConnectionSlug, "schema"); ok {
+		schemaName, _ = p.Value.(string)
+	}
+
+	db, err := sc.dbConnectionFactory(parsedLayers)
+	if err != nil {
+		return errors.Wrap(err, "could not open database")
+	}
+	defer func(db *sqlx.DB) {
+		_ = db.Close()
+	}(db)
+
+	introspector, err := schema.NewIntrospector(db, schemaName)
+	if err != nil {
+		return err
+	}
+
+	return sc.run(ctx, introspector, s.Table, gp)
+}
+
+func NewSchemaCommand(
+	dbConnectionFactory sql.DBConnectionFactory,
+	name string,
+	run schemaRunFunc,
+	options ...cmds.CommandDescriptionOption,
+) (*SchemaCommand, error) {
+	glazedParameterLayer, err := settings.NewGlazedParameterLayers()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create Glazed parameter layer")
+	}
+
+	options_ := append([]cmds.CommandDescriptionOption{
+		cmds.WithLayersList(glazedParameterLayer),
+	}, options...)
+
+	return &SchemaCommand{
+		CommandDescription:  cmds.NewCommandDescription(name, options_...),
+		dbConnectionFactory: dbConnectionFactory,
+		run:                 run,
+	}, nil
+}
+
+// NewSchemaGroupCommand creates the `schema` command group, with the tables, columns,
+// indexes, foreign-keys and views subcommands.
+// options are applied to all the subcommands, and are used to pass in the connection layers.
+func NewSchemaGroupCommand(
+	dbConnectionFactory sql.DBConnectionFactory,
+	options ...cmds.CommandDescriptionOption,
+) (*cobra.Command, error) {
+	cmd := &cobra.Command{
+		Use:   "schema",
+		Short: "Inspect the schema of a database (tables, columns, indexes, foreign keys, views)",
+	}
+
+	optionalTableArgument := cmds.WithArguments(
+		parameters.NewParameterDefinition(
+			"table",
+			parameters.ParameterTypeString,
+			parameters.WithHelp("Only show the objects of this table"),
+		),
+	)
+
+	schemaCommands := []struct {
+		name    string
+		run     schemaRunFunc
+		options []cmds.CommandDescriptionOption
+	}{
+		{
+			name: "tables",
+			run:  runSchemaTables,
+			options: []cmds.CommandDescriptionOption{
+				cmds.WithShort("List the tables of the database"),
+			},
+		},
+		{
+			name: "columns",
+			run:  runSchemaColumns,
+			options: []cmds.CommandDescriptionOption{
+				cmds.WithShort("List the columns of a table"),
+				cmds.WithArguments(
+					parameters.NewParameterDefinition(
+						"table",
+						parameters.ParameterTypeString,
+						parameters.WithHelp("Table to list the columns of"),
+						parameters.WithRequired(true),
+					),
+				),
+			},
+		},
+		{
+			name: "indexes",
+			run:  runSchemaIndexes,
+			options: []cmds.CommandDescriptionOption{
+				cmds.WithShort("List the indexes of the database, or of a single table"),
+				optionalTableArgument,
+			},
+		},
+		{
+			name: "foreign-keys",
+			run:  runSchemaForeignKeys,
+			options: []cmds.CommandDescriptionOption{
+				cmds.WithShort("List the foreign keys of the database, or of a single table"),
+				optionalTableArgument,
+			},
+		},
+		{
+			name: "views",
+			run:  runSchemaViews,
+			options: []cmds.CommandDescriptionOption{
+				cmds.WithShort("List the views of the database"),
+			},
+		},
+	}
+
+	for _, c := range schemaCommands {
+		schemaCommand, err := NewSchemaCommand(
+			dbConnectionFactory,
+			c.name,
+			c.run,
+			append(c.options, options...)...,
+		)
+		if err != nil {
+			return nil, err
+		}
+		cobraCommand, err := sql.BuildCobraCommandWithSqletonMiddlewares(schemaCommand)
+		if err != nil {
+			return nil, err
+		}
+		cmd.AddCommand(cobraCommand)
+	}
+
+	return cmd, nil
+}
+
+func runSchemaTables(ctx context.Context, introspector schema.Introspector, _ string, gp middlewares.Processor) error {
+	tables, err := introspector.Tables(ctx)
+	if err != nil {
+		return err
+	}
+	for _, t := range tables {
+		err = gp.AddRow(ctx, t.ToRow())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runSchemaColumns(ctx context.Context, introspector schema.Introspector, table string, gp middlewares.Processor) error {
+	columns, err := introspector.Columns(ctx, table)
+	if err != nil {
+		return err
+	}
+	for _, c := range columns {
+		err = gp.AddRow(ctx, c.ToRow())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runSchemaIndexes(ctx context.Context, introspector schema.Introspector, table string, gp middlewares.Processor) error {
+	indexes, err := introspector.Indexes(ctx, table)
+	if err != nil {
+		return err
+	}
+	for _, i := range indexes {
+		err = gp.AddRow(ctx, i.ToRow())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runSchemaForeignKeys(ctx context.Context, introspector schema.Introspector, table string, gp middlewares.Processor) error {
+	fks, err := introspector.ForeignKeys(ctx, table)
+	if err != nil {
+		return err
+	}
+	for _, fk := range fks {
+		err = gp.AddRow(ctx, fk.ToRow())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runSchemaViews(ctx context.Context, introspector schema.Introspector, _ string, gp middlewares.Processor) error {
+	views, err := introspector.Views(ctx)
+	if err != nil {
+		return err
+	}
+	for _, v := range views {
+		err = gp.AddRow(ctx, v.ToRow())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/go-go-golems/glazed/pkg/middlewares"
 	"github.com/go-go-golems/glazed/pkg/settings"
 	cmds2 "github.com/go-go-golems/sqleton/pkg/cmds"
+	"github.com/go-go-golems/sqleton/pkg/connection"
 	"github.com/go-go-golems/sqleton/pkg/flags"
 	"github.com/huandu/go-sqlbuilder"
 	"github.com/jmoiron/sqlx"
@@ -173,7 +174,7 @@ func (sc *SelectCommand) RunIntoGlazeProcessor(
 		sqlCommand, err := cmds2.NewSqlCommand(
 			cmds.NewCommandDescription(s.CreateQuery,
 				cmds.WithShort(short), cmds.WithFlags(flags...)),
-			cmds2.WithDbConnectionFactory(sql2.OpenDatabaseFromDefaultSqlConnectionLayer),
+			cmds2.WithDbConnectionFactory(connection.OpenDatabaseFromDefaultSqlConnectionLayer),
 			cmds2.WithQuery(query),
 		)
 		if err != nil {
@@ -214,11 +215,25 @@ func (sc *SelectCommand) RunIntoGlazeProcessor(
 		return err
 	}
 
-	err = sql2.RunQueryIntoGlaze(ctx, db, query, queryArgs, gp)
+	explainer, err := cmds2.GetExplainer(parsedLayers, db.DriverName())
 	if err != nil {
 		return err
 	}
-	return nil
+
+	timeout, err := cmds2.ParseTimeout(ss.Timeout)
+	if err != nil {
+		return err
+	}
+	connect := func() (*sqlx.DB, error) {
+		return sc.dbConnectionFactory(parsedLayers)
+	}
+
+	return cmds2.RunWithTimeout(ctx, db, timeout, connect, func(ctx context.Context) error {
+		if explainer != nil {
+			return explainer.Explain(ctx, db, query, queryArgs, gp)
+		}
+		return sql2.RunQueryIntoGlaze(ctx, db, query, queryArgs, gp)
+	})
 }
 
 func NewSelectCommand(
@@ -18,6 +18,7 @@ import (
 	"github.com/go-go-golems/parka/pkg/server"
 	"github.com/go-go-golems/parka/pkg/utils/fs"
 	sqleton_cmds "github.com/go-go-golems/sqleton/pkg/cmds"
+	"github.com/go-go-golems/sqleton/pkg/lint"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -198,7 +199,8 @@ func (s *ServeCommand) runWithConfigFile(
 		handlers.WithAppendCommandDirHandlerOptions(commandDirHandlerOptions...),
 		handlers.WithAppendTemplateDirHandlerOptions(templateDirHandlerOptions...),
 		handlers.WithAppendTemplateHandlerOptions(templateHandlerOptions...),
-		handlers.WithRepositoryFactory(sqleton_cmds.NewRepositoryFactory()),
+		// leave out the exec commands, and warn about the served queries inserting parameters without escaping them
+		handlers.WithRepositoryFactory(sqleton_cmds.NewRepositoryFactory(sqleton_cmds.NewReadOnlyLoader, lint.NewEscapingCheckLoader)),
 		handlers.WithDevMode(devMode),
 	)
 
@@ -359,7 +361,8 @@ func (s *ServeCommand) Run(
 		handlers.WithAppendCommandDirHandlerOptions(commandDirHandlerOptions...),
 		handlers.WithAppendTemplateDirHandlerOptions(templateDirHandlerOptions...),
 		handlers.WithAppendCommandHandlerOptions(commandHandlerOptions...),
-		handlers.WithRepositoryFactory(sqleton_cmds.NewRepositoryFactory()),
+		// leave out the exec commands, and warn about the served queries inserting parameters without escaping them
+		handlers.WithRepositoryFactory(sqleton_cmds.NewRepositoryFactory(sqleton_cmds.NewReadOnlyLoader, lint.NewEscapingCheckLoader)),
 		handlers.WithDevMode(ss.Dev),
 	)
 
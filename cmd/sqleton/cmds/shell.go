@@ -0,0 +1,499 @@
+package cmds
+
+import (
+	"context"
+	"fmt"
+	"github.com/chzyer/readline"
+	"github.com/go-go-golems/clay/pkg/sql"
+	"github.com/go-go-golems/glazed/pkg/cli"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	cmd_middlewares "github.com/go-go-golems/glazed/pkg/cmds/middlewares"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/go-go-golems/sqleton/pkg/connection"
+	"github.com/go-go-golems/sqleton/pkg/schema"
+	"github.com/go-go-golems/sqleton/pkg/shell"
+	"github.com/go-go-golems/sqleton/pkg/statements"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+)
+
+const shellHelp = `Enter SQL statements terminated by ; (they can span multiple lines).
+
+Meta commands:
+  \?                   show this help
+  \q                   quit the shell
+  \g                   run the statement in the buffer, even if it is not terminated by ;
+  \r                   clear the statement buffer
+  \dt                  list the tables
+  \dv                  list the views
+  \d [table]           describe a table (columns, indexes, foreign keys), or list the tables
+  \o [format]          set the output format (table, csv, tsv, json, yaml, markdown, ...)
+  \x [on|off]          toggle the expanded display (one row per column)
+  \commands            list the repository commands
+  \run command [args]  run a repository command, for example: \run mysql ps --limit 5
+`
+
+// ShellCommand is an interactive SQL shell.
+//
+// The connection is opened once for the whole session. Results are rendered using the glazed
+// output settings, which can be changed from inside the shell with the `\o` and `\x` meta commands.
+// Repository commands can be run with `\run`, and reuse the connection and output settings of the shell.
+type ShellCommand struct {
+	*cmds.CommandDescription
+	dbConnectionFactory sql.DBConnectionFactory
+	commands            []cmds.Command
+}
+
+var _ cmds.BareCommand = (*ShellCommand)(nil)
+
+type ShellSettings struct {
+	HistoryFile string `glazed.parameter:"history-file"`
+}
+
+func NewShellCommand(
+	dbConnectionFactory sql.DBConnectionFactory,
+	commands []cmds.Command,
+	options ...cmds.CommandDescriptionOption,
+) (*ShellCommand, error) {
+	glazedParameterLayer, err := settings.NewGlazedParameterLayers()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create Glazed parameter layer")
+	}
+
+	options_ := append([]cmds.CommandDescriptionOption{
+		cmds.WithShort("Start an interactive SQL shell"),
+		cmds.WithFlags(
+			parameters.NewParameterDefinition(
+				"history-file",
+				parameters.ParameterTypeString,
+				parameters.WithHelp("File to store the shell history in (default: ~/.sqleton/shell_history)"),
+			),
+		),
+		cmds.WithLayersList(glazedParameterLayer),
+	}, options...)
+
+	return &ShellCommand{
+		CommandDescription:  cmds.NewCommandDescription("shell", options_...),
+		dbConnectionFactory: dbConnectionFactory,
+		commands:            commands,
+	}, nil
+}
+
+func (sc *ShellCommand) Run(
+	ctx context.Context,
+	parsedLayers *layers.ParsedLayers,
+) error {
+	ss := &ShellSettings{}
+	err := parsedLayers.InitializeStruct(layers.DefaultSlug, ss)
+	if err != nil {
+		return err
+	}
+
+	glazedLayer, ok := parsedLayers.Get(settings.GlazedSlug)
+	if !ok {
+		return errors.New("glazed layer not found")
+	}
+
+	historyFile := ss.HistoryFile
+	if historyFile == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return errors.Wrap(err, "could not get home directory")
+		}
+		historyFile = filepath.Join(homeDir, ".sqleton", "shell_history")
+	}
+	err = os.MkdirAll(filepath.Dir(historyFile), 0755)
+	if err != nil {
+		return errors.Wrap(err, "could not create history directory")
+	}
+
+	db, err := sc.dbConnectionFactory(parsedLayers)
+	if err != nil {
+		return errors.Wrap(err, "could not open database")
+	}
+	defer func(db *sqlx.DB) {
+		_ = db.Close()
+	}(db)
+
+	err = db.PingContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	schemaName := ""
+	if p, ok := parsedLayers.GetParameter(sql.SqlConnectionSlug, "schema"); ok {
+		schemaName, _ = p.Value.(string)
+	}
+	introspector, err := schema.NewIntrospector(db, schemaName)
+	if err != nil {
+		return err
+	}
+
+	rl, err := readline.NewEx(&readline.Config{
+		Prompt:                 shellPrompt,
+		HistoryFile:            historyFile,
+		DisableAutoSaveHistory: true,
+		InterruptPrompt:        "^C",
+		EOFPrompt:              "\\q",
+	})
+	if err != nil {
+		return errors.Wrap(err, "could not initialize readline")
+	}
+	defer func(rl *readline.Instance) {
+		_ = rl.Close()
+	}(rl)
+
+	session := &shellSession{
+		db:           db,
+		introspector: introspector,
+		parsedLayers: parsedLayers,
+		glazedLayer:  glazedLayer.Clone(),
+		commands:     sc.commands,
+		buffer:       shell.NewBuffer(statements.DriverOptions(db.DriverName())...),
+		rl:           rl,
+		out:          rl.Stdout(),
+	}
+
+	_, _ = fmt.Fprintln(session.out, `sqleton shell. Type \? for help, \q to quit.`)
+
+	for {
+		if session.buffer.IsEmpty() {
+			rl.SetPrompt(shellPrompt)
+		} else {
+			rl.SetPrompt(shellContinuationPrompt)
+		}
+
+		line, err := rl.Readline()
+		if err == readline.ErrInterrupt {
+			// Ctrl-C clears the current statement, like in psql
+			session.buffer.Reset()
+			continue
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		quit, err := session.handleLine(ctx, line)
+		if err != nil {
+			_, _ = fmt.Fprintf(rl.Stderr(), "ERROR: %v\n", err)
+		}
+		if quit {
+			return nil
+		}
+	}
+}
+
+const (
+	shellPrompt             = "sqleton> "
+	shellContinuationPrompt = "      -> "
+)
+
+type shellSession struct {
+	db           *sqlx.DB
+	introspector schema.Introspector
+	// parsedLayers are the layers the shell was started with, and are used to pass
+	// the connection settings to repository commands.
+	parsedLayers *layers.ParsedLayers
+	// glazedLayer contains the current output settings, as modified by \o
+	glazedLayer *layers.ParsedLayer
+	expanded    bool
+	commands    []cmds.Command
+	buffer      *shell.Buffer
+	rl          *readline.Instance
+	out         io.Writer
+}
+
+// handleLine processes a line entered by the user, and returns true if the shell should exit.
+func (s *shellSession) handleLine(ctx context.Context, line string) (bool, error) {
+	if shell.IsMetaCommand(line) {
+		s.saveHistory(line)
+		mc, err := shell.ParseMetaCommand(line)
+		if err != nil {
+			return false, err
+		}
+		return s.runMetaCommand(ctx, mc)
+	}
+
+	if !s.buffer.AddLine(line) {
+		return false, nil
+	}
+
+	return false, s.runBuffer(ctx)
+}
+
+// saveHistory stores a history entry on a single line, so that multi-line statements
+// can be recalled as a whole.
+func (s *shellSession) saveHistory(entry string) {
+	entry = strings.TrimSpace(strings.ReplaceAll(entry, "\n", " "))
+	if entry != "" {
+		_ = s.rl.SaveHistory(entry)
+	}
+}
+
+func (s *shellSession) runBuffer(ctx context.Context) error {
+	if s.buffer.IsEmpty() {
+		return nil
+	}
+	s.saveHistory(s.buffer.String())
+	statements, err := s.buffer.Flush()
+	if err != nil {
+		return err
+	}
+
+	for _, statement := range statements {
+		err = s.withInterrupt(ctx, func(ctx context.Context) error {
+			return s.runProcessor(ctx, s.glazedLayer, func(gp middlewares.Processor) error {
+				return sql.RunQueryIntoGlaze(ctx, s.db, statement, []interface{}{}, gp)
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// withInterrupt cancels the context passed to f when Ctrl-C is pressed,
+// which aborts the running query instead of exiting the shell.
+func (s *shellSession) withInterrupt(ctx context.Context, f func(ctx context.Context) error) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+	return f(ctx)
+}
+
+// runProcessor sets up a glazed processor with the given output settings, and flushes
+// it to the terminal once f has added its rows.
+func (s *shellSession) runProcessor(
+	ctx context.Context,
+	glazedLayer *layers.ParsedLayer,
+	f func(gp middlewares.Processor) error,
+) error {
+	gp, err := settings.SetupTableProcessor(glazedLayer)
+	if err != nil {
+		return err
+	}
+	if s.expanded {
+		gp.AddRowMiddleware(shell.NewExpandedRowMiddleware())
+	}
+
+	_, err = settings.SetupProcessorOutput(gp, glazedLayer, s.out)
+	if err != nil {
+		return err
+	}
+
+	err = f(gp)
+	if err != nil {
+		return err
+	}
+
+	return gp.Close(ctx)
+}
+
+func (s *shellSession) runMetaCommand(ctx context.Context, mc *shell.MetaCommand) (bool, error) {
+	switch mc.Name {
+	case "q", "quit":
+		return true, nil
+
+	case "?", "h", "help":
+		_, _ = fmt.Fprint(s.out, shellHelp)
+
+	case "g":
+		return false, s.runBuffer(ctx)
+
+	case "r":
+		s.buffer.Reset()
+
+	case "dt":
+		return false, s.runSchema(ctx, runSchemaTables, "")
+
+	case "dv":
+		return false, s.runSchema(ctx, runSchemaViews, "")
+
+	case "d":
+		if len(mc.Args) == 0 {
+			return false, s.runSchema(ctx, runSchemaTables, "")
+		}
+		for _, f := range []schemaRunFunc{runSchemaColumns, runSchemaIndexes, runSchemaForeignKeys} {
+			err := s.runSchema(ctx, f, mc.Args[0])
+			if err != nil {
+				return false, err
+			}
+		}
+
+	case "o":
+		return false, s.setOutputFormat(mc.Args)
+
+	case "x":
+		return false, s.setExpanded(mc.Args)
+
+	case "commands":
+		for _, command := range s.commands {
+			_, _ = fmt.Fprintf(s.out, "%-30s %s\n",
+				strings.Join(shell.CommandPath(command), " "),
+				command.Description().Short)
+		}
+
+	case "run":
+		if len(mc.Args) == 0 {
+			return false, errors.New(`usage: \run command [args]`)
+		}
+		return false, s.withInterrupt(ctx, func(ctx context.Context) error {
+			return s.runRepositoryCommand(ctx, mc.Args)
+		})
+
+	default:
+		return false, errors.Errorf(`unknown meta command \%s, type \? for help`, mc.Name)
+	}
+
+	return false, nil
+}
+
+func (s *shellSession) runSchema(ctx context.Context, f schemaRunFunc, table string) error {
+	return s.runProcessor(ctx, s.glazedLayer, func(gp middlewares.Processor) error {
+		return f(ctx, s.introspector, table, gp)
+	})
+}
+
+func (s *shellSession) setOutputFormat(args []string) error {
+	if len(args) == 0 {
+		output, _ := s.glazedLayer.GetParameter("output")
+		_, _ = fmt.Fprintf(s.out, "Output format is %v\n", output)
+		return nil
+	}
+
+	pd, ok := s.glazedLayer.Layer.GetParameterDefinitions().Get("output")
+	if !ok {
+		return errors.New("output parameter not found")
+	}
+	err := pd.CheckValueValidity(args[0])
+	if err != nil {
+		return err
+	}
+	s.glazedLayer.Parameters.UpdateValue("output", pd, args[0], parameters.WithParseStepSource("shell"))
+	_, _ = fmt.Fprintf(s.out, "Output format is %s\n", args[0])
+
+	return nil
+}
+
+func (s *shellSession) setExpanded(args []string) error {
+	if len(args) == 0 {
+		s.expanded = !s.expanded
+	} else {
+		switch args[0] {
+		case "on":
+			s.expanded = true
+		case "off":
+			s.expanded = false
+		default:
+			return errors.Errorf(`usage: \x [on|off]`)
+		}
+	}
+
+	if s.expanded {
+		_, _ = fmt.Fprintln(s.out, "Expanded display is on.")
+	} else {
+		_, _ = fmt.Fprintln(s.out, "Expanded display is off.")
+	}
+	return nil
+}
+
+// runRepositoryCommand parses the arguments of a repository command as if it were called from
+// the command line, using the connection and output settings of the shell as defaults.
+func (s *shellSession) runRepositoryCommand(ctx context.Context, args []string) error {
+	command, rest, err := shell.FindCommand(s.commands, args)
+	if err != nil {
+		return err
+	}
+
+	description := command.Description()
+	// NOTE: the layers are copied into a new container instead of being cloned, because
+	// the glazed layers can't be cloned, and the parser adds its own glazed-command layer.
+	parser, err := cli.NewCobraParserFromLayers(
+		layers.NewParameterLayers(layers.WithLayers(description.Layers.AsList()...)),
+		cli.WithCobraMiddlewaresFunc(s.repositoryCommandMiddlewares),
+	)
+	if err != nil {
+		return err
+	}
+	cobraCommand := cli.NewCobraCommandFromCommandDescription(description)
+	cobraCommand.SetOut(s.out)
+	err = parser.AddToCobraCommand(cobraCommand)
+	if err != nil {
+		return err
+	}
+
+	err = cobraCommand.ParseFlags(rest)
+	if err == pflag.ErrHelp {
+		_, _ = fmt.Fprintln(s.out, description.Short)
+		return cobraCommand.Usage()
+	}
+	if err != nil {
+		return err
+	}
+
+	parsedLayers, err := parser.Parse(cobraCommand, cobraCommand.Flags().Args())
+	if err != nil {
+		return err
+	}
+
+	switch c := command.(type) {
+	case cmds.GlazeCommand:
+		glazedLayer, ok := parsedLayers.Get(settings.GlazedSlug)
+		if !ok {
+			return errors.New("glazed layer not found")
+		}
+		return s.runProcessor(ctx, glazedLayer, func(gp middlewares.Processor) error {
+			return c.RunIntoGlazeProcessor(ctx, parsedLayers, gp)
+		})
+	case cmds.WriterCommand:
+		return c.RunIntoWriter(ctx, parsedLayers, s.out)
+	case cmds.BareCommand:
+		return c.Run(ctx, parsedLayers)
+	default:
+		return errors.Errorf("command %s can't be run from the shell", description.Name)
+	}
+}
+
+// repositoryCommandMiddlewares gives the flags passed to \run precedence over the settings of the shell,
+// which themselves override the defaults of the command.
+func (s *shellSession) repositoryCommandMiddlewares(
+	_ *cli.GlazedCommandSettings,
+	cmd *cobra.Command,
+	args []string,
+) ([]cmd_middlewares.Middleware, error) {
+	shellValues := map[string]map[string]interface{}{
+		settings.GlazedSlug: s.glazedLayer.Parameters.ToMap(),
+	}
+	for _, slug := range []string{sql.SqlConnectionSlug, sql.DbtSlug} {
+		if layer, ok := s.parsedLayers.Get(slug); ok {
+			shellValues[slug] = connection.ExplicitValues(layer)
+		}
+	}
+
+	return []cmd_middlewares.Middleware{
+		cmd_middlewares.ParseFromCobraCommand(cmd,
+			parameters.WithParseStepSource("cobra"),
+		),
+		cmd_middlewares.GatherArguments(args,
+			parameters.WithParseStepSource("arguments"),
+		),
+		cmd_middlewares.UpdateFromMap(shellValues,
+			parameters.WithParseStepSource("shell"),
+		),
+		cmd_middlewares.SetFromDefaults(parameters.WithParseStepSource(parameters.SourceDefaults)),
+	}, nil
+}
@@ -17,6 +17,7 @@ import (
 	parka_doc "github.com/go-go-golems/parka/pkg/doc"
 	"github.com/go-go-golems/sqleton/cmd/sqleton/cmds"
 	sqleton_cmds "github.com/go-go-golems/sqleton/pkg/cmds"
+	"github.com/go-go-golems/sqleton/pkg/connection"
 	"github.com/go-go-golems/sqleton/pkg/flags"
 	"github.com/pkg/errors"
 	"github.com/pkg/profile"
@@ -77,7 +78,7 @@ func main() {
 	if len(os.Args) >= 3 && os.Args[1] == "run-command" && os.Args[2] != "--help" {
 		// load the command
 		loader := &sqleton_cmds.SqlCommandLoader{
-			DBConnectionFactory: sql.OpenDatabaseFromDefaultSqlConnectionLayer,
+			DBConnectionFactory: connection.OpenDatabaseFromDefaultSqlConnectionLayer,
 		}
 		fs_, filePath, err := loaders.FileNameToFsFilePath(os.Args[2])
 		if err != nil {
@@ -171,7 +172,7 @@ func initAllCommands(helpSystem *help.HelpSystem) error {
 		return err
 	}
 
-	runCommand, err := cmds.NewRunCommand(sql.OpenDatabaseFromDefaultSqlConnectionLayer,
+	runCommand, err := cmds.NewRunCommand(connection.OpenDatabaseFromDefaultSqlConnectionLayer,
 		glazed_cmds.WithLayersList(
 			dbtParameterLayer,
 			sqlConnectionParameterLayer,
@@ -186,7 +187,7 @@ func initAllCommands(helpSystem *help.HelpSystem) error {
 	}
 	rootCmd.AddCommand(cobraRunCommand)
 
-	selectCommand, err := cmds.NewSelectCommand(sql.OpenDatabaseFromDefaultSqlConnectionLayer,
+	selectCommand, err := cmds.NewSelectCommand(connection.OpenDatabaseFromDefaultSqlConnectionLayer,
 		glazed_cmds.WithLayersList(
 			dbtParameterLayer,
 			sqlConnectionParameterLayer,
@@ -203,7 +204,7 @@ func initAllCommands(helpSystem *help.HelpSystem) error {
 	rootCmd.AddCommand(cobraSelectCommand)
 
 	queryCommand, err := cmds.NewQueryCommand(
-		sql.OpenDatabaseFromDefaultSqlConnectionLayer,
+		connection.OpenDatabaseFromDefaultSqlConnectionLayer,
 		glazed_cmds.WithLayersList(
 			dbtParameterLayer,
 			sqlConnectionParameterLayer,
@@ -217,6 +218,23 @@ func initAllCommands(helpSystem *help.HelpSystem) error {
 	}
 	rootCmd.AddCommand(cobraQueryCommand)
 
+	schemaCommand, err := cmds.NewSchemaGroupCommand(
+		connection.OpenDatabaseFromDefaultSqlConnectionLayer,
+		glazed_cmds.WithLayersList(
+			dbtParameterLayer,
+			sqlConnectionParameterLayer,
+		))
+	if err != nil {
+		return err
+	}
+	rootCmd.AddCommand(schemaCommand)
+
+	cacheCommand, err := cmds.NewCacheGroupCommand()
+	if err != nil {
+		return err
+	}
+	rootCmd.AddCommand(cacheCommand)
+
 	rootCmd.AddCommand(cmds.MysqlCmd)
 
 	repositoryPaths := viper.GetStringSlice("repositories")
@@ -228,7 +246,7 @@ func initAllCommands(helpSystem *help.HelpSystem) error {
 	}
 
 	loader := &sqleton_cmds.SqlCommandLoader{
-		DBConnectionFactory: sql.OpenDatabaseFromDefaultSqlConnectionLayer,
+		DBConnectionFactory: connection.OpenDatabaseFromDefaultSqlConnectionLayer,
 	}
 	directories := []repositories.Directory{
 		{
@@ -273,7 +291,7 @@ func initAllCommands(helpSystem *help.HelpSystem) error {
 	}
 
 	serveCommand, err := cmds.NewServeCommand(
-		sql.OpenDatabaseFromDefaultSqlConnectionLayer,
+		connection.OpenDatabaseFromDefaultSqlConnectionLayer,
 		repositoryPaths,
 	)
 	if err != nil {
@@ -285,6 +303,47 @@ func initAllCommands(helpSystem *help.HelpSystem) error {
 	}
 	rootCmd.AddCommand(cobraServeCommand)
 
+	lintCommand, err := cmds.NewLintCommand(repositoryPaths)
+	if err != nil {
+		return err
+	}
+	cobraLintCommand, err := cli.BuildCobraCommandFromWriterCommand(lintCommand)
+	if err != nil {
+		return err
+	}
+	rootCmd.AddCommand(cobraLintCommand)
+
+	shellCommand, err := cmds.NewShellCommand(
+		connection.OpenDatabaseFromDefaultSqlConnectionLayer,
+		allCommands,
+		glazed_cmds.WithLayersList(
+			dbtParameterLayer,
+			sqlConnectionParameterLayer,
+		))
+	if err != nil {
+		return err
+	}
+	cobraShellCommand, err := sql.BuildCobraCommandWithSqletonMiddlewares(shellCommand)
+	if err != nil {
+		return err
+	}
+	rootCmd.AddCommand(cobraShellCommand)
+
+	explainDiffCommand, err := cmds.NewExplainDiffCommand(
+		allCommands,
+		glazed_cmds.WithLayersList(
+			dbtParameterLayer,
+			sqlConnectionParameterLayer,
+		))
+	if err != nil {
+		return err
+	}
+	cobraExplainDiffCommand, err := sql.BuildCobraCommandWithSqletonMiddlewares(explainDiffCommand)
+	if err != nil {
+		return err
+	}
+	rootCmd.AddCommand(cobraExplainDiffCommand)
+
 	queriesCommand, err := ls_commands.NewListCommandsCommand(allCommands,
 		ls_commands.WithCommandDescriptionOptions(
 			glazed_cmds.WithShort("Commands related to sqleton queries"),
@@ -296,6 +355,9 @@ func initAllCommands(helpSystem *help.HelpSystem) error {
 		) ([]types.Row, error) {
 			ret := []types.Row{row}
 			switch c := command.(type) {
+			case *sqleton_cmds.ExecCommand:
+				row.Set("query", c.Query)
+				row.Set("type", "exec")
 			case *sqleton_cmds.SqlCommand:
 				row.Set("query", c.Query)
 				row.Set("type", "sql")
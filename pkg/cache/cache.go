@@ -0,0 +1,289 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	// the cache is stored in a sqlite file, using the same pure-Go driver as the sqlite connections
+	_ "modernc.org/sqlite"
+)
+
+const cacheFileName = "cache.db"
+
+// Cache stores the rows returned by queries in a local sqlite database, so that
+// expensive queries don't have to be rerun until their entry expires.
+type Cache struct {
+	db  *sqlx.DB
+	dir string
+}
+
+// Entry describes a cached result, without its rows.
+type Entry struct {
+	Key        string    `db:"key"`
+	Command    string    `db:"command"`
+	Connection string    `db:"connection"`
+	Query      string    `db:"query"`
+	CreatedAt  time.Time `db:"created_at"`
+	ExpiresAt  time.Time `db:"expires_at"`
+	RowCount   int       `db:"row_count"`
+	Size       int       `db:"size"`
+}
+
+func (e *Entry) IsExpired(now time.Time) bool {
+	return !now.Before(e.ExpiresAt)
+}
+
+func (e *Entry) ToRow(now time.Time) types.Row {
+	return types.NewRow(
+		types.MRP("key", e.Key),
+		types.MRP("command", e.Command),
+		types.MRP("connection", e.Connection),
+		types.MRP("created_at", e.CreatedAt),
+		types.MRP("expires_at", e.ExpiresAt),
+		types.MRP("expired", e.IsExpired(now)),
+		types.MRP("rows", e.RowCount),
+		types.MRP("size", e.Size),
+		types.MRP("query", e.Query),
+	)
+}
+
+// DefaultDirectory returns ~/.sqleton/cache.
+func DefaultDirectory() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.Wrap(err, "could not get home directory")
+	}
+	return filepath.Join(homeDir, ".sqleton", "cache"), nil
+}
+
+// Open opens (and creates if necessary) the cache database in dir.
+// If dir is empty, DefaultDirectory is used.
+func Open(dir string) (*Cache, error) {
+	if dir == "" {
+		var err error
+		dir, err = DefaultDirectory()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not create cache directory %s", dir)
+	}
+
+	// the busy timeout allows concurrent sqleton processes (for example serve and the CLI)
+	// to share the cache
+	db, err := sqlx.Connect("sqlite", "file:"+filepath.Join(dir, cacheFileName)+"?_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, errors.Wrap(err, "could not open cache database")
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS entries (
+			key TEXT PRIMARY KEY,
+			command TEXT NOT NULL,
+			connection TEXT NOT NULL,
+			query TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			expires_at TIMESTAMP NOT NULL,
+			row_count INTEGER NOT NULL,
+			size INTEGER NOT NULL,
+			rows BLOB NOT NULL
+		)`)
+	if err != nil {
+		_ = db.Close()
+		return nil, errors.Wrap(err, "could not create cache table")
+	}
+
+	return &Cache{db: db, dir: dir}, nil
+}
+
+func (c *Cache) Close() error {
+	return c.db.Close()
+}
+
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// Key computes a cache key by hashing the JSON serialization of parts.
+func Key(parts ...interface{}) (string, error) {
+	b, err := json.Marshal(parts)
+	if err != nil {
+		return "", errors.Wrap(err, "could not compute cache key")
+	}
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:]), nil
+}
+
+// Get returns the rows stored under key, if they haven't expired yet.
+func (c *Cache) Get(ctx context.Context, key string) ([]types.Row, bool, error) {
+	var data []byte
+	err := c.db.GetContext(ctx, &data,
+		"SELECT rows FROM entries WHERE key = ? AND expires_at > ?", key, time.Now().UTC())
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, errors.Wrap(err, "could not read cache entry")
+	}
+
+	rows, err := decodeRows(data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return rows, true, nil
+}
+
+// Set stores rows under entry.Key, replacing any previous entry.
+// The CreatedAt, ExpiresAt, RowCount and Size fields of entry are set from ttl and rows.
+func (c *Cache) Set(ctx context.Context, entry *Entry, ttl time.Duration, rows []types.Row) error {
+	data, err := encodeRows(rows)
+	if err != nil {
+		return err
+	}
+
+	entry.CreatedAt = time.Now().UTC()
+	entry.ExpiresAt = entry.CreatedAt.Add(ttl)
+	entry.RowCount = len(rows)
+	entry.Size = len(data)
+
+	_, err = c.db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO entries
+			(key, command, connection, query, created_at, expires_at, row_count, size, rows)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.Key, entry.Command, entry.Connection, entry.Query,
+		entry.CreatedAt, entry.ExpiresAt, entry.RowCount, entry.Size, data)
+	if err != nil {
+		return errors.Wrap(err, "could not write cache entry")
+	}
+
+	return nil
+}
+
+// List returns all the entries of the cache, including the expired ones.
+func (c *Cache) List(ctx context.Context) ([]*Entry, error) {
+	ret := []*Entry{}
+	err := c.db.SelectContext(ctx, &ret, `
+		SELECT key, command, connection, query, created_at, expires_at, row_count, size
+		FROM entries
+		ORDER BY created_at`)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list cache entries")
+	}
+	return ret, nil
+}
+
+// Clear deletes the entries of the cache, or only the expired ones if expiredOnly is set,
+// and returns the number of deleted entries.
+func (c *Cache) Clear(ctx context.Context, expiredOnly bool) (int64, error) {
+	query := "DELETE FROM entries"
+	args := []interface{}{}
+	if expiredOnly {
+		query += " WHERE expires_at <= ?"
+		args = append(args, time.Now().UTC())
+	}
+
+	res, err := c.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, errors.Wrap(err, "could not clear cache")
+	}
+	return res.RowsAffected()
+}
+
+// cachedField is the serialized form of a single row field.
+// time.Time values are stored separately so that they are restored as time.Time
+// instead of strings.
+type cachedField struct {
+	Name  string      `json:"n"`
+	Value interface{} `json:"v"`
+	Time  *time.Time  `json:"t,omitempty"`
+}
+
+func encodeRows(rows []types.Row) ([]byte, error) {
+	encoded := make([][]cachedField, 0, len(rows))
+	for _, row := range rows {
+		fields := make([]cachedField, 0, row.Len())
+		for pair := row.Oldest(); pair != nil; pair = pair.Next() {
+			field := cachedField{Name: pair.Key}
+			switch v := pair.Value.(type) {
+			case time.Time:
+				field.Time = &v
+			case *time.Time:
+				field.Time = v
+			default:
+				field.Value = v
+			}
+			fields = append(fields, field)
+		}
+		encoded = append(encoded, fields)
+	}
+
+	data, err := json.Marshal(encoded)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not serialize rows")
+	}
+	return data, nil
+}
+
+func decodeRows(data []byte) ([]types.Row, error) {
+	encoded := [][]cachedField{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err := decoder.Decode(&encoded)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not deserialize cached rows")
+	}
+
+	ret := make([]types.Row, 0, len(encoded))
+	for _, fields := range encoded {
+		row := types.NewRow()
+		for _, field := range fields {
+			if field.Time != nil {
+				row.Set(field.Name, *field.Time)
+				continue
+			}
+			row.Set(field.Name, decodeValue(field.Value))
+		}
+		ret = append(ret, row)
+	}
+	return ret, nil
+}
+
+// decodeValue turns the json.Numbers back into int64 or float64.
+func decodeValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case []interface{}:
+		for i := range v {
+			v[i] = decodeValue(v[i])
+		}
+		return v
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = decodeValue(v[k])
+		}
+		return v
+	default:
+		return v
+	}
+}
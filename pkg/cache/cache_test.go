@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"context"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestSetGet(t *testing.T) {
+	c, err := Open(t.TempDir())
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+	ctx := context.Background()
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	rows := []types.Row{
+		types.NewRow(
+			types.MRP("id", int64(1)),
+			types.MRP("name", "foo"),
+			types.MRP("price", 1.5),
+			types.MRP("deleted", false),
+			types.MRP("parent", nil),
+			types.MRP("created_at", createdAt),
+		),
+	}
+	err = c.Set(ctx, &Entry{Key: "k", Command: "test", Query: "SELECT 1"}, time.Hour, rows)
+	require.NoError(t, err)
+
+	cached, ok, err := c.Get(ctx, "k")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Len(t, cached, 1)
+
+	keys := []string{}
+	for pair := cached[0].Oldest(); pair != nil; pair = pair.Next() {
+		keys = append(keys, pair.Key)
+	}
+	assert.Equal(t, []string{"id", "name", "price", "deleted", "parent", "created_at"}, keys)
+	v, _ := cached[0].Get("id")
+	assert.Equal(t, int64(1), v)
+	v, _ = cached[0].Get("price")
+	assert.Equal(t, 1.5, v)
+	v, _ = cached[0].Get("deleted")
+	assert.Equal(t, false, v)
+	v, _ = cached[0].Get("parent")
+	assert.Nil(t, v)
+	v, _ = cached[0].Get("created_at")
+	assert.True(t, createdAt.Equal(v.(time.Time)))
+
+	_, ok, err = c.Get(ctx, "missing")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestExpiryAndClear(t *testing.T) {
+	c, err := Open(t.TempDir())
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+	ctx := context.Background()
+
+	err = c.Set(ctx, &Entry{Key: "expired"}, -time.Second, []types.Row{})
+	require.NoError(t, err)
+	err = c.Set(ctx, &Entry{Key: "valid"}, time.Hour, []types.Row{})
+	require.NoError(t, err)
+
+	_, ok, err := c.Get(ctx, "expired")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	entries, err := c.List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	deleted, err := c.Clear(ctx, true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	deleted, err = c.Clear(ctx, false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+}
+
+func TestKey(t *testing.T) {
+	k1, err := Key("query", "SELECT 1", []interface{}{1})
+	require.NoError(t, err)
+	k2, err := Key("query", "SELECT 1", []interface{}{2})
+	require.NoError(t, err)
+	assert.NotEqual(t, k1, k2)
+	assert.Len(t, k1, 64)
+}
@@ -0,0 +1,257 @@
+package cmds
+
+import (
+	"context"
+	clay_sql "github.com/go-go-golems/clay/pkg/sql"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/go-go-golems/sqleton/pkg/cache"
+	"github.com/go-go-golems/sqleton/pkg/connection"
+	"github.com/go-go-golems/sqleton/pkg/flags"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// CacheKeyQuery keys the cache on the rendered query, its arguments and the connection.
+	CacheKeyQuery = "query"
+	// CacheKeyParameters keys the cache on the command and its parameter values (including the connection).
+	// Cached results are returned without connecting to the database, but commands whose
+	// query depends on the state of the database (subqueries) might return stale results.
+	CacheKeyParameters = "parameters"
+
+	DefaultCacheTTL = 5 * time.Minute
+)
+
+// CacheSettings is the `cache:` block of a SqlCommandDescription.
+//
+//	cache:
+//	  ttl: 10m
+//	  key: parameters
+//
+// Declaring the block enables caching for the command, unless --no-cache is passed.
+type CacheSettings struct {
+	TTL string `yaml:"ttl,omitempty"`
+	Key string `yaml:"key,omitempty"`
+}
+
+func (c *CacheSettings) GetTTL() (time.Duration, error) {
+	if c.TTL == "" {
+		return DefaultCacheTTL, nil
+	}
+	ttl, err := time.ParseDuration(c.TTL)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid cache ttl %s", c.TTL)
+	}
+	if ttl <= 0 {
+		return 0, errors.Errorf("cache ttl must be positive, got %s", c.TTL)
+	}
+	return ttl, nil
+}
+
+func (c *CacheSettings) GetKeyStrategy() (string, error) {
+	switch c.Key {
+	case "", CacheKeyQuery:
+		return CacheKeyQuery, nil
+	case CacheKeyParameters:
+		return CacheKeyParameters, nil
+	default:
+		return "", errors.Errorf("unknown cache key strategy %s (expected %s or %s)",
+			c.Key, CacheKeyQuery, CacheKeyParameters)
+	}
+}
+
+func (c *CacheSettings) Validate() error {
+	_, err := c.GetTTL()
+	if err != nil {
+		return err
+	}
+	_, err = c.GetKeyStrategy()
+	return err
+}
+
+// queryCache is the cache used by a single run of a SqlCommand.
+type queryCache struct {
+	store       *cache.Cache
+	ttl         time.Duration
+	keyStrategy string
+	command     string
+	// connection describes the connection (with its password masked), and is shown by `cache ls`
+	connection string
+	// connectionIdentity identifies the database the query runs against
+	connectionIdentity interface{}
+	// parameters contains the values of all the layers that influence the results of the command
+	parameters map[string]map[string]interface{}
+}
+
+// nonResultLayers are the layers whose parameters don't change the rows returned by a query,
+// and that are thus left out of the cache key.
+var nonResultLayers = map[string]bool{
+	settings.GlazedSlug:        true,
+	flags.SqlCacheSlug:         true,
+	flags.SqlHelpersSlug:       true,
+	"glazed-command":           true,
+	clay_sql.DbtSlug:           true,
+	clay_sql.SqlConnectionSlug: true,
+}
+
+// openQueryCache returns the cache to use for this run, or nil if caching is disabled,
+// either because the command has no cache block and --cache was not passed, or because
+// --no-cache was passed. If the cache store can't be opened, a warning is logged and the
+// query runs uncached.
+func (s *SqlCommand) openQueryCache(parsedLayers *layers.ParsedLayers) (*queryCache, error) {
+	cs := &flags.SqlCacheSettings{}
+	if _, ok := parsedLayers.Get(flags.SqlCacheSlug); ok {
+		err := parsedLayers.InitializeStruct(flags.SqlCacheSlug, cs)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not initialize sql-cache settings")
+		}
+	}
+
+	if cs.NoCache || (s.Cache == nil && !cs.Cache) {
+		return nil, nil
+	}
+
+	cacheSettings := s.Cache
+	if cacheSettings == nil {
+		cacheSettings = &CacheSettings{}
+	}
+	ttl, err := cacheSettings.GetTTL()
+	if err != nil {
+		return nil, err
+	}
+	keyStrategy, err := cacheSettings.GetKeyStrategy()
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &queryCache{
+		ttl:         ttl,
+		keyStrategy: keyStrategy,
+		command:     strings.Join(append(append([]string{}, s.Parents...), s.Name), " "),
+		parameters:  map[string]map[string]interface{}{},
+	}
+
+	ret.connection, ret.connectionIdentity, err = getConnectionIdentity(parsedLayers)
+	if err != nil {
+		return nil, err
+	}
+
+	parsedLayers.ForEach(func(slug string, l *layers.ParsedLayer) {
+		if !nonResultLayers[slug] {
+			ret.parameters[slug] = l.Parameters.ToMap()
+		}
+	})
+
+	ret.store, err = cache.Open(cs.CacheDir)
+	if err != nil {
+		log.Warn().Err(err).Str("command", ret.command).Msg("Could not open the query cache, running the query uncached")
+		return nil, nil
+	}
+
+	return ret, nil
+}
+
+// getConnectionIdentity resolves the connection settings (including dbt profiles) into
+// a description of the connection and a value identifying the database, used in the cache key.
+func getConnectionIdentity(parsedLayers *layers.ParsedLayers) (string, interface{}, error) {
+	sqlConnectionLayer, ok := parsedLayers.Get(clay_sql.SqlConnectionSlug)
+	if !ok {
+		return "", nil, nil
+	}
+	dbtLayer, ok := parsedLayers.Get(clay_sql.DbtSlug)
+	if !ok {
+		return "", nil, nil
+	}
+
+	config, err := connection.NewConfigFromParsedLayers(sqlConnectionLayer, dbtLayer)
+	if err != nil {
+		return "", nil, err
+	}
+	description := connection.ToString(config)
+	if config.DSN != "" {
+		return description, []string{config.Driver, config.DSN}, nil
+	}
+
+	source, err := connection.GetSource(config)
+	if err != nil {
+		return "", nil, err
+	}
+	if source.Type == connection.TypeSqlite && !connection.IsInMemorySqlite(source.Database) {
+		// relative paths point to different databases depending on the working directory
+		if abs, err := filepath.Abs(source.Database); err == nil {
+			source.Database = abs
+		}
+	}
+
+	return description, source, nil
+}
+
+func (qc *queryCache) Close() error {
+	return qc.store.Close()
+}
+
+// parametersKey is the key used by the parameters strategy, which can be computed before rendering the query.
+func (qc *queryCache) parametersKey() (string, error) {
+	return cache.Key(CacheKeyParameters, qc.command, qc.connectionIdentity, qc.parameters)
+}
+
+func (qc *queryCache) queryKey(query string, args []interface{}) (string, error) {
+	return cache.Key(CacheKeyQuery, qc.connectionIdentity, query, args)
+}
+
+// replay outputs the cached rows into gp, and returns false if there is no valid cache entry for key.
+func (qc *queryCache) replay(ctx context.Context, key string, gp middlewares.Processor) (bool, error) {
+	rows, ok, err := qc.store.Get(ctx, key)
+	if err != nil {
+		// a broken cache should not prevent the query from running
+		log.Warn().Err(err).Str("command", qc.command).Msg("Could not read query cache")
+		return false, nil
+	}
+	if !ok {
+		return false, nil
+	}
+
+	log.Debug().Str("command", qc.command).Str("key", key).Int("rows", len(rows)).Msg("Using cached results")
+	for _, row := range rows {
+		err = gp.AddRow(ctx, row)
+		if err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+func (qc *queryCache) save(ctx context.Context, key string, query string, rows []types.Row) {
+	entry := &cache.Entry{
+		Key:        key,
+		Command:    qc.command,
+		Connection: qc.connection,
+		Query:      query,
+	}
+	err := qc.store.Set(ctx, entry, qc.ttl, rows)
+	if err != nil {
+		log.Warn().Err(err).Str("command", qc.command).Msg("Could not update query cache")
+	}
+}
+
+// recordingProcessor keeps a copy of the rows it forwards, so that they can be stored in the cache
+// once the query has completed successfully.
+type recordingProcessor struct {
+	middlewares.Processor
+	rows []types.Row
+}
+
+func (r *recordingProcessor) AddRow(ctx context.Context, row types.Row) error {
+	copy_ := types.NewRow()
+	for pair := row.Oldest(); pair != nil; pair = pair.Next() {
+		copy_.Set(pair.Key, pair.Value)
+	}
+	r.rows = append(r.rows, copy_)
+	return r.Processor.AddRow(ctx, row)
+}
@@ -0,0 +1,151 @@
+package cmds
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/middlewares/table"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/go-go-golems/sqleton/pkg/flags"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// countingDBFactory returns a connection factory whose databases contain the number
+// of times the factory was called, which tells apart cached and fresh results.
+func countingDBFactory(calls *int) func(*layers.ParsedLayers) (*sqlx.DB, error) {
+	return func(_ *layers.ParsedLayers) (*sqlx.DB, error) {
+		*calls++
+		db, err := sqlx.Connect("sqlite", ":memory:")
+		if err != nil {
+			return nil, err
+		}
+		_, err = db.Exec("CREATE TABLE test (name TEXT)")
+		if err != nil {
+			return nil, err
+		}
+		_, err = db.Exec("INSERT INTO test (name) VALUES (?)", fmt.Sprintf("call %d", *calls))
+		if err != nil {
+			return nil, err
+		}
+		return db, nil
+	}
+}
+
+func makeCacheLayers(t *testing.T, options ...layers.ParsedLayerOption) *layers.ParsedLayers {
+	cacheLayer, err := flags.NewSqlCacheParameterLayer()
+	require.NoError(t, err)
+	options = append([]layers.ParsedLayerOption{
+		layers.WithParsedParameterValue("cache-dir", t.TempDir()),
+	}, options...)
+	parsedCacheLayer, err := layers.NewParsedLayer(cacheLayer, options...)
+	require.NoError(t, err)
+	return layers.NewParsedLayers(layers.WithParsedLayer(flags.SqlCacheSlug, parsedCacheLayer))
+}
+
+func runIntoRows(t *testing.T, s *SqlCommand, parsedLayers *layers.ParsedLayers) []types.Row {
+	gp := middlewares.NewTableProcessor()
+	gp.AddTableMiddleware(&table.NullTableMiddleware{})
+	ctx := context.Background()
+	err := s.RunIntoGlazeProcessor(ctx, parsedLayers, gp)
+	require.NoError(t, err)
+	err = gp.Close(ctx)
+	require.NoError(t, err)
+	return gp.GetTable().Rows
+}
+
+func firstName(t *testing.T, rows []types.Row) interface{} {
+	require.Len(t, rows, 1)
+	v, _ := rows[0].Get("name")
+	return v
+}
+
+func TestCacheQueryKey(t *testing.T) {
+	calls := 0
+	s, err := NewSqlCommand(
+		cmds.NewCommandDescription("test"),
+		WithDbConnectionFactory(countingDBFactory(&calls)),
+		WithQuery("SELECT name FROM test"),
+		WithCache(&CacheSettings{TTL: "1h"}),
+	)
+	require.NoError(t, err)
+
+	parsedLayers := makeCacheLayers(t)
+	assert.Equal(t, "call 1", firstName(t, runIntoRows(t, s, parsedLayers)))
+	// the query is rendered against a new connection, but the cached rows are returned
+	assert.Equal(t, "call 1", firstName(t, runIntoRows(t, s, parsedLayers)))
+	assert.Equal(t, 2, calls)
+}
+
+func TestCacheParametersKeySkipsConnection(t *testing.T) {
+	calls := 0
+	s, err := NewSqlCommand(
+		cmds.NewCommandDescription("test"),
+		WithDbConnectionFactory(countingDBFactory(&calls)),
+		WithQuery("SELECT name FROM test"),
+		WithCache(&CacheSettings{Key: CacheKeyParameters}),
+	)
+	require.NoError(t, err)
+
+	parsedLayers := makeCacheLayers(t)
+	assert.Equal(t, "call 1", firstName(t, runIntoRows(t, s, parsedLayers)))
+	assert.Equal(t, "call 1", firstName(t, runIntoRows(t, s, parsedLayers)))
+	assert.Equal(t, 1, calls)
+}
+
+func TestCacheDisabled(t *testing.T) {
+	calls := 0
+	s, err := NewSqlCommand(
+		cmds.NewCommandDescription("test"),
+		WithDbConnectionFactory(countingDBFactory(&calls)),
+		WithQuery("SELECT name FROM test"),
+		WithCache(&CacheSettings{TTL: "1h"}),
+	)
+	require.NoError(t, err)
+
+	parsedLayers := makeCacheLayers(t, layers.WithParsedParameterValue("no-cache", true))
+	assert.Equal(t, "call 1", firstName(t, runIntoRows(t, s, parsedLayers)))
+	assert.Equal(t, "call 2", firstName(t, runIntoRows(t, s, parsedLayers)))
+
+	// without a cache block, --cache has to be passed explicitly
+	s.Cache = nil
+	parsedLayers = makeCacheLayers(t)
+	assert.Equal(t, "call 3", firstName(t, runIntoRows(t, s, parsedLayers)))
+	assert.Equal(t, "call 4", firstName(t, runIntoRows(t, s, parsedLayers)))
+
+	parsedLayers = makeCacheLayers(t, layers.WithParsedParameterValue("cache", true))
+	assert.Equal(t, "call 5", firstName(t, runIntoRows(t, s, parsedLayers)))
+	assert.Equal(t, "call 5", firstName(t, runIntoRows(t, s, parsedLayers)))
+}
+
+func TestCacheStoreUnavailable(t *testing.T) {
+	calls := 0
+	s, err := NewSqlCommand(
+		cmds.NewCommandDescription("test"),
+		WithDbConnectionFactory(countingDBFactory(&calls)),
+		WithQuery("SELECT name FROM test"),
+		WithCache(&CacheSettings{TTL: "1h"}),
+	)
+	require.NoError(t, err)
+
+	// the cache directory can't be created below a plain file
+	file := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(file, []byte{}, 0644))
+	parsedLayers := makeCacheLayers(t, layers.WithParsedParameterValue("cache-dir", filepath.Join(file, "cache")))
+	assert.Equal(t, "call 1", firstName(t, runIntoRows(t, s, parsedLayers)))
+	assert.Equal(t, "call 2", firstName(t, runIntoRows(t, s, parsedLayers)))
+}
+
+func TestCacheSettingsValidate(t *testing.T) {
+	assert.NoError(t, (&CacheSettings{}).Validate())
+	assert.NoError(t, (&CacheSettings{TTL: "90s", Key: CacheKeyParameters}).Validate())
+	assert.Error(t, (&CacheSettings{TTL: "soon"}).Validate())
+	assert.Error(t, (&CacheSettings{TTL: "-1m"}).Validate())
+	assert.Error(t, (&CacheSettings{Key: "random"}).Validate())
+}
@@ -0,0 +1,64 @@
+package cmds
+
+import (
+	"github.com/pkg/errors"
+	"sort"
+	"strings"
+)
+
+// ColumnDefinition declares a column of the results of a command, for the code generated by `sqleton codegen`,
+// which scans the rows into a struct with one field per column.
+//
+//	columns:
+//	  - name: ID
+//	    type: int64
+//	  - name: post_title
+//	    type: string
+//	    nullable: true
+//
+// Nullable columns are scanned into pointers.
+type ColumnDefinition struct {
+	Name     string `yaml:"name"`
+	Type     string `yaml:"type"`
+	Nullable bool   `yaml:"nullable,omitempty"`
+}
+
+// ColumnTypes are the Go types columns can be declared with.
+var ColumnTypes = map[string]bool{
+	"string":    true,
+	"int":       true,
+	"int32":     true,
+	"int64":     true,
+	"uint":      true,
+	"uint32":    true,
+	"uint64":    true,
+	"float32":   true,
+	"float64":   true,
+	"bool":      true,
+	"time.Time": true,
+	"[]byte":    true,
+}
+
+func ValidateColumns(columns []*ColumnDefinition) error {
+	seen := map[string]bool{}
+	for _, c := range columns {
+		if c.Name == "" {
+			return errors.New("columns must have a name")
+		}
+		if seen[c.Name] {
+			return errors.Errorf("column %s is declared twice", c.Name)
+		}
+		seen[c.Name] = true
+
+		if !ColumnTypes[c.Type] {
+			types := make([]string, 0, len(ColumnTypes))
+			for t := range ColumnTypes {
+				types = append(types, t)
+			}
+			sort.Strings(types)
+			return errors.Errorf("column %s has unsupported type %q (expected one of %s)",
+				c.Name, c.Type, strings.Join(types, ", "))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,373 @@
+package cmds
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/go-go-golems/sqleton/pkg/explain"
+	"github.com/go-go-golems/sqleton/pkg/flags"
+	"github.com/go-go-golems/sqleton/pkg/statements"
+	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-isatty"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"strings"
+)
+
+const ExecCommandType = "exec"
+
+// ExecCommand is a SqlCommand whose query modifies the database (INSERT, UPDATE, DELETE, ...).
+// The rendered statements are run inside a transaction, which is only committed if
+// --yes is passed or if the user confirms interactively. --dry-run always rolls back.
+type ExecCommand struct {
+	*SqlCommand
+}
+
+var _ cmds.GlazeCommand = (*ExecCommand)(nil)
+
+// ConfirmFunc asks the user whether to commit a transaction.
+type ConfirmFunc func(prompt string) (bool, error)
+
+func NewExecCommand(
+	description *cmds.CommandDescription,
+	options ...SqlCommandOption,
+) (*ExecCommand, error) {
+	sqlExecParameterLayer, err := flags.NewSqlExecParameterLayer()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create SQL exec parameter layer")
+	}
+	description.Layers.AppendLayers(sqlExecParameterLayer)
+
+	sqlCommand, err := NewSqlCommand(description, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExecCommand{
+		SqlCommand: sqlCommand,
+	}, nil
+}
+
+func (e *ExecCommand) String() string {
+	return fmt.Sprintf("ExecCommand{Name: %s, Parents: %s}", e.Name, strings.Join(e.Parents, " "))
+}
+
+func (e *ExecCommand) ToYAML(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	defer func(enc *yaml.Encoder) {
+		_ = enc.Close()
+	}(enc)
+
+	return enc.Encode(struct {
+		Type        string `yaml:"type"`
+		*SqlCommand `yaml:",inline"`
+	}{
+		Type:       ExecCommandType,
+		SqlCommand: e.SqlCommand,
+	})
+}
+
+func (e *ExecCommand) RunIntoGlazeProcessor(
+	ctx context.Context,
+	parsedLayers *layers.ParsedLayers,
+	gp middlewares.Processor,
+) error {
+	if e.dbConnectionFactory == nil {
+		return errors.New("dbConnectionFactory is not set")
+	}
+
+	db, err := e.dbConnectionFactory(parsedLayers)
+	if err != nil {
+		return err
+	}
+	defer func(db *sqlx.DB) {
+		_ = db.Close()
+	}(db)
+
+	err = db.PingContext(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "Could not ping database")
+	}
+
+	dataMap := parsedLayers.GetDataMap()
+
+	printQuery := false
+	if printQuery_, ok := parsedLayers.GetParameter(flags.SqlHelpersSlug, "print-query"); ok {
+		printQuery = printQuery_.Value.(bool)
+	}
+	if printQuery {
+		return e.PrintQuery(ctx, db, dataMap, nil)
+	}
+
+	es := &flags.SqlExecSettings{}
+	if _, ok := parsedLayers.Get(flags.SqlExecSlug); ok {
+		err = parsedLayers.InitializeStruct(flags.SqlExecSlug, es)
+		if err != nil {
+			return errors.Wrap(err, "could not initialize sql-exec settings")
+		}
+	}
+
+	explainer, err := GetExplainer(parsedLayers, db.DriverName())
+	if err != nil {
+		return err
+	}
+
+	timeout, err := GetTimeout(parsedLayers, e.Timeout)
+	if err != nil {
+		return err
+	}
+	connect := func() (*sqlx.DB, error) {
+		return e.dbConnectionFactory(parsedLayers)
+	}
+
+	runWithTimeout := func(ctx context.Context, f func(ctx context.Context) error) error {
+		return RunWithTimeout(ctx, db, timeout, connect, f)
+	}
+	if explainer != nil {
+		return runWithTimeout(ctx, func(ctx context.Context) error {
+			return e.ExplainExecIntoGlazeProcessorWithDB(ctx, db, dataMap, explainer, gp)
+		})
+	}
+	// the timeout only applies to the statements, not to the confirmation prompt
+	return e.execIntoGlazeProcessor(ctx, db, dataMap, es, confirmFromStdin, gp, runWithTimeout)
+}
+
+// ExecIntoGlazeProcessorWithDB renders the statements and runs them one by one in a transaction.
+// A single row reporting the number of affected rows and whether the transaction
+// was committed is emitted into gp.
+func (e *ExecCommand) ExecIntoGlazeProcessorWithDB(
+	ctx context.Context,
+	db *sqlx.DB,
+	dataMap map[string]interface{},
+	es *flags.SqlExecSettings,
+	confirm ConfirmFunc,
+	gp middlewares.Processor,
+) error {
+	run := func(ctx context.Context, f func(ctx context.Context) error) error {
+		return f(ctx)
+	}
+	return e.execIntoGlazeProcessor(ctx, db, dataMap, es, confirm, gp, run)
+}
+
+// execIntoGlazeProcessor is ExecIntoGlazeProcessorWithDB, running the statements through run
+// (see RunWithTimeout). The confirmation and the commit happen after run has returned.
+func (e *ExecCommand) execIntoGlazeProcessor(
+	ctx context.Context,
+	db *sqlx.DB,
+	dataMap map[string]interface{},
+	es *flags.SqlExecSettings,
+	confirm ConfirmFunc,
+	gp middlewares.Processor,
+	run func(ctx context.Context, f func(ctx context.Context) error) error,
+) error {
+	var tx *sqlx.Tx
+	committed := false
+	defer func() {
+		if tx != nil && !committed {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var statements_ []string
+	var rowsAffected int64
+	var lastInsertId interface{}
+	err := run(ctx, func(statementsCtx context.Context) error {
+		var err error
+		e.renderedQuery, e.renderedArgs, err = e.RenderQueryWithArgs(statementsCtx, db, dataMap)
+		if err != nil {
+			return errors.Wrapf(err, "Could not generate query")
+		}
+
+		statements_, err = e.splitStatements(db)
+		if err != nil {
+			return err
+		}
+
+		// the transaction is bound to ctx, so that it outlives the context of the statements
+		tx, err = db.BeginTxx(ctx, nil)
+		if err != nil {
+			return errors.Wrap(err, "Could not start transaction")
+		}
+
+		for _, statement := range statements_ {
+			res, err := tx.ExecContext(statementsCtx, statement, e.renderedArgs...)
+			if err != nil {
+				return errors.Wrapf(err, "Could not execute query: %s", statement)
+			}
+			n, err := res.RowsAffected()
+			if err != nil {
+				return errors.Wrap(err, "Could not get number of affected rows")
+			}
+			rowsAffected += n
+			if id, err := res.LastInsertId(); err == nil {
+				lastInsertId = id
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	row := types.NewRow(
+		types.MRP("statements", len(statements_)),
+		types.MRP("rows_affected", rowsAffected),
+	)
+	if lastInsertId != nil {
+		row.Set("last_insert_id", lastInsertId)
+	}
+
+	if !es.DryRun {
+		ok := es.Yes
+		if !ok {
+			ok, err = confirm(fmt.Sprintf("Commit transaction (%d rows affected)?", rowsAffected))
+			if err != nil {
+				return err
+			}
+		}
+
+		if ok {
+			err = tx.Commit()
+			if err != nil {
+				return errors.Wrap(err, "Could not commit transaction")
+			}
+			committed = true
+		}
+	}
+
+	row.Set("dry_run", es.DryRun)
+	row.Set("committed", committed)
+
+	return gp.AddRow(ctx, row)
+}
+
+// splitStatements splits the rendered query into the statements to execute.
+func (e *ExecCommand) splitStatements(db *sqlx.DB) ([]string, error) {
+	// bound arguments can't be distributed over multiple statements,
+	// so a query with bound arguments is always executed as a single statement
+	if len(e.renderedArgs) > 0 {
+		return []string{e.renderedQuery}, nil
+	}
+	ret, err := statements.Split(e.renderedQuery, statements.DriverOptions(db.DriverName())...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not split query into statements")
+	}
+	return ret, nil
+}
+
+// ExplainExecIntoGlazeProcessorWithDB outputs the plan of each statement.
+// The statements are explained in a transaction that is always rolled back,
+// so that EXPLAIN ANALYZE (which executes them) leaves the database unchanged.
+func (e *ExecCommand) ExplainExecIntoGlazeProcessorWithDB(
+	ctx context.Context,
+	db *sqlx.DB,
+	dataMap map[string]interface{},
+	explainer *explain.Explainer,
+	gp middlewares.Processor,
+) error {
+	var err error
+	e.renderedQuery, e.renderedArgs, err = e.RenderQueryWithArgs(ctx, db, explainDataMap(dataMap))
+	if err != nil {
+		return errors.Wrapf(err, "Could not generate query")
+	}
+
+	statements_, err := e.splitStatements(db)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "Could not start transaction")
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	for _, statement := range statements_ {
+		err = explainer.Explain(ctx, tx, statement, e.renderedArgs, gp)
+		if err != nil {
+			return errors.Wrapf(err, "Could not explain statement: %s", statement)
+		}
+	}
+
+	return nil
+}
+
+// ExplainNodes returns the flattened plans of the statements, like SqlCommand.ExplainNodes.
+// The statements are explained in a transaction that is always rolled back,
+// so that EXPLAIN ANALYZE (which executes them) leaves the database unchanged.
+func (e *ExecCommand) ExplainNodes(
+	ctx context.Context,
+	parsedLayers *layers.ParsedLayers,
+	analyze bool,
+) ([]*explain.Node, error) {
+	if e.dbConnectionFactory == nil {
+		return nil, errors.New("dbConnectionFactory is not set")
+	}
+
+	db, err := e.dbConnectionFactory(parsedLayers)
+	if err != nil {
+		return nil, err
+	}
+	defer func(db *sqlx.DB) {
+		_ = db.Close()
+	}(db)
+
+	explainer, err := explain.NewExplainer(db.DriverName(), explain.FormatNodes, analyze)
+	if err != nil {
+		return nil, err
+	}
+
+	e.renderedQuery, e.renderedArgs, err = e.RenderQueryWithArgs(ctx, db, explainDataMap(parsedLayers.GetDataMap()))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Could not generate query")
+	}
+
+	statements_, err := e.splitStatements(db)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not start transaction")
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	ret := []*explain.Node{}
+	for _, statement := range statements_ {
+		nodes, err := explainer.Nodes(ctx, tx, statement, e.renderedArgs)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Could not explain statement: %s", statement)
+		}
+		ret = append(ret, nodes...)
+	}
+
+	return ret, nil
+}
+
+// confirmFromStdin asks for confirmation on the terminal.
+// It refuses to commit if stdin is not a terminal.
+func confirmFromStdin(prompt string) (bool, error) {
+	if !isatty.IsTerminal(os.Stdin.Fd()) && !isatty.IsCygwinTerminal(os.Stdin.Fd()) {
+		return false, errors.New("stdin is not a terminal, pass --yes to commit or --dry-run to roll back")
+	}
+
+	_, _ = fmt.Fprintf(os.Stderr, "%s [y/N] ", prompt)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, errors.Wrap(err, "could not read confirmation")
+	}
+
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes", nil
+}
@@ -0,0 +1,225 @@
+package cmds
+
+import (
+	"context"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/alias"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/middlewares/table"
+	"github.com/go-go-golems/sqleton/pkg/flags"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func runExecCommand(
+	t *testing.T,
+	db *sqlx.DB,
+	es *flags.SqlExecSettings,
+	confirm ConfirmFunc,
+) map[string]interface{} {
+	e, err := NewExecCommand(
+		cmds.NewCommandDescription("test"),
+		WithDbConnectionFactory(createDB),
+		WithQuery(`UPDATE test SET name = {{ .name | sqlString }} WHERE id <= 2`),
+		WithParameterized(true),
+	)
+	require.NoError(t, err)
+
+	gp := middlewares.NewTableProcessor()
+	gp.AddTableMiddleware(&table.NullTableMiddleware{})
+	ctx := context.Background()
+	err = e.ExecIntoGlazeProcessorWithDB(ctx, db, map[string]interface{}{"name": "updated"}, es, confirm, gp)
+	require.NoError(t, err)
+	require.NoError(t, gp.Close(ctx))
+
+	rows := gp.GetTable().Rows
+	require.Len(t, rows, 1)
+	ret := map[string]interface{}{}
+	for pair := rows[0].Oldest(); pair != nil; pair = pair.Next() {
+		ret[pair.Key] = pair.Value
+	}
+	return ret
+}
+
+func countUpdated(t *testing.T, db *sqlx.DB) int {
+	var count int
+	err := db.Get(&count, "SELECT COUNT(*) FROM test WHERE name = 'updated'")
+	require.NoError(t, err)
+	return count
+}
+
+func TestExecCommandDryRun(t *testing.T) {
+	db, err := createDB(nil)
+	require.NoError(t, err)
+	defer func(db *sqlx.DB) {
+		_ = db.Close()
+	}(db)
+	// the in memory database only lives as long as its single connection
+	db.SetMaxOpenConns(1)
+
+	row := runExecCommand(t, db, &flags.SqlExecSettings{DryRun: true}, func(string) (bool, error) {
+		t.Fatal("dry-run should not ask for confirmation")
+		return false, nil
+	})
+	assert.Equal(t, int64(2), row["rows_affected"])
+	assert.Equal(t, false, row["committed"])
+	assert.Equal(t, 0, countUpdated(t, db))
+}
+
+func TestExecCommandConfirm(t *testing.T) {
+	db, err := createDB(nil)
+	require.NoError(t, err)
+	defer func(db *sqlx.DB) {
+		_ = db.Close()
+	}(db)
+	db.SetMaxOpenConns(1)
+
+	row := runExecCommand(t, db, &flags.SqlExecSettings{}, func(string) (bool, error) {
+		return false, nil
+	})
+	assert.Equal(t, false, row["committed"])
+	assert.Equal(t, 0, countUpdated(t, db))
+
+	row = runExecCommand(t, db, &flags.SqlExecSettings{}, func(string) (bool, error) {
+		return true, nil
+	})
+	assert.Equal(t, true, row["committed"])
+	assert.Equal(t, 2, countUpdated(t, db))
+}
+
+func TestExecCommandYes(t *testing.T) {
+	db, err := createDB(nil)
+	require.NoError(t, err)
+	defer func(db *sqlx.DB) {
+		_ = db.Close()
+	}(db)
+	db.SetMaxOpenConns(1)
+
+	row := runExecCommand(t, db, &flags.SqlExecSettings{Yes: true}, func(string) (bool, error) {
+		t.Fatal("--yes should not ask for confirmation")
+		return false, nil
+	})
+	assert.Equal(t, true, row["committed"])
+	assert.Equal(t, 2, countUpdated(t, db))
+}
+
+func TestExecCommandConfirmAfterTimeout(t *testing.T) {
+	db, err := createDB(nil)
+	require.NoError(t, err)
+	defer func(db *sqlx.DB) {
+		_ = db.Close()
+	}(db)
+	db.SetMaxOpenConns(1)
+
+	e, err := NewExecCommand(
+		cmds.NewCommandDescription("test"),
+		WithQuery(`UPDATE test SET name = 'updated' WHERE id <= 2`),
+	)
+	require.NoError(t, err)
+
+	// answering the prompt takes longer than the timeout, which only applies to the statements
+	timeout := 10 * time.Millisecond
+	confirm := func(string) (bool, error) {
+		time.Sleep(5 * timeout)
+		return true, nil
+	}
+	run := func(ctx context.Context, f func(ctx context.Context) error) error {
+		return RunWithTimeout(ctx, db, timeout, nil, f)
+	}
+
+	gp := middlewares.NewTableProcessor()
+	gp.AddTableMiddleware(&table.NullTableMiddleware{})
+	ctx := context.Background()
+	err = e.execIntoGlazeProcessor(ctx, db, map[string]interface{}{}, &flags.SqlExecSettings{}, confirm, gp, run)
+	require.NoError(t, err)
+	require.NoError(t, gp.Close(ctx))
+
+	committed, _ := gp.GetTable().Rows[0].Get("committed")
+	assert.Equal(t, true, committed)
+	assert.Equal(t, 2, countUpdated(t, db))
+}
+
+func TestLoadExecCommand(t *testing.T) {
+	loader := &SqlCommandLoader{}
+	cmds_, err := loader.loadSqlCommandFromReader(strings.NewReader(`
+name: update
+short: Update things
+type: exec
+query: UPDATE test SET name = 'foo'
+`), []cmds.CommandDescriptionOption{}, []alias.Option{})
+	require.NoError(t, err)
+	require.Len(t, cmds_, 1)
+	_, ok := cmds_[0].(*ExecCommand)
+	assert.True(t, ok)
+
+	_, err = loader.loadSqlCommandFromReader(strings.NewReader(`
+name: update
+short: Update things
+type: unknown
+query: UPDATE test SET name = 'foo'
+`), []cmds.CommandDescriptionOption{}, []alias.Option{})
+	assert.Error(t, err)
+}
+
+func TestExecCommandMultipleStatements(t *testing.T) {
+	db, err := createDB(nil)
+	require.NoError(t, err)
+	defer func(db *sqlx.DB) {
+		_ = db.Close()
+	}(db)
+	db.SetMaxOpenConns(1)
+
+	e, err := NewExecCommand(
+		cmds.NewCommandDescription("test"),
+		WithQuery(`
+INSERT INTO test (id, name) VALUES (4, 'updated');
+-- a comment; with a semicolon
+UPDATE test SET name = 'updated' WHERE id = 1;
+`),
+	)
+	require.NoError(t, err)
+
+	gp := middlewares.NewTableProcessor()
+	gp.AddTableMiddleware(&table.NullTableMiddleware{})
+	ctx := context.Background()
+	err = e.ExecIntoGlazeProcessorWithDB(ctx, db, map[string]interface{}{}, &flags.SqlExecSettings{Yes: true}, nil, gp)
+	require.NoError(t, err)
+	require.NoError(t, gp.Close(ctx))
+
+	rows := gp.GetTable().Rows
+	require.Len(t, rows, 1)
+	statements_, _ := rows[0].Get("statements")
+	assert.Equal(t, 2, statements_)
+	rowsAffected, _ := rows[0].Get("rows_affected")
+	assert.Equal(t, int64(2), rowsAffected)
+	assert.Equal(t, 2, countUpdated(t, db))
+}
+
+func TestServedRepositoryLeavesOutExecCommands(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "upd.yaml"), []byte(`name: upd
+short: Update posts
+type: exec
+query: UPDATE posts SET title = 'pwned'
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ls.yaml"), []byte(`name: ls
+short: List posts
+query: SELECT * FROM posts
+`), 0644))
+
+	r, err := NewRepositoryFactory(NewReadOnlyLoader)([]string{dir})
+	require.NoError(t, err)
+
+	// the exec command can't be reached, and thus can't commit, from a request
+	names := []string{}
+	for _, c := range r.CollectCommands([]string{}, true) {
+		names = append(names, c.Description().Name)
+	}
+	assert.Equal(t, []string{"ls"}, names)
+}
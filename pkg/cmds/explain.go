@@ -0,0 +1,108 @@
+package cmds
+
+import (
+	"context"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/sqleton/pkg/connection"
+	"github.com/go-go-golems/sqleton/pkg/explain"
+	"github.com/go-go-golems/sqleton/pkg/flags"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// IsExplain returns true if --explain was passed.
+func IsExplain(parsedLayers *layers.ParsedLayers) bool {
+	explain_, ok := parsedLayers.GetParameter(flags.SqlHelpersSlug, "explain")
+	if !ok {
+		return false
+	}
+	ret, _ := explain_.Value.(bool)
+	return ret
+}
+
+// GetExplainer returns the explainer configured by the sql-helpers flags for the driver driverName,
+// or nil if --explain was not passed.
+func GetExplainer(parsedLayers *layers.ParsedLayers, driverName string) (*explain.Explainer, error) {
+	if !IsExplain(parsedLayers) {
+		return nil, nil
+	}
+
+	ss := &flags.SqlHelpersSettings{}
+	err := parsedLayers.InitializeStruct(flags.SqlHelpersSlug, ss)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not initialize sql-helpers settings")
+	}
+
+	// MySQL only supports EXPLAIN ANALYZE in the tree format, which is used unless another format was asked for
+	format := ss.ExplainFormat
+	if ss.ExplainAnalyze && connection.NormalizeType(driverName) == connection.TypeMySQL {
+		if layer, ok := parsedLayers.Get(flags.SqlHelpersSlug); ok {
+			if _, ok := connection.ExplicitValues(layer)["explain-format"]; !ok {
+				format = explain.FormatTree
+			}
+		}
+	}
+
+	return explain.NewExplainer(driverName, format, ss.ExplainAnalyze)
+}
+
+// explainDataMap returns the template data of a query being explained. The explainer prepends EXPLAIN
+// itself, so explain is set to false for the templates still doing it with {{ if .explain }}.
+func explainDataMap(dataMap map[string]interface{}) map[string]interface{} {
+	ret := make(map[string]interface{}, len(dataMap)+1)
+	for k, v := range dataMap {
+		ret[k] = v
+	}
+	ret["explain"] = false
+	return ret
+}
+
+// ExplainIntoGlazeProcessorWithDB renders the query and outputs its plan instead of its rows.
+func (s *SqlCommand) ExplainIntoGlazeProcessorWithDB(
+	ctx context.Context,
+	db *sqlx.DB,
+	dataMap map[string]interface{},
+	explainer *explain.Explainer,
+	gp middlewares.Processor,
+) error {
+	var err error
+	s.renderedQuery, s.renderedArgs, err = s.RenderQueryWithArgs(ctx, db, explainDataMap(dataMap))
+	if err != nil {
+		return errors.Wrapf(err, "Could not generate query")
+	}
+
+	return explainer.Explain(ctx, db, s.renderedQuery, s.renderedArgs, gp)
+}
+
+// ExplainNodes connects to the database configured in parsedLayers, renders the query
+// with the parameters of parsedLayers and returns its flattened plan.
+func (s *SqlCommand) ExplainNodes(
+	ctx context.Context,
+	parsedLayers *layers.ParsedLayers,
+	analyze bool,
+) ([]*explain.Node, error) {
+	if s.dbConnectionFactory == nil {
+		return nil, errors.New("dbConnectionFactory is not set")
+	}
+
+	db, err := s.dbConnectionFactory(parsedLayers)
+	if err != nil {
+		return nil, err
+	}
+	defer func(db *sqlx.DB) {
+		_ = db.Close()
+	}(db)
+
+	explainer, err := explain.NewExplainer(db.DriverName(), explain.FormatNodes, analyze)
+	if err != nil {
+		return nil, err
+	}
+
+	s.renderedQuery, s.renderedArgs, err = s.RenderQueryWithArgs(ctx, db, explainDataMap(parsedLayers.GetDataMap()))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Could not generate query")
+	}
+
+	return explainer.Nodes(ctx, db, s.renderedQuery, s.renderedArgs)
+}
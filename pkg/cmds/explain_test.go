@@ -0,0 +1,150 @@
+package cmds
+
+import (
+	"context"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/alias"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	glazed_middlewares "github.com/go-go-golems/glazed/pkg/cmds/middlewares"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/middlewares/table"
+	"github.com/go-go-golems/sqleton/pkg/explain"
+	"github.com/go-go-golems/sqleton/pkg/flags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+func TestSqlCommandExplain(t *testing.T) {
+	s, err := NewSqlCommand(
+		cmds.NewCommandDescription("test"),
+		WithDbConnectionFactory(createDB),
+		WithQuery("SELECT * FROM test JOIN test2 ON test2.test_id = test.id WHERE test.id = 1"),
+	)
+	require.NoError(t, err)
+
+	parsedLayers := makeHelpersLayers(t, layers.WithParsedParameterValue("explain", true))
+	rows := runIntoRows(t, s, parsedLayers)
+	require.Len(t, rows, 2)
+	for _, row := range rows {
+		operation, ok := row.Get("operation")
+		require.True(t, ok)
+		assert.Contains(t, []interface{}{"SCAN", "SEARCH"}, operation)
+	}
+}
+
+func TestSqlCommandExplainTemplateWithExplain(t *testing.T) {
+	// the explainer prepends EXPLAIN, templates doing it themselves must not render it twice
+	s, err := NewSqlCommand(
+		cmds.NewCommandDescription("test"),
+		WithDbConnectionFactory(createDB),
+		WithQuery("{{ if .explain }}EXPLAIN{{ end }} SELECT * FROM test"),
+	)
+	require.NoError(t, err)
+
+	parsedLayers := makeHelpersLayers(t, layers.WithParsedParameterValue("explain", true))
+	rows := runIntoRows(t, s, parsedLayers)
+	require.Len(t, rows, 1)
+}
+
+func TestEmbeddedQueryExplain(t *testing.T) {
+	loader := &SqlCommandLoader{DBConnectionFactory: createDB}
+	commands, err := loader.LoadCommands(os.DirFS("../../cmd/sqleton/queries"), "sqlite/tables.yaml",
+		[]cmds.CommandDescriptionOption{}, []alias.Option{})
+	require.NoError(t, err)
+	require.Len(t, commands, 1)
+	s, ok := commands[0].(*SqlCommand)
+	require.True(t, ok)
+
+	parsedLayers := layers.NewParsedLayers()
+	err = glazed_middlewares.ExecuteMiddlewares(s.Description().Layers, parsedLayers,
+		glazed_middlewares.UpdateFromMap(map[string]map[string]interface{}{
+			flags.SqlHelpersSlug: {"explain": true},
+		}),
+		glazed_middlewares.SetFromDefaults(),
+	)
+	require.NoError(t, err)
+
+	rows := runIntoRows(t, s, parsedLayers)
+	require.NotEmpty(t, rows)
+	_, ok = rows[0].Get("operation")
+	assert.True(t, ok)
+}
+
+func TestExecCommandExplainRollsBack(t *testing.T) {
+	db, err := createDB(nil)
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+
+	e, err := NewExecCommand(
+		cmds.NewCommandDescription("test"),
+		WithDbConnectionFactory(createDB),
+		WithQuery(`UPDATE test SET name = 'updated' WHERE id = 1; DELETE FROM test2 WHERE test_id = 1`),
+	)
+	require.NoError(t, err)
+
+	explainer, err := explain.NewExplainer(db.DriverName(), explain.FormatNodes, false)
+	require.NoError(t, err)
+
+	gp := middlewares.NewTableProcessor()
+	gp.AddTableMiddleware(&table.NullTableMiddleware{})
+	ctx := context.Background()
+	err = e.ExplainExecIntoGlazeProcessorWithDB(ctx, db, map[string]interface{}{}, explainer, gp)
+	require.NoError(t, err)
+	require.NoError(t, gp.Close(ctx))
+
+	assert.Len(t, gp.GetTable().Rows, 2)
+	assert.Equal(t, 0, countUpdated(t, db))
+}
+
+func TestExecCommandExplainNodes(t *testing.T) {
+	e, err := NewExecCommand(
+		cmds.NewCommandDescription("test"),
+		WithDbConnectionFactory(createDB),
+		WithQuery(`UPDATE test SET name = 'updated' WHERE id = 1; DELETE FROM test2 WHERE test_id = 1`),
+	)
+	require.NoError(t, err)
+
+	// each statement is explained on its own
+	nodes, err := e.ExplainNodes(context.Background(), layers.NewParsedLayers(), false)
+	require.NoError(t, err)
+	require.Len(t, nodes, 2)
+	assert.Equal(t, "test", nodes[0].Table)
+	assert.Equal(t, "test2", nodes[1].Table)
+}
+
+func TestGetExplainerMySQLAnalyzeUsesTree(t *testing.T) {
+	parseHelpers := func(values map[string]interface{}) *layers.ParsedLayers {
+		helpersLayer, err := flags.NewSqlHelpersParameterLayer()
+		require.NoError(t, err)
+		parsedLayers := layers.NewParsedLayers()
+		err = glazed_middlewares.ExecuteMiddlewares(layers.NewParameterLayers(layers.WithLayers(helpersLayer)), parsedLayers,
+			glazed_middlewares.UpdateFromMap(map[string]map[string]interface{}{flags.SqlHelpersSlug: values}),
+			glazed_middlewares.SetFromDefaults(parameters.WithParseStepSource(parameters.SourceDefaults)),
+		)
+		require.NoError(t, err)
+		return parsedLayers
+	}
+
+	explainer, err := GetExplainer(parseHelpers(map[string]interface{}{
+		"explain": true, "explain-analyze": true,
+	}), "mysql")
+	require.NoError(t, err)
+	assert.Equal(t, explain.FormatTree, explainer.Format())
+
+	// an explicit format other than tree is rejected
+	_, err = GetExplainer(parseHelpers(map[string]interface{}{
+		"explain": true, "explain-analyze": true, "explain-format": "nodes",
+	}), "mysql")
+	assert.Error(t, err)
+
+	explainer, err = GetExplainer(parseHelpers(map[string]interface{}{
+		"explain": true, "explain-analyze": true,
+	}), "postgres")
+	require.NoError(t, err)
+	assert.Equal(t, explain.FormatNodes, explainer.Format())
+}
@@ -1,14 +1,61 @@
 package cmds
 
 import (
-	"github.com/go-go-golems/clay/pkg/sql"
+	glazed_cmds "github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/alias"
+	"github.com/go-go-golems/glazed/pkg/cmds/loaders"
 	"github.com/go-go-golems/parka/pkg/handlers"
+	"github.com/go-go-golems/sqleton/pkg/connection"
+	"github.com/rs/zerolog/log"
+	"io/fs"
 )
 
-func NewRepositoryFactory() handlers.RepositoryFactory {
-	loader := &SqlCommandLoader{
-		DBConnectionFactory: sql.OpenDatabaseFromDefaultSqlConnectionLayer,
+// LoaderWrapper wraps the command loader of a repository, for example to check the loaded commands.
+type LoaderWrapper func(loader loaders.CommandLoader) loaders.CommandLoader
+
+func NewRepositoryFactory(wrappers ...LoaderWrapper) handlers.RepositoryFactory {
+	var loader loaders.CommandLoader = &SqlCommandLoader{
+		DBConnectionFactory: connection.OpenDatabaseFromDefaultSqlConnectionLayer,
+	}
+	for _, wrapper := range wrappers {
+		loader = wrapper(loader)
 	}
 
 	return handlers.NewRepositoryFactoryFromReaderLoaders(loader)
 }
+
+// ReadOnlyLoader wraps a command loader, and leaves out the exec commands it loads.
+// Served commands are run from plain GET requests, which can't stand in for the
+// confirmation required before committing (a link with ?yes=true can be followed by a crawler).
+type ReadOnlyLoader struct {
+	loaders.CommandLoader
+}
+
+var _ loaders.CommandLoader = (*ReadOnlyLoader)(nil)
+
+// NewReadOnlyLoader wraps loader in a ReadOnlyLoader, it can be passed to NewRepositoryFactory.
+func NewReadOnlyLoader(loader loaders.CommandLoader) loaders.CommandLoader {
+	return &ReadOnlyLoader{CommandLoader: loader}
+}
+
+func (l *ReadOnlyLoader) LoadCommands(
+	f fs.FS, entryName string,
+	options []glazed_cmds.CommandDescriptionOption,
+	aliasOptions []alias.Option,
+) ([]glazed_cmds.Command, error) {
+	commands, err := l.CommandLoader.LoadCommands(f, entryName, options, aliasOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []glazed_cmds.Command{}
+	for _, command := range commands {
+		if _, ok := command.(*ExecCommand); ok {
+			log.Warn().Str("file", entryName).Str("name", command.Description().Name).
+				Msg("Not serving exec command")
+			continue
+		}
+		ret = append(ret, command)
+	}
+	return ret, nil
+}
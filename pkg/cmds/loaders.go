@@ -1,11 +1,13 @@
 package cmds
 
 import (
+	_ "embed"
 	"github.com/go-go-golems/clay/pkg/sql"
 	"github.com/go-go-golems/glazed/pkg/cmds"
 	"github.com/go-go-golems/glazed/pkg/cmds/alias"
 	"github.com/go-go-golems/glazed/pkg/cmds/layout"
 	"github.com/go-go-golems/glazed/pkg/cmds/loaders"
+	"github.com/go-go-golems/sqleton/pkg/flags"
 	"github.com/pkg/errors"
 	"gopkg.in/yaml.v3"
 	"io"
@@ -13,6 +15,12 @@ import (
 	"strings"
 )
 
+// CommandJSONSchema is the JSON Schema of the YAML command files, which editors can use
+// to validate and complete them (see `sqleton lint --print-schema`).
+//
+//go:embed command.schema.json
+var CommandJSONSchema []byte
+
 type SqlCommandLoader struct {
 	DBConnectionFactory sql.DBConnectionFactory
 }
@@ -24,6 +32,10 @@ func (scl *SqlCommandLoader) LoadCommands(
 	options []cmds.CommandDescriptionOption,
 	aliasOptions []alias.Option,
 ) ([]cmds.Command, error) {
+	if IsSqlFileName(entryName) {
+		return scl.loadSqlCommandFromSqlFile(f, entryName, options)
+	}
+
 	r, err := f.Open(entryName)
 	if err != nil {
 		return nil, err
@@ -34,22 +46,117 @@ func (scl *SqlCommandLoader) LoadCommands(
 
 	return loaders.LoadCommandOrAliasFromReader(
 		r,
-		scl.loadSqlCommandFromReader,
+		func(r io.Reader, options []cmds.CommandDescriptionOption, _ []alias.Option) ([]cmds.Command, error) {
+			scd, err := decodeSqlCommandDescription(r)
+			if err != nil {
+				return nil, err
+			}
+			return scl.newCommandFromDescription(f, entryName, scd, options)
+		},
 		options,
 		aliasOptions)
 }
 
+// IsFileSupported accepts YAML files, and .sql files starting with front matter (see SqlFile).
+// Other .sql files are plain scripts, which are run with `sqleton run`.
+// Files in partials and layers directories are not commands (see PartialDescription and LayersDirectory).
 func (scl *SqlCommandLoader) IsFileSupported(f fs.FS, fileName string) bool {
+	if IsPartialFileName(fileName) || IsLayerFileName(fileName) {
+		return false
+	}
+	if IsSqlFileName(fileName) {
+		content, err := fs.ReadFile(f, fileName)
+		if err != nil {
+			return false
+		}
+		// a malformed front matter is reported when loading the file
+		sf, err := ParseSqlFile(string(content))
+		return sf != nil || err != nil
+	}
 	return strings.HasSuffix(fileName, ".yaml") || strings.HasSuffix(fileName, ".yml")
 }
 
+func (scl *SqlCommandLoader) loadSqlCommandFromSqlFile(
+	f fs.FS, fileName string,
+	options []cmds.CommandDescriptionOption,
+) ([]cmds.Command, error) {
+	content, err := fs.ReadFile(f, fileName)
+	if err != nil {
+		return nil, err
+	}
+	return scl.loadSqlCommandFromSqlContent(f, fileName, string(content), options)
+}
+
+// loadSqlCommandFromSqlContent creates the command described by the front matter of content,
+// which was read from fileName in f. f can be nil if the command doesn't include any partials.
+func (scl *SqlCommandLoader) loadSqlCommandFromSqlContent(
+	f fs.FS, fileName string,
+	content string,
+	options []cmds.CommandDescriptionOption,
+) ([]cmds.Command, error) {
+	sf, err := ParseSqlFile(content)
+	if err != nil {
+		return nil, err
+	}
+	if sf == nil {
+		return nil, errors.Errorf("%s doesn't start with a front matter describing the command", fileName)
+	}
+
+	scd := &SqlCommandDescription{}
+	decoder := yaml.NewDecoder(strings.NewReader(sf.FrontMatter))
+	decoder.KnownFields(true)
+	err = decoder.Decode(scd)
+	if err != nil {
+		if err == io.EOF {
+			return nil, errors.Errorf("the front matter of %s is empty", fileName)
+		}
+		return nil, err
+	}
+	if scd.Query != "" {
+		return nil, errors.Errorf("command %s: the query of a .sql file is the content following its front matter", scd.Name)
+	}
+	scd.Query = sf.Query
+
+	return scl.newCommandFromDescription(f, fileName, scd, options)
+}
+
 func (scl *SqlCommandLoader) loadSqlCommandFromReader(
 	s io.Reader,
 	options []cmds.CommandDescriptionOption,
 	_ []alias.Option,
 ) ([]cmds.Command, error) {
+	scd, err := decodeSqlCommandDescription(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return scl.newCommandFromDescription(nil, "", scd, options)
+}
+
+func decodeSqlCommandDescription(s io.Reader) (*SqlCommandDescription, error) {
 	scd := &SqlCommandDescription{}
-	err := yaml.NewDecoder(s).Decode(scd)
+	decoder := yaml.NewDecoder(s)
+	// unknown keys are most likely typos (descripton:, subquery:, ...), which would otherwise go unnoticed
+	decoder.KnownFields(true)
+	err := decoder.Decode(scd)
+	if err != nil {
+		return nil, err
+	}
+	return scd, nil
+}
+
+// newCommandFromDescription creates the command described by scd, which was loaded from fileName in f.
+// f is used to resolve the partials included by the command, and can be nil if it doesn't include any.
+func (scl *SqlCommandLoader) newCommandFromDescription(
+	f fs.FS, fileName string,
+	scd *SqlCommandDescription,
+	options []cmds.CommandDescriptionOption,
+) ([]cmds.Command, error) {
+	flags_, partials, err := resolveIncludes(f, fileName, scd)
+	if err != nil {
+		return nil, err
+	}
+	useLayers, err := resolveUseLayers(f, fileName, scd)
 	if err != nil {
 		return nil, err
 	}
@@ -57,34 +164,99 @@ func (scl *SqlCommandLoader) loadSqlCommandFromReader(
 	options_ := []cmds.CommandDescriptionOption{
 		cmds.WithShort(scd.Short),
 		cmds.WithLong(scd.Long),
-		cmds.WithFlags(scd.Flags...),
+		cmds.WithFlags(flags_...),
 		cmds.WithArguments(scd.Arguments...),
 		cmds.WithLayersList(scd.Layers...),
+		cmds.WithLayersList(useLayers...),
 		cmds.WithLayout(&layout.Layout{
 			Sections: scd.Layout,
 		}),
 	}
 	options_ = append(options_, options...)
 
-	sq, err := NewSqlCommand(
-		cmds.NewCommandDescription(
-			scd.Name,
-		),
+	description := cmds.NewCommandDescription(
+		scd.Name,
+	)
+	sqlCommandOptions := []SqlCommandOption{
 		WithDbConnectionFactory(scl.DBConnectionFactory),
 		WithQuery(scd.Query),
 		WithSubQueries(scd.SubQueries),
-	)
+		WithPartials(scd.Includes, partials),
+		WithParameterized(scd.Parameterized),
+		WithCache(scd.Cache),
+		WithTimeout(scd.Timeout),
+		WithPagination(scd.Pagination),
+		WithColumns(scd.Columns),
+	}
+
+	_, err = ParseTimeout(scd.Timeout)
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrapf(err, "invalid timeout for command %s", scd.Name)
+	}
+
+	if scd.Cache != nil {
+		err = scd.Cache.Validate()
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid cache settings for command %s", scd.Name)
+		}
+	}
+
+	if scd.Pagination != nil {
+		err = scd.Pagination.Validate()
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid pagination settings for command %s", scd.Name)
+		}
+	}
+
+	err = ValidateColumns(scd.Columns)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid columns for command %s", scd.Name)
+	}
+
+	var command cmds.Command
+	var sq *SqlCommand
+	switch scd.Type {
+	case "":
+		sqlCacheParameterLayer, err := flags.NewSqlCacheParameterLayer()
+		if err != nil {
+			return nil, err
+		}
+		description.Layers.AppendLayers(sqlCacheParameterLayer)
+
+		sq, err = NewSqlCommand(description, sqlCommandOptions...)
+		if err != nil {
+			return nil, err
+		}
+		command = sq
+	case ExecCommandType:
+		if scd.Cache != nil {
+			return nil, errors.Errorf("command %s: exec commands can't be cached", scd.Name)
+		}
+		if scd.Pagination != nil {
+			return nil, errors.Errorf("command %s: exec commands can't be paginated", scd.Name)
+		}
+		ec, err := NewExecCommand(description, sqlCommandOptions...)
+		if err != nil {
+			return nil, err
+		}
+		sq = ec.SqlCommand
+		command = ec
+	default:
+		return nil, errors.Errorf("Unknown command type %s", scd.Type)
 	}
 
 	for _, option := range options_ {
 		option(sq.Description())
 	}
 
+	err = checkUseLayers(sq, useLayers)
+	if err != nil {
+		return nil, err
+	}
+
 	if !sq.IsValid() {
 		return nil, errors.New("Invalid command")
 	}
 
-	return []cmds.Command{sq}, nil
+	return []cmds.Command{command}, nil
 }
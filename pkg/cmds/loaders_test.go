@@ -0,0 +1,98 @@
+package cmds
+
+import (
+	"encoding/json"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/alias"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestLoadCommandUnknownKey(t *testing.T) {
+	loader := &SqlCommandLoader{}
+	_, err := loader.loadSqlCommandFromReader(strings.NewReader(`
+name: ls
+short: List
+subquery:
+  ids: SELECT id FROM test
+query: SELECT 1
+`), []cmds.CommandDescriptionOption{}, []alias.Option{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "line 4: field subquery not found")
+}
+
+// yamlKeys returns the YAML keys of the fields of the given struct.
+func yamlKeys(v interface{}) []string {
+	ret := []string{}
+	t := reflect.TypeOf(v)
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name != "" && name != "-" {
+			ret = append(ret, name)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+type jsonSchema struct {
+	Properties  map[string]*jsonSchema `json:"properties"`
+	Items       *jsonSchema            `json:"items"`
+	Definitions map[string]*jsonSchema `json:"definitions"`
+}
+
+func (s *jsonSchema) keys() []string {
+	ret := []string{}
+	for k := range s.Properties {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// TestCommandJSONSchema checks that the published schema doesn't drift from the command format.
+func TestCommandJSONSchema(t *testing.T) {
+	schema := &jsonSchema{}
+	require.NoError(t, json.Unmarshal(CommandJSONSchema, schema))
+
+	assert.Equal(t, yamlKeys(SqlCommandDescription{}), schema.keys())
+	assert.Equal(t, yamlKeys(CacheSettings{}), schema.Properties["cache"].keys())
+	assert.Equal(t, yamlKeys(PaginationSettings{}), schema.Properties["pagination"].keys())
+	assert.Equal(t, yamlKeys(ColumnDefinition{}), schema.Properties["columns"].Items.keys())
+	assert.Equal(t, yamlKeys(parameters.ParameterDefinition{}), schema.Definitions["parameter"].keys())
+}
+
+func TestLoadCommandColumns(t *testing.T) {
+	loader := &SqlCommandLoader{}
+	cmds_, err := loader.loadSqlCommandFromReader(strings.NewReader(`
+name: ls
+short: List
+columns:
+  - name: id
+    type: int64
+  - name: name
+    type: string
+    nullable: true
+query: SELECT id, name FROM test
+`), []cmds.CommandDescriptionOption{}, []alias.Option{})
+	require.NoError(t, err)
+	s := cmds_[0].(*SqlCommand)
+	require.Len(t, s.Columns, 2)
+	assert.Equal(t, &ColumnDefinition{Name: "name", Type: "string", Nullable: true}, s.Columns[1])
+
+	_, err = loader.loadSqlCommandFromReader(strings.NewReader(`
+name: ls
+short: List
+columns:
+  - name: id
+    type: integer
+query: SELECT id FROM test
+`), []cmds.CommandDescriptionOption{}, []alias.Option{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `column id has unsupported type "integer"`)
+}
@@ -0,0 +1,359 @@
+package cmds
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/go-go-golems/sqleton/pkg/flags"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	PaginationOrderAsc  = "asc"
+	PaginationOrderDesc = "desc"
+)
+
+// PaginationSettings is the `pagination:` block of a SqlCommandDescription.
+//
+//	pagination:
+//	  keys: [id]
+//	  page-size: 50
+//
+// Declaring the block adds the --page-size, --cursor and --all-pages flags to the command.
+// With keys, the pages are selected with a predicate on the key columns of the results
+// (keyset pagination), which have to be non-null and uniquely identify a row.
+// Without keys, the pages are selected with LIMIT and OFFSET, keeping the order of the query.
+type PaginationSettings struct {
+	Keys     []string `yaml:"keys,omitempty"`
+	PageSize int      `yaml:"page-size,omitempty"`
+	Order    string   `yaml:"order,omitempty"`
+}
+
+var keyColumnRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func (p *PaginationSettings) Validate() error {
+	if p.PageSize < 0 {
+		return errors.Errorf("page-size must be positive, got %d", p.PageSize)
+	}
+	for _, key := range p.Keys {
+		if !keyColumnRegexp.MatchString(key) {
+			return errors.Errorf("invalid pagination key %s, expected the name of a column of the results", key)
+		}
+	}
+	switch p.Order {
+	case "", PaginationOrderAsc, PaginationOrderDesc:
+	default:
+		return errors.Errorf("unknown pagination order %s (expected %s or %s)",
+			p.Order, PaginationOrderAsc, PaginationOrderDesc)
+	}
+	if p.Order != "" && !p.IsKeyset() {
+		return errors.New("pagination order can only be set together with keys")
+	}
+	return nil
+}
+
+// IsKeyset returns true if the pages are selected on the key columns instead of with an offset.
+func (p *PaginationSettings) IsKeyset() bool {
+	return len(p.Keys) > 0
+}
+
+// Page is the page of results requested with the pagination flags.
+type Page struct {
+	Size     int
+	AllPages bool
+	cursor   *pageCursor
+}
+
+// pageCursor is the position of a page: the keys of the last row of the previous page
+// for keyset pagination, or the number of rows to skip for offset pagination.
+// It is passed around base64 encoded, and should be considered opaque by clients.
+type pageCursor struct {
+	Keys   []interface{} `json:"k,omitempty"`
+	Offset int           `json:"o,omitempty"`
+}
+
+func (c *pageCursor) Encode() (string, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", errors.Wrap(err, "could not encode cursor")
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeCursor(s string) (*pageCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.Errorf("invalid cursor %s", s)
+	}
+
+	ret := &pageCursor{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	// keep integer keys as integers, float64 would lose the precision of large ids
+	dec.UseNumber()
+	err = dec.Decode(ret)
+	if err != nil {
+		return nil, errors.Errorf("invalid cursor %s", s)
+	}
+
+	for i, key := range ret.Keys {
+		n, ok := key.(json.Number)
+		if !ok {
+			continue
+		}
+		if i_, err := n.Int64(); err == nil {
+			ret.Keys[i] = i_
+		} else if f, err := n.Float64(); err == nil {
+			ret.Keys[i] = f
+		}
+	}
+
+	return ret, nil
+}
+
+// GetPage returns the page requested with the pagination flags, or nil if the command
+// is not paginated or if --page-size is 0.
+func (s *SqlCommand) GetPage(parsedLayers *layers.ParsedLayers) (*Page, error) {
+	if s.Pagination == nil {
+		return nil, nil
+	}
+
+	ps := &flags.SqlPaginationSettings{
+		PageSize: s.Pagination.PageSize,
+	}
+	if _, ok := parsedLayers.Get(flags.SqlPaginationSlug); ok {
+		err := parsedLayers.InitializeStruct(flags.SqlPaginationSlug, ps)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if ps.PageSize < 0 {
+		return nil, errors.Errorf("--page-size must be positive, got %d", ps.PageSize)
+	}
+	if ps.PageSize == 0 {
+		if ps.Cursor != "" {
+			return nil, errors.New("--cursor requires a --page-size")
+		}
+		return nil, nil
+	}
+
+	ret := &Page{
+		Size:     ps.PageSize,
+		AllPages: ps.AllPages,
+	}
+	if ps.Cursor != "" {
+		cursor, err := decodeCursor(ps.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if s.Pagination.IsKeyset() && len(cursor.Keys) != len(s.Pagination.Keys) ||
+			!s.Pagination.IsKeyset() && len(cursor.Keys) > 0 {
+			return nil, errors.Errorf("cursor %s was not returned by this command", ps.Cursor)
+		}
+		ret.cursor = cursor
+	}
+
+	return ret, nil
+}
+
+// pageQuery returns the query selecting a single page of the results of query.
+// Keyset pages wrap the query in a subquery filtered and ordered on the keys,
+// offset pages append LIMIT and OFFSET to the query.
+func (p *PaginationSettings) pageQuery(
+	db *sqlx.DB,
+	query string,
+	args []interface{},
+	size int,
+	cursor *pageCursor,
+) (string, []interface{}) {
+	b := newArgsBinder(db)
+	b.args = append(b.args, args...)
+
+	query = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(query), ";"))
+
+	if !p.IsKeyset() {
+		offset := 0
+		if cursor != nil {
+			offset = cursor.Offset
+		}
+		return fmt.Sprintf("%s\nLIMIT %d OFFSET %d", query, size, offset), b.args
+	}
+
+	direction, comparison := "ASC", ">"
+	if p.Order == PaginationOrderDesc {
+		direction, comparison = "DESC", "<"
+	}
+
+	sb := &strings.Builder{}
+	_, _ = fmt.Fprintf(sb, "SELECT * FROM (\n%s\n) sqleton_page", query)
+	if cursor != nil {
+		placeholders := make([]string, len(cursor.Keys))
+		for i, key := range cursor.Keys {
+			placeholders[i] = b.bind(key)
+		}
+		_, _ = fmt.Fprintf(sb, "\nWHERE (%s) %s (%s)",
+			strings.Join(p.Keys, ", "), comparison, strings.Join(placeholders, ", "))
+	}
+	orderBy := make([]string, len(p.Keys))
+	for i, key := range p.Keys {
+		orderBy[i] = key + " " + direction
+	}
+	_, _ = fmt.Fprintf(sb, "\nORDER BY %s\nLIMIT %d", strings.Join(orderBy, ", "), size)
+
+	return sb.String(), b.args
+}
+
+// nextCursor returns the cursor of the page following a page of count rows, or nil
+// if the page was not full, which means that there are no more rows.
+func (p *PaginationSettings) nextCursor(size int, cursor *pageCursor, count int, lastKeys []interface{}) *pageCursor {
+	if count < size {
+		return nil
+	}
+	if p.IsKeyset() {
+		return &pageCursor{Keys: lastKeys}
+	}
+	offset := 0
+	if cursor != nil {
+		offset = cursor.Offset
+	}
+	return &pageCursor{Offset: offset + count}
+}
+
+// pageProcessor counts the rows of a page and remembers the keys of its last row.
+type pageProcessor struct {
+	middlewares.Processor
+	keys     []string
+	count    int
+	lastKeys []interface{}
+}
+
+func (p *pageProcessor) AddRow(ctx context.Context, row types.Row) error {
+	p.count++
+	if len(p.keys) > 0 {
+		keys := make([]interface{}, len(p.keys))
+		for i, key := range p.keys {
+			v, ok := row.Get(key)
+			if !ok {
+				return errors.Errorf("pagination key %s is not a column of the results", key)
+			}
+			keys[i] = v
+		}
+		p.lastKeys = keys
+	}
+	return p.Processor.AddRow(ctx, row)
+}
+
+// runPagesIntoGlazeProcessorWithDB runs the query for the requested page, or for all the pages
+// starting with the requested one if --all-pages was passed. If the page is full,
+// the cursor of the next page is printed on stderr.
+func (s *SqlCommand) runPagesIntoGlazeProcessorWithDB(
+	ctx context.Context,
+	db *sqlx.DB,
+	dataMap map[string]interface{},
+	page *Page,
+	gp middlewares.Processor,
+) error {
+	query, args, err := s.RenderQueryWithArgs(ctx, db, dataMap)
+	if err != nil {
+		return errors.Wrapf(err, "Could not generate query")
+	}
+
+	cursor := page.cursor
+	for {
+		s.renderedQuery, s.renderedArgs = s.Pagination.pageQuery(db, query, args, page.Size, cursor)
+
+		pp := &pageProcessor{Processor: gp, keys: s.Pagination.Keys}
+		err = s.RunQueryIntoGlaze(ctx, db, pp)
+		if err != nil {
+			return errors.Wrapf(err, "Could not run query")
+		}
+		// a query interrupted by a timeout can stop without error, in the middle of a page
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		next := s.Pagination.nextCursor(page.Size, cursor, pp.count, pp.lastKeys)
+		if next == nil {
+			return nil
+		}
+		if !page.AllPages {
+			next_, err := next.Encode()
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(os.Stderr, "more rows are available, pass --cursor %s for the next page\n", next_)
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// NextCursor returns the cursor of the page following the requested page, or "" if it is the last one.
+// It runs the page query, only retrieving the keys (or the number of rows for offset pagination).
+func (s *SqlCommand) NextCursor(
+	ctx context.Context,
+	db *sqlx.DB,
+	dataMap map[string]interface{},
+	page *Page,
+) (string, error) {
+	query, args, err := s.RenderQueryWithArgs(ctx, db, dataMap)
+	if err != nil {
+		return "", errors.Wrapf(err, "Could not generate query")
+	}
+	query, args = s.Pagination.pageQuery(db, query, args, page.Size, page.cursor)
+
+	count := 0
+	var lastKeys []interface{}
+	if s.Pagination.IsKeyset() {
+		orderBy := strings.Join(s.Pagination.Keys, ", ")
+		if s.Pagination.Order == PaginationOrderDesc {
+			orderBy = strings.Join(s.Pagination.Keys, " DESC, ") + " DESC"
+		}
+		query = fmt.Sprintf("SELECT %s FROM (\n%s\n) sqleton_keys ORDER BY %s",
+			strings.Join(s.Pagination.Keys, ", "), query, orderBy)
+
+		rows, err := db.QueryxContext(ctx, query, args...)
+		if err != nil {
+			return "", errors.Wrap(err, "could not compute the next cursor")
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+		for rows.Next() {
+			lastKeys, err = rows.SliceScan()
+			if err != nil {
+				return "", errors.Wrap(err, "could not compute the next cursor")
+			}
+			count++
+		}
+		if err = rows.Err(); err != nil {
+			return "", errors.Wrap(err, "could not compute the next cursor")
+		}
+		for i, key := range lastKeys {
+			if b, ok := key.([]byte); ok {
+				lastKeys[i] = string(b)
+			}
+		}
+	} else {
+		query = fmt.Sprintf("SELECT COUNT(*) FROM (\n%s\n) sqleton_keys", query)
+		err = db.QueryRowxContext(ctx, query, args...).Scan(&count)
+		if err != nil {
+			return "", errors.Wrap(err, "could not compute the next cursor")
+		}
+	}
+
+	next := s.Pagination.nextCursor(page.Size, page.cursor, count, lastKeys)
+	if next == nil {
+		return "", nil
+	}
+	return next.Encode()
+}
@@ -0,0 +1,168 @@
+package cmds
+
+import (
+	"context"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/alias"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/middlewares/table"
+	"github.com/go-go-golems/sqleton/pkg/flags"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func makePaginationLayers(t *testing.T, options ...layers.ParsedLayerOption) *layers.ParsedLayers {
+	paginationLayer, err := flags.NewSqlPaginationParameterLayer(2)
+	require.NoError(t, err)
+	options = append([]layers.ParsedLayerOption{layers.WithParsedParameterValue("page-size", 2)}, options...)
+	parsedPaginationLayer, err := layers.NewParsedLayer(paginationLayer, options...)
+	require.NoError(t, err)
+	return layers.NewParsedLayers(layers.WithParsedLayer(flags.SqlPaginationSlug, parsedPaginationLayer))
+}
+
+// runPage runs the command and returns the ids of the rows it output, and the cursor of the next page.
+func runPage(t *testing.T, s *SqlCommand, parsedLayers *layers.ParsedLayers) ([]interface{}, string) {
+	gp := middlewares.NewTableProcessor()
+	gp.AddTableMiddleware(&table.NullTableMiddleware{})
+	err := s.RunIntoGlazeProcessor(context.Background(), parsedLayers, gp)
+	require.NoError(t, err)
+
+	ids := []interface{}{}
+	for _, row := range gp.GetTable().Rows {
+		id, _ := row.Get("id")
+		ids = append(ids, id)
+	}
+
+	metadata, err := s.Metadata(context.Background(), parsedLayers)
+	require.NoError(t, err)
+	cursor, _ := metadata["next_cursor"].(string)
+
+	return ids, cursor
+}
+
+func TestKeysetPagination(t *testing.T) {
+	s, err := NewSqlCommand(
+		cmds.NewCommandDescription("test"),
+		WithDbConnectionFactory(createDB),
+		WithQuery("SELECT id, name FROM test WHERE name LIKE {{ sqlBind \"test%\" }};"),
+		WithPagination(&PaginationSettings{Keys: []string{"id"}, PageSize: 2}),
+	)
+	require.NoError(t, err)
+	_, ok := s.Description().Layers.Get(flags.SqlPaginationSlug)
+	assert.True(t, ok)
+
+	ids, cursor := runPage(t, s, makePaginationLayers(t))
+	assert.Equal(t, []interface{}{int64(1), int64(2)}, ids)
+	require.NotEmpty(t, cursor)
+
+	ids, cursor = runPage(t, s, makePaginationLayers(t, layers.WithParsedParameterValue("cursor", cursor)))
+	assert.Equal(t, []interface{}{int64(3)}, ids)
+	assert.Empty(t, cursor)
+
+	ids, _ = runPage(t, s, makePaginationLayers(t,
+		layers.WithParsedParameterValue("page-size", 1),
+		layers.WithParsedParameterValue("all-pages", true)))
+	assert.Equal(t, []interface{}{int64(1), int64(2), int64(3)}, ids)
+
+	s.Pagination.Order = PaginationOrderDesc
+	ids, cursor = runPage(t, s, makePaginationLayers(t))
+	assert.Equal(t, []interface{}{int64(3), int64(2)}, ids)
+	ids, _ = runPage(t, s, makePaginationLayers(t, layers.WithParsedParameterValue("cursor", cursor)))
+	assert.Equal(t, []interface{}{int64(1)}, ids)
+}
+
+func TestOffsetPagination(t *testing.T) {
+	s, err := NewSqlCommand(
+		cmds.NewCommandDescription("test"),
+		WithDbConnectionFactory(createDB),
+		WithQuery("SELECT id, name FROM test ORDER BY name DESC"),
+		WithPagination(&PaginationSettings{PageSize: 2}),
+	)
+	require.NoError(t, err)
+
+	ids, cursor := runPage(t, s, makePaginationLayers(t))
+	assert.Equal(t, []interface{}{int64(3), int64(2)}, ids)
+	require.NotEmpty(t, cursor)
+
+	ids, cursor = runPage(t, s, makePaginationLayers(t, layers.WithParsedParameterValue("cursor", cursor)))
+	assert.Equal(t, []interface{}{int64(1)}, ids)
+	assert.Empty(t, cursor)
+
+	// a page size of 0 returns all the rows
+	ids, _ = runPage(t, s, makePaginationLayers(t, layers.WithParsedParameterValue("page-size", 0)))
+	assert.Equal(t, []interface{}{int64(3), int64(2), int64(1)}, ids)
+}
+
+func TestPageQueryPlaceholders(t *testing.T) {
+	p := &PaginationSettings{Keys: []string{"created_at", "id"}}
+	db := sqlx.NewDb(nil, "postgres")
+
+	query, args := p.pageQuery(db, "SELECT * FROM posts WHERE status = $1", []interface{}{"draft"}, 10,
+		&pageCursor{Keys: []interface{}{"2024-01-01", int64(42)}})
+	assert.Contains(t, query, "WHERE (created_at, id) > ($2, $3)")
+	assert.Contains(t, query, "ORDER BY created_at ASC, id ASC\nLIMIT 10")
+	assert.Equal(t, []interface{}{"draft", "2024-01-01", int64(42)}, args)
+}
+
+func TestPageCursor(t *testing.T) {
+	c := &pageCursor{Keys: []interface{}{int64(9007199254740993), "abc"}}
+	s, err := c.Encode()
+	require.NoError(t, err)
+
+	decoded, err := decodeCursor(s)
+	require.NoError(t, err)
+	assert.Equal(t, c, decoded)
+
+	_, err = decodeCursor("not a cursor")
+	assert.Error(t, err)
+}
+
+func TestGetPageRejectsForeignCursor(t *testing.T) {
+	s, err := NewSqlCommand(
+		cmds.NewCommandDescription("test"),
+		WithQuery("SELECT id FROM test"),
+		WithPagination(&PaginationSettings{Keys: []string{"id"}, PageSize: 2}),
+	)
+	require.NoError(t, err)
+
+	offsetCursor, err := (&pageCursor{Offset: 20}).Encode()
+	require.NoError(t, err)
+	_, err = s.GetPage(makePaginationLayers(t, layers.WithParsedParameterValue("cursor", offsetCursor)))
+	assert.Error(t, err)
+}
+
+func TestLoadCommandPagination(t *testing.T) {
+	loader := &SqlCommandLoader{}
+	commands, err := loader.loadSqlCommandFromReader(strings.NewReader(`
+name: ls
+short: List
+pagination:
+  keys: [id]
+  page-size: 50
+query: SELECT id FROM test
+`), []cmds.CommandDescriptionOption{}, []alias.Option{})
+	require.NoError(t, err)
+	require.Len(t, commands, 1)
+
+	layer, ok := commands[0].Description().Layers.Get(flags.SqlPaginationSlug)
+	require.True(t, ok)
+	pd, ok := layer.GetParameterDefinitions().Get("page-size")
+	require.True(t, ok)
+	assert.Equal(t, 50, *pd.Default)
+
+	for _, invalid := range []string{
+		"pagination:\n  keys: [\"id; DROP TABLE test\"]",
+		"pagination:\n  page-size: -1",
+		"pagination:\n  order: desc",
+		"type: exec\npagination:\n  page-size: 10",
+	} {
+		_, err = loader.loadSqlCommandFromReader(strings.NewReader(
+			"name: ls\nshort: List\nquery: SELECT 1\n"+invalid+"\n",
+		), []cmds.CommandDescriptionOption{}, []alias.Option{})
+		assert.Error(t, err, invalid)
+	}
+}
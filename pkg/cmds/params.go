@@ -0,0 +1,204 @@
+package cmds
+
+import (
+	"context"
+	"github.com/go-go-golems/clay/pkg/sql"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var namedParameterRegexp = regexp.MustCompile(`^([a-zA-Z_][a-zA-Z0-9_]*)(?::([a-zA-Z]+))?=(.*)$`)
+
+// integerRegexp and floatRegexp match the plain decimal numbers a parameter value is coerced to.
+// Values with leading zeros (zip codes, ids) or spelled out (inf, nan) are kept as strings.
+var integerRegexp = regexp.MustCompile(`^-?(0|[1-9][0-9]*)$`)
+var floatRegexp = regexp.MustCompile(`^-?(0|[1-9][0-9]*)\.[0-9]+$`)
+
+// dateFormats are the formats for which a parameter value is coerced to a time.Time.
+// Natural language dates are only accepted when the type is given explicitly (name:date=...).
+var dateFormats = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// ParseNamedParameters builds the map of named parameters passed to ad-hoc queries.
+//
+// paramsFile is an optional YAML file containing a map of parameters. The values of params,
+// which are of the form name=value or name:type=value, override the values from the file.
+//
+// When no type is given, the value is coerced to an int, a float or a time.Time if possible,
+// and comma separated values are turned into a list.
+// Since the --param flag itself is split on commas, entries that don't start with name=
+// are considered to be the continuation of the previous value.
+func ParseNamedParameters(params []string, paramsFile string) (map[string]interface{}, error) {
+	ret := map[string]interface{}{}
+
+	if paramsFile != "" {
+		b, err := os.ReadFile(paramsFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not read params file %s", paramsFile)
+		}
+		err = yaml.Unmarshal(b, &ret)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not parse params file %s", paramsFile)
+		}
+		if ret == nil {
+			ret = map[string]interface{}{}
+		}
+	}
+
+	type rawParameter struct {
+		name   string
+		type_  string
+		values []string
+	}
+	rawParameters := []*rawParameter{}
+	for _, p := range params {
+		matches := namedParameterRegexp.FindStringSubmatch(p)
+		if matches == nil {
+			if len(rawParameters) == 0 {
+				return nil, errors.Errorf("invalid parameter %s, expected name=value", p)
+			}
+			last := rawParameters[len(rawParameters)-1]
+			last.values = append(last.values, p)
+			continue
+		}
+		rawParameters = append(rawParameters, &rawParameter{
+			name:   matches[1],
+			type_:  matches[2],
+			values: []string{matches[3]},
+		})
+	}
+
+	for _, p := range rawParameters {
+		if p.type_ == "" {
+			ret[p.name] = CoerceParameterValue(strings.Join(p.values, ","))
+			continue
+		}
+
+		v, err := parseTypedParameter(p.name, p.type_, p.values)
+		if err != nil {
+			return nil, err
+		}
+		ret[p.name] = v
+	}
+
+	return ret, nil
+}
+
+func parseTypedParameter(name string, type_ string, values []string) (interface{}, error) {
+	parameterType := parameters.ParameterType(type_)
+	switch parameterType {
+	case parameters.ParameterTypeString,
+		parameters.ParameterTypeInteger,
+		parameters.ParameterTypeFloat,
+		parameters.ParameterTypeBool,
+		parameters.ParameterTypeDate:
+		values = []string{strings.Join(values, ",")}
+	case parameters.ParameterTypeStringList,
+		parameters.ParameterTypeIntegerList,
+		parameters.ParameterTypeFloatList:
+	default:
+		return nil, errors.Errorf("unsupported type %s for parameter %s", type_, name)
+	}
+
+	pd := parameters.NewParameterDefinition(name, parameterType)
+	p, err := pd.ParseParameter(values)
+	if err != nil {
+		return nil, err
+	}
+	return p.Value, nil
+}
+
+// CoerceParameterValue converts a parameter passed on the command line to the most specific
+// type it can be parsed as: int, float64, time.Time, or a list of those if the value contains commas.
+// Everything else is returned as a string.
+func CoerceParameterValue(value string) interface{} {
+	if strings.Contains(value, ",") {
+		ret := []interface{}{}
+		for _, v := range strings.Split(value, ",") {
+			ret = append(ret, coerceScalarValue(strings.TrimSpace(v)))
+		}
+		return ret
+	}
+
+	return coerceScalarValue(value)
+}
+
+func coerceScalarValue(value string) interface{} {
+	if integerRegexp.MatchString(value) {
+		if i, err := strconv.Atoi(value); err == nil {
+			return i
+		}
+	}
+	if floatRegexp.MatchString(value) {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	for _, format := range dateFormats {
+		if t, err := time.ParseInLocation(format, value, time.Local); err == nil {
+			return t
+		}
+	}
+	return value
+}
+
+// RunNamedQueryIntoGlaze runs a query containing :name placeholders, like sql.RunNamedQueryIntoGlaze.
+// List values are expanded, so that they can be used as `WHERE id IN (:ids)`.
+func RunNamedQueryIntoGlaze(
+	ctx context.Context,
+	db *sqlx.DB,
+	query string,
+	params map[string]interface{},
+	gp middlewares.Processor,
+) error {
+	hasList := false
+	for _, v := range params {
+		if _, ok := v.([]byte); ok {
+			continue
+		}
+		kind := reflect.ValueOf(v).Kind()
+		if kind == reflect.Slice || kind == reflect.Array {
+			hasList = true
+			break
+		}
+	}
+
+	if !hasList {
+		return sql.RunNamedQueryIntoGlaze(ctx, db, query, params, gp)
+	}
+
+	query_, args, err := BindNamedQuery(db, query, params)
+	if err != nil {
+		return err
+	}
+
+	return sql.RunQueryIntoGlaze(ctx, db, query_, args, gp)
+}
+
+// BindNamedQuery turns the :name placeholders of query into the bind variables of the driver of db,
+// expanding list values like RunNamedQueryIntoGlaze.
+func BindNamedQuery(db *sqlx.DB, query string, params map[string]interface{}) (string, []interface{}, error) {
+	query_, args, err := sqlx.Named(query, params)
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "Could not bind named parameters: %s", query)
+	}
+	query_, args, err = sqlx.In(query_, args...)
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "Could not expand list parameters: %s", query)
+	}
+
+	return db.Rebind(query_), args, nil
+}
@@ -0,0 +1,123 @@
+package cmds
+
+import (
+	"context"
+	assert2 "github.com/go-go-golems/glazed/pkg/helpers/assert"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/middlewares/table"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseNamedParametersCoercion(t *testing.T) {
+	params, err := ParseNamedParameters([]string{
+		"id=42",
+		"ratio=0.5",
+		"name=foobar",
+		"since=2023-01-02",
+		"zip:string=01234",
+		"verbose:bool=true",
+	}, "")
+	require.NoError(t, err)
+
+	assert.Equal(t, 42, params["id"])
+	assert.Equal(t, 0.5, params["ratio"])
+	assert.Equal(t, "foobar", params["name"])
+	assert.Equal(t, time.Date(2023, 1, 2, 0, 0, 0, 0, time.Local), params["since"])
+	assert.Equal(t, "01234", params["zip"])
+	assert.Equal(t, true, params["verbose"])
+}
+
+func TestParseNamedParametersCoercionKeepsStrings(t *testing.T) {
+	params, err := ParseNamedParameters([]string{
+		"zip=007",
+		"name=inf",
+		"other=NaN",
+		"exp=1e5",
+		"negative=-3",
+		"small=-0.25",
+	}, "")
+	require.NoError(t, err)
+
+	assert.Equal(t, "007", params["zip"])
+	assert.Equal(t, "inf", params["name"])
+	assert.Equal(t, "NaN", params["other"])
+	assert.Equal(t, "1e5", params["exp"])
+	assert.Equal(t, -3, params["negative"])
+	assert.Equal(t, -0.25, params["small"])
+}
+
+func TestParseNamedParametersLists(t *testing.T) {
+	// the --param flag is split on commas, so the list values arrive as separate entries
+	params, err := ParseNamedParameters([]string{
+		"ids=1", "2", "3",
+		"names:stringList=a", "b",
+		"tags=foo,bar",
+	}, "")
+	require.NoError(t, err)
+
+	assert.Equal(t, []interface{}{1, 2, 3}, params["ids"])
+	assert.Equal(t, []string{"a", "b"}, params["names"])
+	assert.Equal(t, []interface{}{"foo", "bar"}, params["tags"])
+}
+
+func TestParseNamedParametersErrors(t *testing.T) {
+	_, err := ParseNamedParameters([]string{"foobar"}, "")
+	assert.Error(t, err)
+
+	_, err = ParseNamedParameters([]string{"id:int=foo"}, "")
+	assert.Error(t, err)
+
+	_, err = ParseNamedParameters([]string{"id:unknown=1"}, "")
+	assert.Error(t, err)
+}
+
+func TestParseNamedParametersFile(t *testing.T) {
+	paramsFile := filepath.Join(t.TempDir(), "params.yaml")
+	err := os.WriteFile(paramsFile, []byte("id: 1\nname: test1\nids: [1, 2]\n"), 0644)
+	require.NoError(t, err)
+
+	params, err := ParseNamedParameters([]string{"name=test2"}, paramsFile)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, params["id"])
+	assert.Equal(t, "test2", params["name"])
+	assert.Equal(t, []interface{}{1, 2}, params["ids"])
+}
+
+func TestRunNamedQueryWithList(t *testing.T) {
+	db, err := createDB(nil)
+	require.NoError(t, err)
+
+	gp := middlewares.NewTableProcessor()
+	gp.AddTableMiddleware(&table.NullTableMiddleware{})
+	ctx := context.Background()
+
+	err = RunNamedQueryIntoGlaze(ctx, db,
+		"SELECT * FROM test WHERE id IN (:ids) AND name != :name ORDER BY id",
+		map[string]interface{}{
+			"ids":  []interface{}{1, 2, 3},
+			"name": "test2",
+		}, gp)
+	require.NoError(t, err)
+
+	err = gp.Close(ctx)
+	require.NoError(t, err)
+
+	expected := []types.Row{
+		types.NewRow(
+			types.MRP("id", int64(1)),
+			types.MRP("name", "test1"),
+		),
+		types.NewRow(
+			types.MRP("id", int64(3)),
+			types.MRP("name", "test3"),
+		),
+	}
+	assert2.EqualRows(t, expected, gp.GetTable().Rows)
+}
@@ -0,0 +1,147 @@
+package cmds
+
+import (
+	"fmt"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// PartialsDirectory is the name of the directories holding the partials of a repository.
+// The files in these directories are not commands.
+const PartialsDirectory = "_partials"
+
+// PartialDescription is a fragment shared by the commands of a repository, which include it with
+// `includes: [name]`. Its flags are added to the flags of the command, and its template can be
+// rendered by the query with `{{ template "name" . }}`.
+//
+// The partial name is looked up as _partials/name.yaml in the directory of the command and its parents,
+// the nearest one being used.
+type PartialDescription struct {
+	Flags    []*parameters.ParameterDefinition `yaml:"flags,omitempty"`
+	Template string                            `yaml:"template,omitempty"`
+}
+
+// IsPartialFileName returns true if the file is inside a partials directory.
+func IsPartialFileName(fileName string) bool {
+	return isInDirectory(fileName, PartialsDirectory)
+}
+
+func isInDirectory(fileName string, directory string) bool {
+	for _, part := range strings.Split(path.Dir(fileName), "/") {
+		if part == directory {
+			return true
+		}
+	}
+	return false
+}
+
+// readFromParents reads subDir/fileName from dir or the nearest of its parents containing it.
+// It returns the path of the file along with its content.
+func readFromParents(f fs.FS, dir string, subDir string, fileName string) (string, []byte, error) {
+	for {
+		p := path.Join(dir, subDir, fileName)
+		content, err := fs.ReadFile(f, p)
+		if err == nil {
+			return p, content, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", nil, err
+		}
+
+		if dir == "." || dir == "/" {
+			return "", nil, err
+		}
+		dir = path.Dir(dir)
+	}
+}
+
+func loadPartial(f fs.FS, dir string, name string) (*PartialDescription, error) {
+	if !fs.ValidPath(name) || strings.HasSuffix(name, ".yaml") {
+		return nil, errors.Errorf("invalid partial name %s", name)
+	}
+
+	fileName, content, err := readFromParents(f, dir, PartialsDirectory, name+".yaml")
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errors.Errorf("could not find partial %s", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pd := &PartialDescription{}
+	decoder := yaml.NewDecoder(strings.NewReader(string(content)))
+	decoder.KnownFields(true)
+	err = decoder.Decode(pd)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not load partial %s", fileName)
+	}
+	return pd, nil
+}
+
+// resolveIncludes loads the partials included by the command loaded from fileName in f,
+// and returns the flags of the command merged with the flags of the partials, along with the partial templates.
+// The flags declared by the command take precedence over the flags of the partials, then the partials
+// listed first take precedence.
+func resolveIncludes(f fs.FS, fileName string, scd *SqlCommandDescription) (
+	[]*parameters.ParameterDefinition,
+	map[string]string,
+	error,
+) {
+	if len(scd.Includes) == 0 {
+		return scd.Flags, nil, nil
+	}
+	if f == nil {
+		return nil, nil, errors.Errorf("command %s: includes can only be resolved for commands loaded from a repository", scd.Name)
+	}
+
+	flags_ := append([]*parameters.ParameterDefinition{}, scd.Flags...)
+	declared := map[string]bool{}
+	for _, flag := range flags_ {
+		declared[flag.Name] = true
+	}
+	partials := map[string]string{}
+
+	for _, name := range scd.Includes {
+		pd, err := loadPartial(f, path.Dir(fileName), name)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "command %s", scd.Name)
+		}
+		for _, flag := range pd.Flags {
+			if declared[flag.Name] {
+				continue
+			}
+			declared[flag.Name] = true
+			flags_ = append(flags_, flag)
+		}
+		if pd.Template != "" {
+			partials[name] = pd.Template
+		}
+	}
+
+	return flags_, partials, nil
+}
+
+// QueryWithPartials returns the query of the command, preceded by the definitions of the partials it includes,
+// which makes it renderable on its own.
+func (s *SqlCommand) QueryWithPartials() string {
+	if len(s.Partials) == 0 {
+		return s.Query
+	}
+
+	names := make([]string, 0, len(s.Partials))
+	for name := range s.Partials {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ret := ""
+	for _, name := range names {
+		ret += fmt.Sprintf("{{ define %q }}%s{{ end }}", name, s.Partials[name])
+	}
+	return ret + s.Query
+}
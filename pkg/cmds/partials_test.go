@@ -0,0 +1,77 @@
+package cmds
+
+import (
+	"context"
+	clay_sql "github.com/go-go-golems/clay/pkg/sql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"testing/fstest"
+)
+
+var partialsFS = fstest.MapFS{
+	"_partials/name_filter.yaml": &fstest.MapFile{Data: []byte(`
+flags:
+  - name: name
+    type: string
+    help: Only show rows with this name
+  - name: limit
+    type: int
+    default: 10
+template: |
+  {{ if .name }}AND name = {{ .name | sqlString }}{{ end }}
+`)},
+	"test/_partials/limit.yaml": &fstest.MapFile{Data: []byte(`
+template: LIMIT {{ .limit }}
+`)},
+	"test/ls.yaml": &fstest.MapFile{Data: []byte(`
+name: ls
+short: List
+flags:
+  - name: limit
+    type: int
+    default: 2
+includes:
+  - name_filter
+  - limit
+query: SELECT id FROM test WHERE 1=1 {{ template "name_filter" . }} {{ template "limit" . }}
+`)},
+	"test/missing.yaml": &fstest.MapFile{Data: []byte(`
+name: missing
+short: List
+includes: [nope]
+query: SELECT 1
+`)},
+}
+
+func TestLoadCommandWithIncludes(t *testing.T) {
+	loader := &SqlCommandLoader{DBConnectionFactory: createDB}
+	assert.False(t, loader.IsFileSupported(partialsFS, "_partials/name_filter.yaml"))
+	assert.False(t, loader.IsFileSupported(partialsFS, "test/_partials/limit.yaml"))
+	assert.True(t, loader.IsFileSupported(partialsFS, "test/ls.yaml"))
+
+	commands, err := loader.LoadCommands(partialsFS, "test/ls.yaml", nil, nil)
+	require.NoError(t, err)
+	require.Len(t, commands, 1)
+	s := commands[0].(*SqlCommand)
+
+	// the flags of the command take precedence over the flags of the partials
+	flags_ := s.Description().GetDefaultFlags().ToList()
+	require.Len(t, flags_, 2)
+	assert.Equal(t, "limit", flags_[0].Name)
+	assert.Equal(t, 2, *flags_[0].Default)
+	assert.Equal(t, "name", flags_[1].Name)
+
+	ps := map[string]interface{}{"name": "test1", "limit": 2}
+	query, err := s.RenderQuery(context.Background(), nil, ps)
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT id FROM test WHERE 1=1 AND name = 'test1'\n LIMIT 2", query)
+
+	// the query can be rendered on its own once the partials are inlined
+	inlined, err := clay_sql.RenderQuery(context.Background(), nil, s.QueryWithPartials(), nil, ps)
+	require.NoError(t, err)
+	assert.Equal(t, query, inlined)
+
+	_, err = loader.LoadCommands(partialsFS, "test/missing.yaml", nil, nil)
+	assert.EqualError(t, err, "command missing: could not find partial nope")
+}
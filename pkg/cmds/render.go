@@ -0,0 +1,237 @@
+package cmds
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	clay_sql "github.com/go-go-golems/clay/pkg/sql"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/helpers/templating"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"reflect"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// argsBinder collects the values that are bound while rendering a query template,
+// and returns the placeholder matching the bind style of the database driver.
+type argsBinder struct {
+	bindType int
+	args     []interface{}
+}
+
+func newArgsBinder(db *sqlx.DB) *argsBinder {
+	bindType := sqlx.QUESTION
+	if db != nil {
+		bindType = sqlx.BindType(db.DriverName())
+	}
+	return &argsBinder{
+		bindType: bindType,
+		args:     []interface{}{},
+	}
+}
+
+func (b *argsBinder) bind(value interface{}) string {
+	b.args = append(b.args, value)
+	n := len(b.args)
+
+	switch b.bindType {
+	case sqlx.DOLLAR:
+		return fmt.Sprintf("$%d", n)
+	case sqlx.AT:
+		return fmt.Sprintf("@p%d", n)
+	case sqlx.NAMED:
+		name := fmt.Sprintf("arg%d", n)
+		b.args[n-1] = sql.Named(name, value)
+		return ":" + name
+	default:
+		return "?"
+	}
+}
+
+func (b *argsBinder) bindList(values interface{}) (string, error) {
+	list, err := toInterfaceList(values)
+	if err != nil {
+		return "", err
+	}
+	if len(list) == 0 {
+		return "", errors.New("cannot bind an empty list")
+	}
+
+	placeholders := make([]string, len(list))
+	for i, v := range list {
+		placeholders[i] = b.bind(v)
+	}
+	return strings.Join(placeholders, ", "), nil
+}
+
+func (b *argsBinder) bindDate(date interface{}, fullFormat string, defaultFormat string) (string, error) {
+	s, err := formatDate(date, fullFormat, defaultFormat)
+	if err != nil {
+		return "", err
+	}
+	return b.bind(s), nil
+}
+
+// funcMap returns the binding template functions. sqlBind and sqlBindIn are always available.
+// If parameterized is true, the quoting helpers provided by clay (sqlString, sqlStringIn, sqlIn, ...)
+// are replaced by versions that bind their value instead of interpolating it into the query.
+func (b *argsBinder) funcMap(parameterized bool) template.FuncMap {
+	ret := template.FuncMap{
+		"sqlBind":   b.bind,
+		"sqlBindIn": b.bindList,
+	}
+
+	if !parameterized {
+		return ret
+	}
+
+	ret["sqlString"] = b.bind
+	ret["sqlStringIn"] = b.bindList
+	ret["sqlIn"] = b.bindList
+	ret["sqlIntIn"] = b.bindList
+	ret["sqlLike"] = func(value string) string {
+		return b.bind("%" + value + "%")
+	}
+	ret["sqlStringLike"] = func(value string) string {
+		return b.bind("%" + value + "%")
+	}
+	ret["sqlDate"] = func(date interface{}) (string, error) {
+		return b.bindDate(date, time.RFC3339, "2006-01-02")
+	}
+	ret["sqlDateTime"] = func(date interface{}) (string, error) {
+		return b.bindDate(date, time.RFC3339, "2006-01-02T15:04:05")
+	}
+	ret["sqliteDate"] = func(date interface{}) (string, error) {
+		return b.bindDate(date, "2006-01-02", "2006-01-02")
+	}
+	ret["sqliteDateTime"] = func(date interface{}) (string, error) {
+		return b.bindDate(date, "2006-01-02 15:04:05", "2006-01-02 15:04:05")
+	}
+
+	return ret
+}
+
+// formatDate mirrors the date formatting of the clay sqlDate helpers, without the surrounding quotes.
+func formatDate(date interface{}, fullFormat string, defaultFormat string) (string, error) {
+	var t time.Time
+	switch v := date.(type) {
+	case string:
+		parsedDate, err := parameters.ParseDate(v)
+		if err != nil {
+			return "", err
+		}
+		t = parsedDate
+	case time.Time:
+		t = v
+	default:
+		return "", errors.Errorf("could not parse date %v", date)
+	}
+
+	if t.Location() == time.Local {
+		return t.Format(defaultFormat), nil
+	}
+	return t.Format(fullFormat), nil
+}
+
+func toInterfaceList(values interface{}) ([]interface{}, error) {
+	if l, ok := values.([]interface{}); ok {
+		return l, nil
+	}
+
+	v := reflect.ValueOf(values)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, errors.Errorf("could not cast %v to a list", values)
+	}
+
+	ret := make([]interface{}, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		ret[i] = v.Index(i).Interface()
+	}
+	return ret, nil
+}
+
+// RenderQueryWithArgs renders a query template the same way clay_sql.RenderQuery does,
+// but also returns the list of values that were bound while rendering.
+//
+// The sqlBind and sqlBindIn template functions emit driver-appropriate placeholders
+// (`?`, `$1`, ...) and collect their values into the returned args.
+// If parameterized is true, the usual quoting helpers (sqlString, sqlStringIn, sqlIntIn, sqlLike, sqlDate, ...)
+// bind their values as well, so that no parameter value is interpolated into the SQL.
+//
+// NOTE: the binding functions are not available in the nested templates run by
+// sqlColumn, sqlSingle, sqlSlice and sqlMap, which are rendered by clay directly.
+func RenderQueryWithArgs(
+	ctx context.Context,
+	db *sqlx.DB,
+	query string,
+	subQueries map[string]string,
+	data map[string]interface{},
+	parameterized bool,
+) (string, []interface{}, error) {
+	return renderQueryWithArgs(ctx, db, query, subQueries, nil, data, parameterized)
+}
+
+// renderQueryWithArgs is RenderQueryWithArgs, with the partial templates the query can render with
+// `{{ template "name" . }}`.
+func renderQueryWithArgs(
+	ctx context.Context,
+	db *sqlx.DB,
+	query string,
+	subQueries map[string]string,
+	partials map[string]string,
+	data map[string]interface{},
+	parameterized bool,
+) (string, []interface{}, error) {
+	binder := newArgsBinder(db)
+	t := clay_sql.CreateTemplate(ctx, subQueries, data, db).
+		Funcs(binder.funcMap(parameterized))
+
+	err := parsePartials(t, partials)
+	if err != nil {
+		return "", nil, err
+	}
+
+	t, err = t.Parse(query)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "Could not parse query template")
+	}
+
+	ret, err := templating.RenderTemplate(t, data)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "Could not render query template")
+	}
+
+	return clay_sql.CleanQuery(ret), binder.args, nil
+}
+
+// parsePartials adds the partial templates to the template set of t.
+func parsePartials(t *template.Template, partials map[string]string) error {
+	for name, partial := range partials {
+		_, err := t.New(name).Parse(partial)
+		if err != nil {
+			return errors.Wrapf(err, "Could not parse partial %s", name)
+		}
+	}
+	return nil
+}
+
+// ParseQueryTemplate parses a query template with all the functions and partials available when rendering it,
+// without rendering it. It is used to inspect the parameters referenced by a query.
+func ParseQueryTemplate(query string, subQueries map[string]string, partials map[string]string) (*template.Template, error) {
+	t := clay_sql.CreateTemplate(context.Background(), subQueries, nil, nil).
+		Funcs(newArgsBinder(nil).funcMap(true))
+
+	err := parsePartials(t, partials)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err = t.Parse(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not parse query template")
+	}
+	return t, nil
+}
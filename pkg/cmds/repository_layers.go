@@ -0,0 +1,93 @@
+package cmds
+
+import (
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/pkg/errors"
+	"io/fs"
+	"path"
+)
+
+// LayersDirectory is the name of the directories holding the parameter layers of a repository.
+// A layer is defined in _layers/<slug>.yaml, using the same format as the layers built into sqleton:
+//
+//	slug: date-range
+//	name: Date range flags
+//	flags:
+//	  - name: from
+//	    type: date
+//
+// and is added to the commands listing its slug under `use-layers:`. Like partials, layers
+// are looked up in the directory of the command and its parents, the nearest one being used.
+// The files in these directories are not commands. The leading underscore keeps existing
+// directories of commands named layers loadable.
+const LayersDirectory = "_layers"
+
+// IsLayerFileName returns true if the file is inside a layers directory.
+func IsLayerFileName(fileName string) bool {
+	return isInDirectory(fileName, LayersDirectory)
+}
+
+func loadLayer(f fs.FS, dir string, slug string) (layers.ParameterLayer, error) {
+	if !fs.ValidPath(slug) || path.Ext(slug) != "" {
+		return nil, errors.Errorf("invalid layer slug %s", slug)
+	}
+
+	fileName, content, err := readFromParents(f, dir, LayersDirectory, slug+".yaml")
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errors.Errorf("could not find layer %s", slug)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ret, err := layers.NewParameterLayerFromYAML(content)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not load layer %s", fileName)
+	}
+	if ret.GetSlug() != slug {
+		return nil, errors.Errorf("layer %s has slug %s instead of %s", fileName, ret.GetSlug(), slug)
+	}
+	return ret, nil
+}
+
+// resolveUseLayers loads the layers used by the command loaded from fileName in f.
+func resolveUseLayers(f fs.FS, fileName string, scd *SqlCommandDescription) ([]layers.ParameterLayer, error) {
+	if len(scd.UseLayers) == 0 {
+		return nil, nil
+	}
+	if f == nil {
+		return nil, errors.Errorf("command %s: layers can only be resolved for commands loaded from a repository", scd.Name)
+	}
+
+	ret := []layers.ParameterLayer{}
+	for _, slug := range scd.UseLayers {
+		layer, err := loadLayer(f, path.Dir(fileName), slug)
+		if err != nil {
+			return nil, errors.Wrapf(err, "command %s", scd.Name)
+		}
+		ret = append(ret, layer)
+	}
+	return ret, nil
+}
+
+// checkUseLayers checks that the parameters of the layers used by a command don't clash
+// with the other parameters of the command, which would make the command unusable.
+func checkUseLayers(s *SqlCommand, useLayers []layers.ParameterLayer) error {
+	used := map[string]bool{}
+	for _, l := range useLayers {
+		used[l.GetSlug()] = true
+	}
+
+	declared := map[string]string{}
+	var err error
+	s.Description().Layers.ForEach(func(slug string, l layers.ParameterLayer) {
+		for _, pd := range l.GetParameterDefinitions().ToList() {
+			if other, ok := declared[pd.Name]; ok && err == nil && (used[slug] || used[other]) {
+				err = errors.Errorf("command %s: parameter %s of layer %s is already defined by layer %s",
+					s.Name, pd.Name, slug, other)
+			}
+			declared[pd.Name] = slug
+		}
+	})
+	return err
+}
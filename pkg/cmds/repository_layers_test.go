@@ -0,0 +1,67 @@
+package cmds
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"testing/fstest"
+)
+
+var layersFS = fstest.MapFS{
+	"_layers/date-range.yaml": &fstest.MapFile{Data: []byte(`
+slug: date-range
+name: Date range flags
+flags:
+  - name: from
+    type: date
+  - name: to
+    type: date
+`)},
+	"_layers/misnamed.yaml": &fstest.MapFile{Data: []byte(`
+slug: other
+name: Misnamed
+`)},
+	"posts/ls.yaml": &fstest.MapFile{Data: []byte(`
+name: ls
+short: List
+use-layers: [date-range]
+query: SELECT * FROM posts WHERE date >= {{ .from | sqlDate }}
+`)},
+	"posts/clash.yaml": &fstest.MapFile{Data: []byte(`
+name: clash
+short: List
+flags:
+  - name: from
+    type: string
+use-layers: [date-range]
+query: SELECT 1
+`)},
+	"posts/misnamed.yaml": &fstest.MapFile{Data: []byte(`
+name: misnamed
+short: List
+use-layers: [misnamed]
+query: SELECT 1
+`)},
+}
+
+func TestLoadCommandUseLayers(t *testing.T) {
+	loader := &SqlCommandLoader{}
+	assert.False(t, loader.IsFileSupported(layersFS, "_layers/date-range.yaml"))
+	// directories of commands named layers are not layer directories
+	assert.True(t, loader.IsFileSupported(layersFS, "layers/ls.yaml"))
+
+	commands, err := loader.LoadCommands(layersFS, "posts/ls.yaml", nil, nil)
+	require.NoError(t, err)
+	require.Len(t, commands, 1)
+	layer, ok := commands[0].Description().Layers.Get("date-range")
+	require.True(t, ok)
+	assert.Equal(t, "Date range flags", layer.GetName())
+	_, ok = layer.GetParameterDefinitions().Get("from")
+	assert.True(t, ok)
+
+	_, err = loader.LoadCommands(layersFS, "posts/clash.yaml", nil, nil)
+	assert.EqualError(t, err, "command clash: parameter from of layer date-range is already defined by layer default")
+
+	_, err = loader.LoadCommands(layersFS, "posts/misnamed.yaml", nil, nil)
+	assert.EqualError(t, err, "command misnamed: layer _layers/misnamed.yaml has slug other instead of misnamed")
+}
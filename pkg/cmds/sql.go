@@ -15,6 +15,7 @@ import (
 	"github.com/pkg/errors"
 	"gopkg.in/yaml.v3"
 	"io"
+	"os"
 	"strings"
 )
 
@@ -32,6 +33,7 @@ var _ cmds.GlazeCommand = (*SqlCommand)(nil)
 var _ cmds.CommandWithMetadata = (*SqlCommand)(nil)
 
 type SqlCommandDescription struct {
+	Type      string                            `yaml:"type,omitempty"`
 	Name      string                            `yaml:"name"`
 	Short     string                            `yaml:"short"`
 	Long      string                            `yaml:"long,omitempty"`
@@ -39,9 +41,16 @@ type SqlCommandDescription struct {
 	Flags     []*parameters.ParameterDefinition `yaml:"flags,omitempty"`
 	Arguments []*parameters.ParameterDefinition `yaml:"arguments,omitempty"`
 	Layers    []layers.ParameterLayer           `yaml:"layers,omitempty"`
-
-	SubQueries map[string]string `yaml:"subqueries,omitempty"`
-	Query      string            `yaml:"query"`
+	UseLayers []string                          `yaml:"use-layers,omitempty"`
+
+	Includes      []string            `yaml:"includes,omitempty"`
+	SubQueries    map[string]string   `yaml:"subqueries,omitempty"`
+	Query         string              `yaml:"query"`
+	Parameterized bool                `yaml:"parameterized,omitempty"`
+	Cache         *CacheSettings      `yaml:"cache,omitempty"`
+	Timeout       string              `yaml:"timeout,omitempty"`
+	Pagination    *PaginationSettings `yaml:"pagination,omitempty"`
+	Columns       []*ColumnDefinition `yaml:"columns,omitempty"`
 }
 
 // SqlCommand describes a command line command that runs a query
@@ -49,8 +58,16 @@ type SqlCommand struct {
 	*cmds.CommandDescription `yaml:",inline"`
 	Query                    string                       `yaml:"query"`
 	SubQueries               map[string]string            `yaml:"subqueries,omitempty"`
+	Includes                 []string                     `yaml:"includes,omitempty"`
+	Partials                 map[string]string            `yaml:"-"`
+	Parameterized            bool                         `yaml:"parameterized,omitempty"`
+	Cache                    *CacheSettings               `yaml:"cache,omitempty"`
+	Timeout                  string                       `yaml:"timeout,omitempty"`
+	Pagination               *PaginationSettings          `yaml:"pagination,omitempty"`
+	Columns                  []*ColumnDefinition          `yaml:"columns,omitempty"`
 	dbConnectionFactory      clay_sql.DBConnectionFactory `yaml:"-"`
 	renderedQuery            string
+	renderedArgs             []interface{}
 }
 
 func (s *SqlCommand) Metadata(
@@ -69,14 +86,28 @@ func (s *SqlCommand) Metadata(
 		return nil, errors.Wrapf(err, "Could not ping database")
 	}
 
-	query, err := s.RenderQuery(ctx, db, parsedLayers.GetDataMap())
+	query, args, err := s.RenderQueryWithArgs(ctx, db, parsedLayers.GetDataMap())
 	if err != nil {
 		return nil, errors.Wrapf(err, "Could not generate query")
 	}
 
-	return map[string]interface{}{
+	ret := map[string]interface{}{
 		"query": query,
-	}, nil
+		"args":  args,
+	}
+
+	page, err := s.GetPage(parsedLayers)
+	if err != nil {
+		return nil, err
+	}
+	if page != nil && !page.AllPages {
+		ret["next_cursor"], err = s.NextCursor(ctx, db, parsedLayers.GetDataMap(), page)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ret, nil
 }
 
 func (s *SqlCommand) String() string {
@@ -112,6 +143,44 @@ func WithSubQueries(subQueries map[string]string) SqlCommandOption {
 	}
 }
 
+// WithPartials sets the partials included by the command, and their templates by name.
+func WithPartials(includes []string, partials map[string]string) SqlCommandOption {
+	return func(s *SqlCommand) {
+		s.Includes = includes
+		s.Partials = partials
+	}
+}
+
+func WithParameterized(parameterized bool) SqlCommandOption {
+	return func(s *SqlCommand) {
+		s.Parameterized = parameterized
+	}
+}
+
+func WithCache(cache *CacheSettings) SqlCommandOption {
+	return func(s *SqlCommand) {
+		s.Cache = cache
+	}
+}
+
+func WithTimeout(timeout string) SqlCommandOption {
+	return func(s *SqlCommand) {
+		s.Timeout = timeout
+	}
+}
+
+func WithPagination(pagination *PaginationSettings) SqlCommandOption {
+	return func(s *SqlCommand) {
+		s.Pagination = pagination
+	}
+}
+
+func WithColumns(columns []*ColumnDefinition) SqlCommandOption {
+	return func(s *SqlCommand) {
+		s.Columns = columns
+	}
+}
+
 func NewSqlCommand(
 	description *cmds.CommandDescription,
 	options ...SqlCommandOption,
@@ -148,6 +217,14 @@ func NewSqlCommand(
 		option(ret)
 	}
 
+	if ret.Pagination != nil {
+		sqlPaginationParameterLayer, err := flags.NewSqlPaginationParameterLayer(ret.Pagination.PageSize)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not create pagination parameter layer")
+		}
+		description.Layers.AppendLayers(sqlPaginationParameterLayer)
+	}
+
 	return ret, nil
 }
 
@@ -160,6 +237,54 @@ func (s *SqlCommand) RunIntoGlazeProcessor(
 		return errors.New("dbConnectionFactory is not set")
 	}
 
+	printQuery := false
+	if printQuery_, ok := parsedLayers.GetParameter("sql-helpers", "print-query"); ok {
+		printQuery = printQuery_.Value.(bool)
+	}
+
+	explain_ := IsExplain(parsedLayers)
+
+	// streamed results are not cached, since that would mean keeping all the rows in memory
+	stream := IsStream(parsedLayers) && !printQuery && !explain_
+	if stream {
+		err := CheckStreamable(gp)
+		if err != nil {
+			return err
+		}
+	}
+
+	page, err := s.GetPage(parsedLayers)
+	if err != nil {
+		return err
+	}
+
+	// explaining a query doesn't use the cache, and neither does walking through all the pages
+	var qc *queryCache
+	if !printQuery && !explain_ && !stream && (page == nil || !page.AllPages) {
+		var err error
+		qc, err = s.openQueryCache(parsedLayers)
+		if err != nil {
+			return err
+		}
+	}
+	if qc != nil {
+		defer func(qc *queryCache) {
+			_ = qc.Close()
+		}(qc)
+
+		// the parameters key doesn't depend on the rendered query, so we can avoid connecting to the database
+		if qc.keyStrategy == CacheKeyParameters {
+			key, err := qc.parametersKey()
+			if err != nil {
+				return err
+			}
+			ok, err := qc.replay(ctx, key, gp)
+			if ok || err != nil {
+				return err
+			}
+		}
+	}
+
 	// at this point, the factory can probably be passed the sql-connection parsed layer
 	db, err := s.dbConnectionFactory(parsedLayers)
 	if err != nil {
@@ -176,30 +301,114 @@ func (s *SqlCommand) RunIntoGlazeProcessor(
 
 	dataMap := parsedLayers.GetDataMap()
 
-	printQuery := false
-	if printQuery_, ok := parsedLayers.GetParameter("sql-helpers", "print-query"); ok {
-		printQuery = printQuery_.Value.(bool)
+	if printQuery {
+		return s.PrintQuery(ctx, db, dataMap, page)
 	}
 
-	if printQuery {
-		return s.PrintQuery(ctx, db, dataMap)
+	explainer, err := GetExplainer(parsedLayers, db.DriverName())
+	if err != nil {
+		return err
 	}
 
-	return s.RunIntoGlazeProcessorWithDB(ctx, db, dataMap, gp)
+	timeout, err := GetTimeout(parsedLayers, s.Timeout)
+	if err != nil {
+		return err
+	}
+	connect := func() (*sqlx.DB, error) {
+		return s.dbConnectionFactory(parsedLayers)
+	}
+
+	return RunWithTimeout(ctx, db, timeout, connect, func(ctx context.Context) error {
+		if explainer != nil {
+			return s.ExplainIntoGlazeProcessorWithDB(ctx, db, dataMap, explainer, gp)
+		}
+		if qc != nil {
+			return s.runIntoGlazeProcessorWithCache(ctx, db, dataMap, page, qc, gp)
+		}
+		if stream {
+			progress := NewProgressProcessor(gp, os.Stderr)
+			defer progress.Finish()
+			gp = progress
+		}
+		if page != nil {
+			return s.runPagesIntoGlazeProcessorWithDB(ctx, db, dataMap, page, gp)
+		}
+		return s.RunIntoGlazeProcessorWithDB(ctx, db, dataMap, gp)
+	})
 }
 
+// runIntoGlazeProcessorWithCache outputs the cached rows if there is a valid cache entry,
+// and otherwise runs the query and stores its rows in the cache.
+func (s *SqlCommand) runIntoGlazeProcessorWithCache(
+	ctx context.Context,
+	db *sqlx.DB,
+	dataMap map[string]interface{},
+	page *Page,
+	qc *queryCache,
+	gp middlewares.Processor,
+) error {
+	var err error
+	s.renderedQuery, s.renderedArgs, err = s.RenderQueryWithArgs(ctx, db, dataMap)
+	if err != nil {
+		return errors.Wrapf(err, "Could not generate query")
+	}
+	if page != nil {
+		s.renderedQuery, s.renderedArgs = s.Pagination.pageQuery(db, s.renderedQuery, s.renderedArgs, page.Size, page.cursor)
+	}
+
+	var key string
+	if qc.keyStrategy == CacheKeyParameters {
+		key, err = qc.parametersKey()
+	} else {
+		key, err = qc.queryKey(s.renderedQuery, s.renderedArgs)
+		if err == nil {
+			var ok bool
+			ok, err = qc.replay(ctx, key, gp)
+			if ok {
+				return err
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	recorder := &recordingProcessor{Processor: gp}
+	err = s.RunQueryIntoGlaze(ctx, db, recorder)
+	if err != nil {
+		return errors.Wrapf(err, "Could not run query")
+	}
+	// a query interrupted by a timeout can stop without error, with only part of its rows
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	qc.save(ctx, key, s.renderedQuery, recorder.rows)
+
+	return nil
+}
+
+// PrintQuery prints the rendered query and its arguments. If page is not nil,
+// the query selecting that page is printed.
 func (s *SqlCommand) PrintQuery(
 	ctx context.Context,
 	db *sqlx.DB,
 	dataMap map[string]interface{},
+	page *Page,
 ) error {
 	var err error
-	s.renderedQuery, err = s.RenderQuery(ctx, db, dataMap)
+	s.renderedQuery, s.renderedArgs, err = s.RenderQueryWithArgs(ctx, db, dataMap)
 	if err != nil {
 		return errors.Wrapf(err, "Could not generate query")
 	}
+	if page != nil {
+		s.renderedQuery, s.renderedArgs = s.Pagination.pageQuery(db, s.renderedQuery, s.renderedArgs, page.Size, page.cursor)
+	}
 
 	fmt.Println(s.renderedQuery)
+	if len(s.renderedArgs) > 0 {
+		fmt.Println("Args:")
+		fmt.Println(s.renderedArgs)
+	}
 	return &cmds.ExitWithoutGlazeError{}
 }
 
@@ -210,7 +419,7 @@ func (s *SqlCommand) RunIntoGlazeProcessorWithDB(
 	gp middlewares.Processor,
 ) error {
 	var err error
-	s.renderedQuery, err = s.RenderQuery(ctx, db, dataMap)
+	s.renderedQuery, s.renderedArgs, err = s.RenderQueryWithArgs(ctx, db, dataMap)
 	if err != nil {
 		return errors.Wrapf(err, "Could not generate query")
 	}
@@ -223,6 +432,7 @@ func (s *SqlCommand) RunIntoGlazeProcessorWithDB(
 	return nil
 }
 
+// RenderQueryFull connects to the database and renders the query, see RenderQuery.
 func (s *SqlCommand) RenderQueryFull(
 	ctx context.Context,
 	parsedLayers *layers.ParsedLayers,
@@ -260,25 +470,43 @@ func (s *SqlCommand) IsValid() bool {
 	return s.Name != "" && s.Query != "" && s.Short != ""
 }
 
+// RenderQuery renders the query, dropping the arguments bound by the template: the `?` placeholders
+// of parameterized commands and of sqlBind are left without their values. Use RenderQueryWithArgs
+// to render a query that is going to be run.
 func (s *SqlCommand) RenderQuery(
 	ctx context.Context,
 	db *sqlx.DB,
 	ps map[string]interface{},
 ) (string, error) {
-	ret, err := clay_sql.RenderQuery(ctx, db, s.Query, s.SubQueries, ps)
+	ret, _, err := s.RenderQueryWithArgs(ctx, db, ps)
 	if err != nil {
-		return "", errors.Wrap(err, "Could not render query")
+		return "", err
 	}
 
 	return ret, nil
 }
 
+// RenderQueryWithArgs renders the query and returns the arguments bound by the template,
+// which have to be passed to the driver alongside the query.
+func (s *SqlCommand) RenderQueryWithArgs(
+	ctx context.Context,
+	db *sqlx.DB,
+	ps map[string]interface{},
+) (string, []interface{}, error) {
+	ret, args, err := renderQueryWithArgs(ctx, db, s.Query, s.SubQueries, s.Partials, ps, s.Parameterized)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "Could not render query")
+	}
+
+	return ret, args, nil
+}
+
 // RunQueryIntoGlaze runs the query and processes the results into Glaze.
-// This requires RenderQuery to be invoked first in order to have a s.renderedQuery.
+// This requires RenderQuery to be invoked first in order to have a s.renderedQuery and s.renderedArgs.
 // NOTE(manuel, 2024-04-11) This really could benefit of a further cleanup, what with codegen now
 func (s *SqlCommand) RunQueryIntoGlaze(
 	ctx context.Context,
 	db *sqlx.DB,
 	gp middlewares.Processor) error {
-	return clay_sql.RunQueryIntoGlaze(ctx, db, s.renderedQuery, []interface{}{}, gp)
+	return clay_sql.RunQueryIntoGlaze(ctx, db, s.renderedQuery, s.renderedArgs, gp)
 }
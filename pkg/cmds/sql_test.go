@@ -16,13 +16,13 @@ import (
 	"testing"
 
 	// sqlite
-	_ "github.com/mattn/go-sqlite3"
+	_ "modernc.org/sqlite"
 )
 
 // Here we do a bunch of unit tests in a pretty end to end style by using an in memory SQLite database.
 
 func createDB(_ *layers.ParsedLayers) (*sqlx.DB, error) {
-	db, err := sqlx.Connect("sqlite3", ":memory:")
+	db, err := sqlx.Connect("sqlite", ":memory:")
 	if err != nil {
 		return nil, err
 	}
@@ -466,3 +466,75 @@ func TestMapSubQuery(t *testing.T) {
 	assert.Equal(t, "test1", name)
 
 }
+
+func TestParameterizedRender(t *testing.T) {
+	s, err := NewSqlCommand(
+		cmds.NewCommandDescription("test"),
+		WithDbConnectionFactory(createDB),
+		WithQuery(`
+	SELECT * FROM test
+	WHERE name = {{ .name | sqlString }}
+	AND id IN ({{ .ids | sqlIntIn }})
+`,
+		),
+		WithParameterized(true),
+	)
+	require.NoError(t, err)
+
+	query, args, err := s.RenderQueryWithArgs(context.Background(), nil, map[string]interface{}{
+		"name": "test1' OR 1=1 --",
+		"ids":  []int{1, 2},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, sql.CleanQuery(`
+	SELECT * FROM test
+	WHERE name = ?
+	AND id IN (?, ?)
+`), query)
+	assert.Equal(t, []interface{}{"test1' OR 1=1 --", 1, 2}, args)
+}
+
+func TestSqlBindRender(t *testing.T) {
+	s, err := NewSqlCommand(
+		cmds.NewCommandDescription("test"),
+		WithDbConnectionFactory(createDB),
+		WithQuery(`SELECT * FROM test WHERE name = {{ .name | sqlBind }} AND table_name = {{ .name | sqlString }}`),
+	)
+	require.NoError(t, err)
+
+	query, args, err := s.RenderQueryWithArgs(context.Background(), nil, map[string]interface{}{
+		"name": "test1",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM test WHERE name = ? AND table_name = 'test1'", query)
+	assert.Equal(t, []interface{}{"test1"}, args)
+}
+
+func TestParameterizedRun(t *testing.T) {
+	s, err := NewSqlCommand(
+		cmds.NewCommandDescription("test"),
+		WithDbConnectionFactory(createDB),
+		WithQuery(`SELECT * FROM test WHERE name = {{ .test | sqlString }} OR name = {{ .name | sqlString }}`),
+		WithParameterized(true),
+	)
+	require.NoError(t, err)
+
+	parsedLayers, err := makeSimpleDefaultLayer(
+		layers.WithParsedParameterValue("name", "test1' OR '1'='1"),
+		layers.WithParsedParameterValue("test", "test2"),
+	)
+	require.NoError(t, err)
+
+	gp := middlewares.NewTableProcessor()
+	gp.AddTableMiddleware(&table.NullTableMiddleware{})
+	ctx := context.Background()
+	err = s.RunIntoGlazeProcessor(ctx, parsedLayers, gp)
+	require.NoError(t, err)
+
+	err = gp.Close(ctx)
+	require.NoError(t, err)
+
+	assert2.EqualRows(t, []types.Row{
+		types.NewRow(types.MRP("id", int64(2)), types.MRP("name", "test2")),
+	}, gp.GetTable().Rows)
+}
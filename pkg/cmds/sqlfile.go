@@ -0,0 +1,124 @@
+package cmds
+
+import (
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/middlewares"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/pkg/errors"
+	"io/fs"
+	"strings"
+)
+
+// SqlFile is a .sql file whose leading comment holds the YAML description of a command
+// (name, short, flags, ...), the rest of the file being its query. The front matter is
+// either a block comment:
+//
+//	/* ---
+//	name: ls-posts
+//	short: Show all posts
+//	--- */
+//	SELECT * FROM posts
+//
+// or a run of line comments:
+//
+//	-- ---
+//	-- name: ls-posts
+//	-- short: Show all posts
+//	-- ---
+//	SELECT * FROM posts
+//
+// Since the front matter is a comment, the file stays valid SQL.
+type SqlFile struct {
+	// FrontMatter is the YAML of the front matter, preceded by empty lines
+	// so that the lines of the YAML are the lines of the file.
+	FrontMatter string
+	Query       string
+	// QueryLine is the line of the file on which the query starts.
+	QueryLine int
+}
+
+const (
+	blockFrontMatterStart = "/* ---"
+	blockFrontMatterEnd   = "--- */"
+	lineFrontMatterMarker = "-- ---"
+)
+
+func IsSqlFileName(fileName string) bool {
+	return strings.HasSuffix(fileName, ".sql")
+}
+
+// ParseSqlFile splits the content of a .sql file into its front matter and its query.
+// It returns nil if the file doesn't start with front matter.
+func ParseSqlFile(content string) (*SqlFile, error) {
+	lines := strings.Split(content, "\n")
+	start := 0
+	for start < len(lines) && strings.TrimSpace(lines[start]) == "" {
+		start++
+	}
+	if start == len(lines) {
+		return nil, nil
+	}
+
+	frontMatter := []string{}
+	end := -1
+	switch strings.TrimSpace(lines[start]) {
+	case blockFrontMatterStart:
+		for i := start + 1; i < len(lines); i++ {
+			if strings.TrimSpace(lines[i]) == blockFrontMatterEnd {
+				end = i
+				break
+			}
+			frontMatter = append(frontMatter, lines[i])
+		}
+		if end == -1 {
+			return nil, errors.Errorf("front matter starting on line %d is not terminated by %s",
+				start+1, blockFrontMatterEnd)
+		}
+	case lineFrontMatterMarker:
+		for i := start + 1; i < len(lines); i++ {
+			line := strings.TrimRight(lines[i], "\r")
+			if strings.TrimSpace(line) == lineFrontMatterMarker {
+				end = i
+				break
+			}
+			if !strings.HasPrefix(line, "--") {
+				break
+			}
+			line = strings.TrimPrefix(line, "--")
+			frontMatter = append(frontMatter, strings.TrimPrefix(line, " "))
+		}
+		if end == -1 {
+			return nil, errors.Errorf("front matter starting on line %d is not terminated by %s",
+				start+1, lineFrontMatterMarker)
+		}
+	default:
+		return nil, nil
+	}
+
+	return &SqlFile{
+		FrontMatter: strings.Repeat("\n", start+1) + strings.Join(frontMatter, "\n"),
+		Query:       strings.Join(lines[end+1:], "\n"),
+		QueryLine:   end + 2,
+	}, nil
+}
+
+// SqlFileDefaults returns the default values of the parameters declared by the front matter
+// of the .sql file content, read from fileName in f, keyed by parameter name. This is how
+// `sqleton run` renders such a file as a script, without parsing its flags from the command line.
+// f is used to resolve the partials and layers the command uses, and can be nil for files read from stdin.
+func SqlFileDefaults(f fs.FS, fileName string, content string) (map[string]interface{}, error) {
+	scl := &SqlCommandLoader{}
+	commands, err := scl.loadSqlCommandFromSqlContent(f, fileName, content, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	parsedLayers := layers.NewParsedLayers()
+	err = middlewares.ExecuteMiddlewares(commands[0].Description().Layers, parsedLayers,
+		middlewares.SetFromDefaults(parameters.WithParseStepSource(parameters.SourceDefaults)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return parsedLayers.GetDataMap(), nil
+}
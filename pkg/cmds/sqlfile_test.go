@@ -0,0 +1,120 @@
+package cmds
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"testing/fstest"
+)
+
+func TestParseSqlFile(t *testing.T) {
+	sf, err := ParseSqlFile(`
+/* ---
+name: ls
+short: List
+--- */
+SELECT * FROM test
+`)
+	require.NoError(t, err)
+	require.NotNil(t, sf)
+	assert.Equal(t, "\n\nname: ls\nshort: List", sf.FrontMatter)
+	assert.Equal(t, "SELECT * FROM test\n", sf.Query)
+	assert.Equal(t, 6, sf.QueryLine)
+
+	sf, err = ParseSqlFile(`-- ---
+-- name: ls
+-- flags:
+--   - name: id
+--     type: int
+-- ---
+SELECT * FROM test WHERE id = {{ .id }}`)
+	require.NoError(t, err)
+	require.NotNil(t, sf)
+	assert.Equal(t, "\nname: ls\nflags:\n  - name: id\n    type: int", sf.FrontMatter)
+	assert.Equal(t, "SELECT * FROM test WHERE id = {{ .id }}", sf.Query)
+	assert.Equal(t, 7, sf.QueryLine)
+
+	sf, err = ParseSqlFile("-- just a script\nSELECT 1")
+	require.NoError(t, err)
+	assert.Nil(t, sf)
+
+	_, err = ParseSqlFile("-- ---\n-- name: ls\nSELECT 1")
+	assert.EqualError(t, err, "front matter starting on line 1 is not terminated by -- ---")
+}
+
+func TestLoadSqlFile(t *testing.T) {
+	f := fstest.MapFS{
+		"ls.sql": &fstest.MapFile{Data: []byte(`/* ---
+name: ls
+short: List
+flags:
+  - name: name
+    type: string
+--- */
+SELECT id FROM test WHERE name = {{ .name | sqlString }}
+`)},
+		"script.sql": &fstest.MapFile{Data: []byte("SELECT 1;\n")},
+		"typo.sql":   &fstest.MapFile{Data: []byte("-- ---\n-- name: ls\n-- shrt: List\n-- ---\nSELECT 1")},
+		"query.sql":  &fstest.MapFile{Data: []byte("/* ---\nname: ls\nquery: SELECT 2\n--- */\nSELECT 1")},
+	}
+	loader := &SqlCommandLoader{DBConnectionFactory: createDB}
+
+	assert.True(t, loader.IsFileSupported(f, "ls.sql"))
+	assert.False(t, loader.IsFileSupported(f, "script.sql"))
+
+	commands, err := loader.LoadCommands(f, "ls.sql", nil, nil)
+	require.NoError(t, err)
+	require.Len(t, commands, 1)
+	s, ok := commands[0].(*SqlCommand)
+	require.True(t, ok)
+	assert.Equal(t, "ls", s.Description().Name)
+	assert.Equal(t, "List", s.Description().Short)
+
+	query, err := s.RenderQuery(context.Background(), nil, map[string]interface{}{"name": "test1"})
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT id FROM test WHERE name = 'test1'", query)
+
+	_, err = loader.LoadCommands(f, "typo.sql", nil, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "line 3: field shrt not found")
+
+	_, err = loader.LoadCommands(f, "query.sql", nil, nil)
+	assert.Error(t, err)
+}
+
+func TestSqlFileDefaults(t *testing.T) {
+	f := fstest.MapFS{
+		"_layers/rng.yaml": &fstest.MapFile{Data: []byte(`slug: rng
+name: Range
+flags:
+  - name: top
+    type: int
+    default: 5
+`)},
+		"ls.sql": &fstest.MapFile{Data: []byte(`-- ---
+-- name: ls
+-- short: List
+-- use-layers: [rng]
+-- flags:
+--   - name: name
+--     type: string
+--     default: test1
+-- arguments:
+--   - name: ids
+--     type: intList
+-- ---
+SELECT id FROM test WHERE name = {{ .name | sqlString }} LIMIT {{ .top }}
+`)},
+	}
+
+	defaults, err := SqlFileDefaults(f, "ls.sql", string(f["ls.sql"].Data))
+	require.NoError(t, err)
+	assert.Equal(t, "test1", defaults["name"])
+	assert.Equal(t, 5, defaults["top"])
+	assert.NotContains(t, defaults, "ids")
+
+	// files read from stdin can't use the layers of a repository
+	_, err = SqlFileDefaults(nil, "-", string(f["ls.sql"].Data))
+	assert.Error(t, err)
+}
@@ -0,0 +1,113 @@
+package cmds
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/middlewares/table"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/mattn/go-isatty"
+	"github.com/pkg/errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// progressInterval is how often the progress counter is refreshed.
+const progressInterval = 200 * time.Millisecond
+
+// IsStream returns true if --stream was passed.
+func IsStream(parsedLayers *layers.ParsedLayers) bool {
+	stream, ok := parsedLayers.GetParameter(settings.GlazedSlug, "stream")
+	if !ok {
+		return false
+	}
+	ret, _ := stream.Value.(bool)
+	return ret
+}
+
+// CheckStreamable returns an error if gp collects the full table before outputting it,
+// which would make streaming pointless: table middlewares (sorting, table jq expressions,
+// table output formats) only run once all the rows have been added.
+func CheckStreamable(gp middlewares.Processor) error {
+	tp, ok := gp.(*middlewares.TableProcessor)
+	if !ok || len(tp.TableMiddlewares) == 0 {
+		return nil
+	}
+
+	reasons := []string{}
+	for _, tm := range tp.TableMiddlewares {
+		switch tm.(type) {
+		case *table.SortByMiddleware:
+			reasons = append(reasons, "sorting rows (--sort-by)")
+		case *table.OutputMiddleware:
+			reasons = append(reasons, "this output format (use --output csv, tsv, json or yaml)")
+		default:
+			reasons = append(reasons, fmt.Sprintf("%T", tm))
+		}
+	}
+
+	return errors.Errorf("--stream can't be used with options that need the full table: %s",
+		strings.Join(reasons, ", "))
+}
+
+// ProgressProcessor counts the rows going through a Processor and reports them on w.
+// On a terminal, the counter is refreshed in place while the rows come in,
+// otherwise only the final count is printed.
+type ProgressProcessor struct {
+	middlewares.Processor
+	w          io.Writer
+	isTerminal bool
+	count      int64
+	start      time.Time
+	lastUpdate time.Time
+}
+
+func NewProgressProcessor(gp middlewares.Processor, w io.Writer) *ProgressProcessor {
+	ret := &ProgressProcessor{
+		Processor: gp,
+		w:         w,
+		start:     time.Now(),
+	}
+	if f, ok := w.(*os.File); ok {
+		ret.isTerminal = isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+	}
+	return ret
+}
+
+func (p *ProgressProcessor) AddRow(ctx context.Context, row types.Row) error {
+	p.count++
+	if p.isTerminal {
+		if now := time.Now(); now.Sub(p.lastUpdate) >= progressInterval {
+			p.lastUpdate = now
+			_, _ = fmt.Fprintf(p.w, "\r%s", p.status(now))
+		}
+	}
+	return p.Processor.AddRow(ctx, row)
+}
+
+// Count returns the number of rows seen so far.
+func (p *ProgressProcessor) Count() int64 {
+	return p.count
+}
+
+// Finish prints the final row count.
+func (p *ProgressProcessor) Finish() {
+	prefix := ""
+	if p.isTerminal {
+		prefix = "\r"
+	}
+	_, _ = fmt.Fprintf(p.w, "%s%s\n", prefix, p.status(time.Now()))
+}
+
+func (p *ProgressProcessor) status(now time.Time) string {
+	elapsed := now.Sub(p.start)
+	rate := float64(0)
+	if elapsed > 0 {
+		rate = float64(p.count) / elapsed.Seconds()
+	}
+	return fmt.Sprintf("streamed %d rows in %s (%.0f rows/s)", p.count, elapsed.Round(time.Millisecond), rate)
+}
@@ -0,0 +1,41 @@
+package cmds
+
+import (
+	"bytes"
+	"context"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/middlewares/table"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestCheckStreamable(t *testing.T) {
+	gp := middlewares.NewTableProcessor()
+	assert.NoError(t, CheckStreamable(gp))
+
+	gp.AddTableMiddleware(table.NewSortByMiddlewareFromColumns("name"))
+	err := CheckStreamable(gp)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--sort-by")
+}
+
+func TestProgressProcessor(t *testing.T) {
+	gp := middlewares.NewTableProcessor()
+	gp.AddTableMiddleware(&table.NullTableMiddleware{})
+	w := &bytes.Buffer{}
+	progress := NewProgressProcessor(gp, w)
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, progress.AddRow(ctx, types.NewRow(types.MRP("i", i))))
+	}
+	progress.Finish()
+
+	assert.Equal(t, int64(3), progress.Count())
+	assert.Len(t, gp.GetTable().Rows, 3)
+	// a buffer is not a terminal, so only the final count is printed
+	assert.Contains(t, w.String(), "streamed 3 rows")
+	assert.NotContains(t, w.String(), "\r")
+}
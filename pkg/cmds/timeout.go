@@ -0,0 +1,197 @@
+package cmds
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/sqleton/pkg/flags"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"sync"
+	"time"
+)
+
+// serverCancelTimeout bounds the time spent cancelling a timed out query on the server.
+const serverCancelTimeout = 5 * time.Second
+
+// QueryTimeoutError is returned when a command runs longer than its timeout.
+type QueryTimeoutError struct {
+	Timeout time.Duration
+	// CancelledOnServer is true if the running query was cancelled on the server,
+	// and not only abandoned by the client.
+	CancelledOnServer bool
+}
+
+func (e *QueryTimeoutError) Error() string {
+	if e.CancelledOnServer {
+		return fmt.Sprintf("query timed out after %s and was cancelled on the server", e.Timeout)
+	}
+	return fmt.Sprintf("query timed out after %s", e.Timeout)
+}
+
+// ParseTimeout parses a timeout given as a duration (30s, 2m). An empty string means no timeout.
+func ParseTimeout(timeout string) (time.Duration, error) {
+	if timeout == "" {
+		return 0, nil
+	}
+	ret, err := time.ParseDuration(timeout)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid timeout %s", timeout)
+	}
+	if ret < 0 {
+		return 0, errors.Errorf("timeout must not be negative, got %s", timeout)
+	}
+	return ret, nil
+}
+
+// GetTimeout returns the timeout passed with --timeout, falling back to defaultTimeout
+// (usually the `timeout:` of the command YAML) if the flag is not set.
+func GetTimeout(parsedLayers *layers.ParsedLayers, defaultTimeout string) (time.Duration, error) {
+	timeout := defaultTimeout
+	if timeout_, ok := parsedLayers.GetParameter(flags.SqlHelpersSlug, "timeout"); ok {
+		if s, ok := timeout_.Value.(string); ok && s != "" {
+			timeout = s
+		}
+	}
+	return ParseTimeout(timeout)
+}
+
+// ServerCanceller cancels the query running on a database handle on the server side.
+//
+// Cancelling the context of a query only makes the client stop waiting: the MySQL driver
+// closes the connection, and the server carries on running the query. The canceller
+// pins the database handle to a single connection whose server side id is known,
+// so that the query can be killed from a second connection.
+type ServerCanceller struct {
+	driverName string
+	backendID  int64
+	connect    func() (*sqlx.DB, error)
+}
+
+// NewServerCanceller limits db to a single connection and retrieves its id.
+// connect opens the connection used to issue the cancellation.
+//
+// Drivers that don't need a server side cancel (sqlite interrupts the query when
+// the context is cancelled) get a canceller that does nothing.
+func NewServerCanceller(
+	ctx context.Context,
+	db *sqlx.DB,
+	connect func() (*sqlx.DB, error),
+) (*ServerCanceller, error) {
+	ret := &ServerCanceller{
+		driverName: db.DriverName(),
+		connect:    connect,
+	}
+
+	var idQuery string
+	switch ret.driverName {
+	case "mysql":
+		idQuery = "SELECT CONNECTION_ID()"
+	case "postgres", "pgx":
+		idQuery = "SELECT pg_backend_pid()"
+	default:
+		return ret, nil
+	}
+
+	// all the queries of the command have to run on the connection we cancel
+	db.SetMaxOpenConns(1)
+	err := db.GetContext(ctx, &ret.backendID, idQuery)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get connection id")
+	}
+
+	return ret, nil
+}
+
+// CanCancel returns true if Cancel issues a cancellation on the server.
+func (c *ServerCanceller) CanCancel() bool {
+	return c.backendID != 0 && c.connect != nil
+}
+
+// Cancel cancels the query currently running on the pinned connection.
+func (c *ServerCanceller) Cancel(ctx context.Context) error {
+	if !c.CanCancel() {
+		return nil
+	}
+
+	db, err := c.connect()
+	if err != nil {
+		return errors.Wrap(err, "could not open connection to cancel query")
+	}
+	defer func(db *sqlx.DB) {
+		_ = db.Close()
+	}(db)
+
+	switch c.driverName {
+	case "mysql":
+		_, err = db.ExecContext(ctx, fmt.Sprintf("KILL QUERY %d", c.backendID))
+	default:
+		_, err = db.ExecContext(ctx, "SELECT pg_cancel_backend($1)", c.backendID)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "could not cancel query on connection %d", c.backendID)
+	}
+	return nil
+}
+
+// RunWithTimeout runs f with a context that expires after timeout, if timeout is not 0.
+// When the timeout expires, the query running on db is cancelled on the server
+// (see ServerCanceller) and a *QueryTimeoutError is returned.
+func RunWithTimeout(
+	ctx context.Context,
+	db *sqlx.DB,
+	timeout time.Duration,
+	connect func() (*sqlx.DB, error),
+	f func(ctx context.Context) error,
+) error {
+	if timeout <= 0 {
+		return f(ctx)
+	}
+
+	canceller, err := NewServerCanceller(ctx, db, connect)
+	if err != nil {
+		return err
+	}
+
+	ctx_, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan struct{})
+	cancelledOnServer := false
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		select {
+		case <-done:
+		case <-ctx_.Done():
+			if ctx_.Err() != context.DeadlineExceeded || !canceller.CanCancel() {
+				return
+			}
+			cancelCtx, cancelCancel := context.WithTimeout(context.Background(), serverCancelTimeout)
+			defer cancelCancel()
+			err := canceller.Cancel(cancelCtx)
+			if err != nil {
+				log.Warn().Err(err).Msg("Could not cancel timed out query on the server")
+				return
+			}
+			cancelledOnServer = true
+		}
+	}()
+
+	err = f(ctx_)
+	close(done)
+	wg.Wait()
+
+	// An interrupted query doesn't necessarily surface as an error (the row loop of
+	// clay's RunQueryIntoGlaze stops silently), so an expired deadline always means
+	// the results are incomplete. The parent context being cancelled (Ctrl-C) is not a timeout.
+	if errors.Is(ctx_.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		return &QueryTimeoutError{
+			Timeout:           timeout,
+			CancelledOnServer: cancelledOnServer,
+		}
+	}
+	return err
+}
@@ -0,0 +1,105 @@
+package cmds
+
+import (
+	"context"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/alias"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/middlewares/table"
+	"github.com/go-go-golems/sqleton/pkg/flags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+const slowQuery = `
+WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c WHERE x < 1000000000)
+SELECT count(*) AS n FROM c`
+
+func makeHelpersLayers(t *testing.T, options ...layers.ParsedLayerOption) *layers.ParsedLayers {
+	helpersLayer, err := flags.NewSqlHelpersParameterLayer()
+	require.NoError(t, err)
+	parsedHelpersLayer, err := layers.NewParsedLayer(helpersLayer, options...)
+	require.NoError(t, err)
+	return layers.NewParsedLayers(layers.WithParsedLayer(flags.SqlHelpersSlug, parsedHelpersLayer))
+}
+
+func TestParseTimeout(t *testing.T) {
+	timeout, err := ParseTimeout("")
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(0), timeout)
+
+	timeout, err = ParseTimeout("1m30s")
+	require.NoError(t, err)
+	assert.Equal(t, 90*time.Second, timeout)
+
+	_, err = ParseTimeout("30")
+	assert.Error(t, err)
+	_, err = ParseTimeout("-1s")
+	assert.Error(t, err)
+}
+
+func TestGetTimeoutFlagOverridesCommand(t *testing.T) {
+	timeout, err := GetTimeout(makeHelpersLayers(t), "10s")
+	require.NoError(t, err)
+	assert.Equal(t, 10*time.Second, timeout)
+
+	timeout, err = GetTimeout(makeHelpersLayers(t, layers.WithParsedParameterValue("timeout", "2s")), "10s")
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Second, timeout)
+}
+
+func TestSqlCommandTimeout(t *testing.T) {
+	s, err := NewSqlCommand(
+		cmds.NewCommandDescription("slow"),
+		WithDbConnectionFactory(createDB),
+		WithQuery(slowQuery),
+		WithTimeout("50ms"),
+	)
+	require.NoError(t, err)
+
+	gp := middlewares.NewTableProcessor()
+	gp.AddTableMiddleware(&table.NullTableMiddleware{})
+	start := time.Now()
+	err = s.RunIntoGlazeProcessor(context.Background(), makeHelpersLayers(t), gp)
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), 10*time.Second)
+
+	timeoutErr, ok := err.(*QueryTimeoutError)
+	require.True(t, ok, "expected a timeout error, got %v", err)
+	assert.Equal(t, 50*time.Millisecond, timeoutErr.Timeout)
+	// sqlite interrupts the query itself
+	assert.False(t, timeoutErr.CancelledOnServer)
+}
+
+func TestRunWithTimeoutSuccess(t *testing.T) {
+	db, err := createDB(nil)
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+
+	ran := false
+	err = RunWithTimeout(context.Background(), db, time.Minute, nil, func(ctx context.Context) error {
+		_, hasDeadline := ctx.Deadline()
+		assert.True(t, hasDeadline)
+		ran = true
+		return nil
+	})
+	require.NoError(t, err)
+	assert.True(t, ran)
+}
+
+func TestLoadCommandInvalidTimeout(t *testing.T) {
+	loader := &SqlCommandLoader{}
+	_, err := loader.loadSqlCommandFromReader(strings.NewReader(`
+name: slow
+short: Slow query
+timeout: forever
+query: SELECT 1
+`), []cmds.CommandDescriptionOption{}, []alias.Option{})
+	assert.Error(t, err)
+}
@@ -2,23 +2,85 @@ package codegen
 
 import (
 	"github.com/dave/jennifer/jen"
-	cmds2 "github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/clay/pkg/sql"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
 	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
 	"github.com/go-go-golems/glazed/pkg/codegen"
+	"github.com/go-go-golems/glazed/pkg/settings"
 	"github.com/go-go-golems/sqleton/pkg/cmds"
+	"github.com/go-go-golems/sqleton/pkg/flags"
 	"github.com/iancoleman/strcase"
+	"github.com/pkg/errors"
+	"reflect"
+	"strings"
 )
 
 type SqlCommandCodeGenerator struct {
 	PackageName string
+	// HTTP adds a Handler method to the generated commands, see GenerateHandlersCode.
+	HTTP bool
 }
 
 const SqletonCmdsPath = "github.com/go-go-golems/sqleton/pkg/cmds"
+const SqletonConnectionPath = "github.com/go-go-golems/sqleton/pkg/connection"
+const GlazedLayersPath = "github.com/go-go-golems/glazed/pkg/cmds/layers"
+const GlazedSettingsPath = "github.com/go-go-golems/glazed/pkg/settings"
+
+// commandName returns the name the generated identifiers of the command are derived from,
+// which includes its parents to keep the commands of different directories apart.
+func commandName(cmd *cmds.SqlCommand) string {
+	return strcase.ToLowerCamel(strings.Join(commandPath(cmd), " "))
+}
+
+// commandPath returns the parents of the command followed by its name. As in cobra, the name is the first word
+// of the name of the description, so that names like "ls-posts-type [types...]" give ls-posts-type.
+func commandPath(cmd *cmds.SqlCommand) []string {
+	description := cmd.Description()
+	name := description.Name
+	if fields := strings.Fields(name); len(fields) > 0 {
+		name = fields[0]
+	}
+	return append(append([]string{}, description.Parents...), name)
+}
+
+// settingsLayers are the layers added by sqleton to all the commands. The generated commands
+// add the connection and output layers themselves, and don't support the other ones.
+var settingsLayers = map[string]bool{
+	sql.SqlConnectionSlug:   true,
+	sql.DbtSlug:             true,
+	settings.GlazedSlug:     true,
+	flags.SqlHelpersSlug:    true,
+	flags.SqlCacheSlug:      true,
+	flags.SqlPaginationSlug: true,
+}
+
+// checkConvertible returns an error if the generated code of the command wouldn't behave like the command.
+func checkConvertible(cmd *cmds.SqlCommand) error {
+	if cmd.Pagination != nil {
+		return errors.Errorf("command %s: paginated commands can't be converted", cmd.Description().Name)
+	}
+	return nil
+}
+
+// commandFlags returns the flags of the generated command: the flags of the command, followed by
+// those of the layers it uses (use-layers: and layers:), which the generated command declares as its own.
+func commandFlags(cmd *cmds.SqlCommand) *parameters.ParameterDefinitions {
+	ret := cmd.GetDefaultFlags()
+	cmd.Description().Layers.ForEach(func(slug string, l layers.ParameterLayer) {
+		if slug == layers.DefaultSlug || settingsLayers[slug] {
+			return
+		}
+		l.GetParameterDefinitions().GetFlags().ForEach(func(pd *parameters.ParameterDefinition) {
+			ret.Set(pd.Name, pd)
+		})
+	})
+	return ret
+}
 
 func (s *SqlCommandCodeGenerator) defineConstants(f *jen.File, cmdName string, cmd *cmds.SqlCommand) {
-	// Define the constant for the main query.
+	// Define the constant for the main query, along with the partials it includes.
 	queryConstName := strcase.ToLowerCamel(cmdName) + "CommandQuery"
-	f.Const().Id(queryConstName).Op("=").Lit(cmd.Query)
+	f.Const().Id(queryConstName).Op("=").Lit(cmd.QueryWithPartials())
 
 	if len(cmd.SubQueries) > 0 {
 		for name, subQuery := range cmd.SubQueries {
@@ -40,35 +102,52 @@ func (s *SqlCommandCodeGenerator) defineStruct(f *jen.File, cmdName string) {
 func (s *SqlCommandCodeGenerator) defineParametersStruct(
 	f *jen.File,
 	cmdName string,
-	cmd *cmds2.CommandDescription,
+	cmd *cmds.SqlCommand,
 ) {
 	structName := strcase.ToCamel(cmdName) + "CommandParameters"
+	// the fields are initialized from the parsed parameters by their glazed.parameter tag,
+	// which has to be the name of the parameter: a snake cased tag leaves from-date unset
 	f.Type().Id(structName).StructFunc(func(g *jen.Group) {
-		cmd.GetDefaultFlags().ForEach(func(flag *parameters.ParameterDefinition) {
+		commandFlags(cmd).ForEach(func(flag *parameters.ParameterDefinition) {
 			s := g.Id(strcase.ToCamel(flag.Name))
 			s = codegen.FlagTypeToGoType(s, flag.Type)
-			s.Tag(map[string]string{"glazed.parameter": strcase.ToSnake(flag.Name)})
+			s.Tag(map[string]string{"glazed.parameter": flag.Name})
 		})
 		cmd.GetDefaultArguments().ForEach(func(arg *parameters.ParameterDefinition) {
 			s := g.Id(strcase.ToCamel(arg.Name))
 			s = codegen.FlagTypeToGoType(s, arg.Type)
-			s.Tag(map[string]string{"glazed.parameter": strcase.ToSnake(arg.Name)})
+			s.Tag(map[string]string{"glazed.parameter": arg.Name})
 		})
 	})
 }
 
-func (s *SqlCommandCodeGenerator) renderQuery() []jen.Code {
+// parametersMap returns the map of parameter values passed to the query template,
+// keyed by the names of the flags and arguments the template refers to.
+func parametersMap(cmd *cmds.SqlCommand) jen.Code {
+	return jen.Map(jen.String()).Interface().Values(jen.DictFunc(func(d jen.Dict) {
+		add := func(pd *parameters.ParameterDefinition) {
+			d[jen.Lit(pd.Name)] = jen.Id("params").Dot(strcase.ToCamel(pd.Name))
+		}
+		commandFlags(cmd).ForEach(add)
+		cmd.GetDefaultArguments().ForEach(add)
+	}))
+}
+
+// renderQuery returns the statements rendering the query into renderedQuery and args, running the returnErr
+// statements if the rendering fails. The query is rendered with sqleton's helpers, so that sqlBind and sqlBindIn
+// are available even if the command is not parameterized.
+func (s *SqlCommandCodeGenerator) renderQuery(cmd *cmds.SqlCommand, returnErr ...jen.Code) []jen.Code {
 	return []jen.Code{
-		jen.Id("ps").Op(":=").Qual(codegen.MapsHelpersPath, "StructToMap").Call(jen.Id("params"), jen.Lit(false)),
-		jen.List(jen.Id("renderedQuery"), jen.Err()).Op(":=").Qual(codegen.ClaySqlPath, "RenderQuery").Call(
-			jen.Id("ctx"), jen.Id("db"), jen.Id("p").Dot("Query"), jen.Id("p").Dot("SubQueries"), jen.Id("ps"),
+		jen.Id("ps").Op(":=").Add(parametersMap(cmd)),
+		jen.List(jen.Id("renderedQuery"), jen.Id("args"), jen.Err()).Op(":=").Qual(SqletonCmdsPath, "RenderQueryWithArgs").Call(
+			jen.Id("ctx"), jen.Id("db"), jen.Id("p").Dot("Query"), jen.Id("p").Dot("SubQueries"), jen.Id("ps"), jen.Lit(cmd.Parameterized),
 		),
-		jen.If(jen.Err().Op("!=").Nil()).Block(jen.Return(jen.Err())),
+		jen.If(jen.Err().Op("!=").Nil()).Block(returnErr...),
 		jen.Line(),
 	}
 }
 
-func (s *SqlCommandCodeGenerator) defineRunIntoGlazedMethod(f *jen.File, cmdName string) {
+func (s *SqlCommandCodeGenerator) defineRunIntoGlazedMethod(f *jen.File, cmdName string, cmd *cmds.SqlCommand) {
 	methodName := "RunIntoGlazed"
 	receiver := strcase.ToCamel(cmdName) + "Command"
 	parametersStruct := strcase.ToCamel(cmdName) + "CommandParameters"
@@ -82,17 +161,158 @@ func (s *SqlCommandCodeGenerator) defineRunIntoGlazedMethod(f *jen.File, cmdName
 			jen.Id("gp").Qual(codegen.GlazedMiddlewaresPath, "Processor"),
 		).Error().
 		BlockFunc(func(g *jen.Group) {
-			for _, c := range s.renderQuery() {
+			for _, c := range s.renderQuery(cmd, jen.Return(jen.Err())) {
 				g.Add(c)
 			}
 			g.Err().Op("=").Qual(codegen.ClaySqlPath, "RunQueryIntoGlaze").Call(
-				jen.Id("ctx"), jen.Id("db"), jen.Id("renderedQuery"), jen.Index().Interface().Values(), jen.Id("gp"),
+				jen.Id("ctx"), jen.Id("db"), jen.Id("renderedQuery"), jen.Id("args"), jen.Id("gp"),
 			)
 			g.If(jen.Err().Op("!=").Nil()).Block(jen.Return(jen.Err()))
 			g.Return(jen.Nil())
 		})
 }
 
+// defineRunIntoGlazeProcessorMethod makes the command a glazed command, which connects to the database
+// configured by the sql-connection and dbt layers, and runs RunIntoGlazed with the parsed parameters.
+func (s *SqlCommandCodeGenerator) defineRunIntoGlazeProcessorMethod(f *jen.File, cmdName string) {
+	receiver := strcase.ToCamel(cmdName) + "Command"
+	parametersStruct := strcase.ToCamel(cmdName) + "CommandParameters"
+
+	f.Func().
+		Params(jen.Id("p").Op("*").Id(receiver)).Id("RunIntoGlazeProcessor").
+		Params(
+			jen.Id("ctx").Qual("context", "Context"),
+			jen.Id("parsedLayers").Op("*").Qual(GlazedLayersPath, "ParsedLayers"),
+			jen.Id("gp").Qual(codegen.GlazedMiddlewaresPath, "Processor"),
+		).Error().
+		Block(
+			jen.Id("params").Op(":=").Op("&").Id(parametersStruct).Values(),
+			jen.Err().Op(":=").Id("parsedLayers").Dot("InitializeStruct").Call(
+				jen.Qual(GlazedLayersPath, "DefaultSlug"), jen.Id("params"),
+			),
+			jen.If(jen.Err().Op("!=").Nil()).Block(jen.Return(jen.Err())),
+			jen.Line(),
+			jen.List(jen.Id("db"), jen.Err()).Op(":=").
+				Qual(SqletonConnectionPath, "OpenDatabaseFromDefaultSqlConnectionLayer").Call(jen.Id("parsedLayers")),
+			jen.If(jen.Err().Op("!=").Nil()).Block(jen.Return(jen.Err())),
+			jen.Defer().Func().Params().Block(
+				jen.Id("_").Op("=").Id("db").Dot("Close").Call(),
+			).Call(),
+			jen.Line(),
+			jen.Return(jen.Id("p").Dot("RunIntoGlazed").Call(jen.Id("ctx"), jen.Id("db"), jen.Id("params"), jen.Id("gp"))),
+		)
+}
+
+// columnType returns the Go type of a declared column, a pointer if the column is nullable.
+func columnType(c *cmds.ColumnDefinition) jen.Code {
+	var ret *jen.Statement
+	switch c.Type {
+	case "time.Time":
+		ret = jen.Qual("time", "Time")
+	case "[]byte":
+		// a nil slice already represents NULL
+		return jen.Index().Byte()
+	default:
+		ret = jen.Id(c.Type)
+	}
+	if c.Nullable {
+		return jen.Op("*").Add(ret)
+	}
+	return ret
+}
+
+func (s *SqlCommandCodeGenerator) defineRowStruct(f *jen.File, cmdName string, cmd *cmds.SqlCommand) {
+	structName := strcase.ToCamel(cmdName) + "Row"
+	f.Type().Id(structName).StructFunc(func(g *jen.Group) {
+		for _, c := range cmd.Columns {
+			g.Id(strcase.ToCamel(c.Name)).Add(columnType(c)).
+				Tag(map[string]string{"db": c.Name, "json": c.Name})
+		}
+	})
+}
+
+// defineQueryRowsMethod defines the QueryRows method, which returns the results as a slice of row structs.
+// Scanning fails if the query returns a column that is not declared.
+func (s *SqlCommandCodeGenerator) defineQueryRowsMethod(f *jen.File, cmdName string, cmd *cmds.SqlCommand) {
+	receiver := strcase.ToCamel(cmdName) + "Command"
+	parametersStruct := strcase.ToCamel(cmdName) + "CommandParameters"
+	rowStruct := strcase.ToCamel(cmdName) + "Row"
+
+	f.Func().
+		Params(jen.Id("p").Op("*").Id(receiver)).Id("QueryRows").
+		Params(
+			jen.Id("ctx").Qual("context", "Context"),
+			jen.Id("db").Op("*").Qual("github.com/jmoiron/sqlx", "DB"),
+			jen.Id("params").Op("*").Id(parametersStruct),
+		).Params(jen.Index().Id(rowStruct), jen.Error()).
+		BlockFunc(func(g *jen.Group) {
+			for _, c := range s.renderQuery(cmd, jen.Return(jen.Nil(), jen.Err())) {
+				g.Add(c)
+			}
+			g.Id("ret").Op(":=").Index().Id(rowStruct).Values()
+			g.Err().Op("=").Id("db").Dot("SelectContext").Call(
+				jen.Id("ctx"), jen.Op("&").Id("ret"), jen.Id("renderedQuery"), jen.Id("args").Op("..."),
+			)
+			g.If(jen.Err().Op("!=").Nil()).Block(jen.Return(jen.Nil(), jen.Err()))
+			g.Return(jen.Id("ret"), jen.Nil())
+		})
+}
+
+// parameterDefinitionToJen returns the call to parameters.NewParameterDefinition creating pd.
+func parameterDefinitionToJen(pd *parameters.ParameterDefinition) (jen.Code, error) {
+	args := []jen.Code{jen.Lit(pd.Name), jen.Lit(string(pd.Type))}
+	if pd.Help != "" {
+		args = append(args, jen.Qual(codegen.GlazedParametersPath, "WithHelp").Call(jen.Lit(pd.Help)))
+	}
+	if pd.ShortFlag != "" {
+		args = append(args, jen.Qual(codegen.GlazedParametersPath, "WithShortFlag").Call(jen.Lit(pd.ShortFlag)))
+	}
+	if pd.Default != nil {
+		default_, err := codegen.LiteralToJen(reflect.ValueOf(*pd.Default))
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not generate the default value of parameter %s", pd.Name)
+		}
+		args = append(args, jen.Qual(codegen.GlazedParametersPath, "WithDefault").Call(default_))
+	}
+	if len(pd.Choices) > 0 {
+		choices := make([]jen.Code, 0, len(pd.Choices))
+		for _, c := range pd.Choices {
+			choices = append(choices, jen.Lit(c))
+		}
+		args = append(args, jen.Qual(codegen.GlazedParametersPath, "WithChoices").Call(choices...))
+	}
+	if pd.Required {
+		args = append(args, jen.Qual(codegen.GlazedParametersPath, "WithRequired").Call(jen.True()))
+	}
+
+	return jen.Qual(codegen.GlazedParametersPath, "NewParameterDefinition").Call(args...), nil
+}
+
+func parameterDefinitionsToJen(pds *parameters.ParameterDefinitions) ([]jen.Code, error) {
+	ret := []jen.Code{}
+	err := pds.ForEachE(func(pd *parameters.ParameterDefinition) error {
+		c, err := parameterDefinitionToJen(pd)
+		if err != nil {
+			return err
+		}
+		ret = append(ret, jen.Line().Add(c))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(ret) > 0 {
+		ret = append(ret, jen.Line())
+	}
+	return ret, nil
+}
+
+// defineLayer defines a variable holding the layer returned by constructor, returning on error.
+func defineLayer(g *jen.Group, name string, path string, constructor string) {
+	g.List(jen.Id(name), jen.Err()).Op(":=").Qual(path, constructor).Call()
+	g.If(jen.Err().Op("!=").Nil()).Block(jen.Return(jen.Nil(), jen.Err()))
+}
+
 func (s *SqlCommandCodeGenerator) defineNewFunction(f *jen.File, cmdName string, cmd *cmds.SqlCommand) error {
 	funcName := "New" + strcase.ToCamel(cmdName) + "Command"
 	commandStruct := strcase.ToCamel(cmdName) + "Command"
@@ -100,50 +320,62 @@ func (s *SqlCommandCodeGenerator) defineNewFunction(f *jen.File, cmdName string,
 
 	description := cmd.Description()
 
-	var err_ error
+	flagDefs, err := parameterDefinitionsToJen(commandFlags(cmd))
+	if err != nil {
+		return errors.Wrapf(err, "command %s", description.Name)
+	}
+	argDefs, err := parameterDefinitionsToJen(cmd.GetDefaultArguments())
+	if err != nil {
+		return errors.Wrapf(err, "command %s", description.Name)
+	}
+
+	descriptionOptions := []jen.Code{
+		jen.Lit(description.Name),
+		jen.Line().Qual(codegen.GlazedCommandsPath, "WithShort").Call(jen.Lit(description.Short)),
+		jen.Line().Qual(codegen.GlazedCommandsPath, "WithLong").Call(jen.Lit(description.Long)),
+		jen.Line().Qual(codegen.GlazedCommandsPath, "WithFlags").Call(jen.Id("flagDefs").Op("...")),
+		jen.Line().Qual(codegen.GlazedCommandsPath, "WithArguments").Call(jen.Id("argDefs").Op("...")),
+	}
+	if len(description.Parents) > 0 {
+		parents := make([]jen.Code, 0, len(description.Parents))
+		for _, p := range description.Parents {
+			parents = append(parents, jen.Lit(p))
+		}
+		descriptionOptions = append(descriptionOptions,
+			jen.Line().Qual(codegen.GlazedCommandsPath, "WithParents").Call(parents...))
+	}
+	// the layers needed to run the command as a cobra command, see RunIntoGlazeProcessor
+	descriptionOptions = append(descriptionOptions,
+		jen.Line().Qual(codegen.GlazedCommandsPath, "WithLayersList").Call(
+			jen.Id("sqlConnectionParameterLayer"), jen.Id("dbtParameterLayer"), jen.Id("glazedParameterLayer"),
+		),
+		jen.Line(),
+	)
+
 	f.Func().Id(funcName).Params().
 		Params(jen.Op("*").Id(commandStruct), jen.Error()).
-		Block(
-			// TODO(manuel, 2023-12-07) Can be refactored since this is duplicated in geppetto/codegen.go
-			jen.Var().Id("flagDefs").Op("=").
+		BlockFunc(func(g *jen.Group) {
+			g.Var().Id("flagDefs").Op("=").
 				Index().Op("*").
 				Qual(codegen.GlazedParametersPath, "ParameterDefinition").
-				ValuesFunc(func(g *jen.Group) {
-					err_ = cmd.GetDefaultFlags().ForEachE(func(flag *parameters.ParameterDefinition) error {
-						dict, err := codegen.ParameterDefinitionToDict(flag)
-						if err != nil {
-							return err
-						}
-						g.Values(dict)
-						return nil
-					})
-				}),
-			jen.Line(),
-			jen.Var().Id("argDefs").Op("=").
+				Values(flagDefs...)
+			g.Line()
+			g.Var().Id("argDefs").Op("=").
 				Index().Op("*").
 				Qual(codegen.GlazedParametersPath, "ParameterDefinition").
-				ValuesFunc(func(g *jen.Group) {
-					err_ = cmd.GetDefaultArguments().ForEachE(func(arg *parameters.ParameterDefinition) error {
-						dict, err := codegen.ParameterDefinitionToDict(arg)
-						if err != nil {
-							return err
-						}
-						g.Values(dict)
-						return nil
-					})
-				}),
-			jen.Line(),
-			jen.Id("cmdDescription").Op(":=").Qual(codegen.GlazedCommandsPath, "NewCommandDescription").
-				Call(
-					jen.Lit(description.Name),
-					jen.Line().Qual(codegen.GlazedCommandsPath, "WithShort").Call(jen.Lit(description.Short)),
-					jen.Line().Qual(codegen.GlazedCommandsPath, "WithLong").Call(jen.Lit(description.Long)),
-					jen.Line().Qual(codegen.GlazedCommandsPath, "WithFlags").Call(jen.Id("flagDefs").Op("...")),
-					jen.Line().Qual(codegen.GlazedCommandsPath, "WithArguments").Call(jen.Id("argDefs").Op("...")),
-				),
-			jen.Line(),
+				Values(argDefs...)
+			g.Line()
+
+			defineLayer(g, "sqlConnectionParameterLayer", codegen.ClaySqlPath, "NewSqlConnectionParameterLayer")
+			defineLayer(g, "dbtParameterLayer", codegen.ClaySqlPath, "NewDbtParameterLayer")
+			defineLayer(g, "glazedParameterLayer", GlazedSettingsPath, "NewGlazedParameterLayers")
+			g.Line()
+
+			g.Id("cmdDescription").Op(":=").Qual(codegen.GlazedCommandsPath, "NewCommandDescription").
+				Call(descriptionOptions...)
+			g.Line()
 
-			jen.Return(jen.Op("&").Id(commandStruct).Values(jen.Dict{
+			g.Return(jen.Op("&").Id(commandStruct).Values(jen.Dict{
 				jen.Id("CommandDescription"): jen.Id("cmdDescription"),
 				jen.Id("Query"):              jen.Id(queryConstName),
 				jen.Id("SubQueries"): jen.Map(jen.String()).String().Values(jen.DictFunc(func(d jen.Dict) {
@@ -154,24 +386,40 @@ func (s *SqlCommandCodeGenerator) defineNewFunction(f *jen.File, cmdName string,
 						}
 					}
 				})),
-			}), jen.Nil()),
-		)
+			}), jen.Nil())
+		})
 
-	return err_
+	return nil
 }
 
 func (s *SqlCommandCodeGenerator) GenerateCommandCode(cmd *cmds.SqlCommand) (*jen.File, error) {
+	err := checkConvertible(cmd)
+	if err != nil {
+		return nil, err
+	}
+
 	f := jen.NewFile(s.PackageName)
-	cmdName := strcase.ToLowerCamel(cmd.Name)
+	cmdName := commandName(cmd)
 
 	// Define constants, struct, and methods using helper functions.
 	s.defineConstants(f, cmdName, cmd)
 	s.defineStruct(f, cmdName)
 	f.Line()
-	s.defineParametersStruct(f, cmdName, cmd.Description())
-	s.defineRunIntoGlazedMethod(f, cmdName)
+	s.defineParametersStruct(f, cmdName, cmd)
+	s.defineRunIntoGlazedMethod(f, cmdName, cmd)
 	f.Line()
-	err := s.defineNewFunction(f, cmdName, cmd)
+	s.defineRunIntoGlazeProcessorMethod(f, cmdName)
+	f.Line()
+	if len(cmd.Columns) > 0 {
+		s.defineRowStruct(f, cmdName, cmd)
+		s.defineQueryRowsMethod(f, cmdName, cmd)
+		f.Line()
+	}
+	if s.HTTP {
+		s.defineHandlerMethod(f, cmdName, cmd)
+		f.Line()
+	}
+	err = s.defineNewFunction(f, cmdName, cmd)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,143 @@
+package codegen
+
+import (
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	sqleton_cmds "github.com/go-go-golems/sqleton/pkg/cmds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"testing/fstest"
+)
+
+func TestGenerateRowStruct(t *testing.T) {
+	cmd, err := sqleton_cmds.NewSqlCommand(
+		cmds.NewCommandDescription("ls-posts",
+			cmds.WithFlags(parameters.NewParameterDefinition("post-type", parameters.ParameterTypeString)),
+		),
+		sqleton_cmds.WithQuery("SELECT id, title FROM posts WHERE type = {{ index . \"post-type\" | sqlString }}"),
+		sqleton_cmds.WithColumns([]*sqleton_cmds.ColumnDefinition{
+			{Name: "id", Type: "int64"},
+			{Name: "title", Type: "string", Nullable: true},
+			{Name: "created_at", Type: "time.Time"},
+		}),
+	)
+	require.NoError(t, err)
+
+	s := &SqlCommandCodeGenerator{PackageName: "queries"}
+	f, err := s.GenerateCommandCode(cmd)
+	require.NoError(t, err)
+	code := f.GoString()
+
+	assert.Contains(t, code, "type LsPostsRow struct {\n"+
+		"\tId        int64     `db:\"id\" json:\"id\"`\n"+
+		"\tTitle     *string   `db:\"title\" json:\"title\"`\n"+
+		"\tCreatedAt time.Time `db:\"created_at\" json:\"created_at\"`\n"+
+		"}")
+	assert.Contains(t, code, "func (p *LsPostsCommand) QueryRows(ctx context.Context, db *sqlx.DB, "+
+		"params *LsPostsCommandParameters) ([]LsPostsRow, error) {")
+	assert.Contains(t, code, `ps := map[string]interface{}{"post-type": params.PostType}`)
+	assert.Contains(t, code, "PostType string `glazed.parameter:\"post-type\"`")
+	assert.Contains(t, code, "err = db.SelectContext(ctx, &ret, renderedQuery, args...)")
+	// sqlBind is available in commands which are not parameterized
+	assert.Contains(t, code, "renderedQuery, args, err := cmds1.RenderQueryWithArgs(ctx, db, p.Query, p.SubQueries, ps, false)")
+}
+
+func TestGenerateWithoutColumns(t *testing.T) {
+	cmd, err := sqleton_cmds.NewSqlCommand(
+		cmds.NewCommandDescription("ls-posts"),
+		sqleton_cmds.WithQuery("SELECT * FROM posts"),
+	)
+	require.NoError(t, err)
+
+	s := &SqlCommandCodeGenerator{PackageName: "queries"}
+	f, err := s.GenerateCommandCode(cmd)
+	require.NoError(t, err)
+	code := f.GoString()
+	assert.Contains(t, code, "func (p *LsPostsCommand) RunIntoGlazed(")
+	assert.NotContains(t, code, "LsPostsRow")
+}
+
+func TestGenerateParameterDefinitions(t *testing.T) {
+	cmd, err := sqleton_cmds.NewSqlCommand(
+		cmds.NewCommandDescription("ls",
+			cmds.WithFlags(
+				parameters.NewParameterDefinition("limit", parameters.ParameterTypeInteger,
+					parameters.WithHelp("Maximum number of posts"), parameters.WithDefault(10)),
+				parameters.NewParameterDefinition("order", parameters.ParameterTypeChoice,
+					parameters.WithChoices("id", "title"), parameters.WithRequired(true)),
+			),
+			cmds.WithParents("posts"),
+		),
+		sqleton_cmds.WithQuery("SELECT * FROM posts"),
+	)
+	require.NoError(t, err)
+
+	s := &SqlCommandCodeGenerator{PackageName: "queries"}
+	f, err := s.GenerateCommandCode(cmd)
+	require.NoError(t, err)
+	code := f.GoString()
+
+	// the parents are part of the generated names
+	assert.Contains(t, code, "func NewPostsLsCommand() (*PostsLsCommand, error) {")
+	assert.Contains(t, code, `parameters.NewParameterDefinition("limit", "int", `+
+		`parameters.WithHelp("Maximum number of posts"), parameters.WithDefault(10)),`)
+	assert.Contains(t, code, `parameters.NewParameterDefinition("order", "choice", `+
+		`parameters.WithChoices("id", "title"), parameters.WithRequired(true)),`)
+	assert.Contains(t, code, `cmds.WithParents("posts"),`)
+	assert.Contains(t, code, "func (p *PostsLsCommand) RunIntoGlazeProcessor(ctx context.Context, "+
+		"parsedLayers *layers.ParsedLayers, gp middlewares.Processor) error {")
+}
+
+func TestGenerateUsedLayers(t *testing.T) {
+	f := fstest.MapFS{
+		"_layers/rng.yaml": &fstest.MapFile{Data: []byte(`
+slug: rng
+name: Range
+flags:
+  - name: top
+    type: int
+    default: 10
+`)},
+		"ls.yaml": &fstest.MapFile{Data: []byte(`
+name: ls
+short: List
+use-layers: [rng]
+query: SELECT {{ .top }}
+`)},
+		"paginated.yaml": &fstest.MapFile{Data: []byte(`
+name: paginated
+short: List
+pagination:
+  keys: [id]
+query: SELECT id FROM posts
+`)},
+	}
+	loader := &sqleton_cmds.SqlCommandLoader{}
+	commands, err := loader.LoadCommands(f, "ls.yaml", nil, nil)
+	require.NoError(t, err)
+	cmd := commands[0].(*sqleton_cmds.SqlCommand)
+
+	s := &SqlCommandCodeGenerator{PackageName: "queries"}
+	file, err := s.GenerateCommandCode(cmd)
+	require.NoError(t, err)
+	code := file.GoString()
+
+	// the flags of the layer become flags of the generated command
+	assert.Contains(t, code, "Top int `glazed.parameter:\"top\"`")
+	assert.Contains(t, code, `ps := map[string]interface{}{"top": params.Top}`)
+	assert.Contains(t, code, `parameters.NewParameterDefinition("top", "int", parameters.WithDefault(10)),`)
+
+	b, err := s.GenerateTypeScriptClient([]*sqleton_cmds.SqlCommand{cmd})
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "  top?: number;")
+
+	b, err = s.GenerateOpenAPIDocument([]*sqleton_cmds.SqlCommand{cmd})
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"name": "top"`)
+
+	commands, err = loader.LoadCommands(f, "paginated.yaml", nil, nil)
+	require.NoError(t, err)
+	_, err = s.GenerateCommandCode(commands[0].(*sqleton_cmds.SqlCommand))
+	assert.EqualError(t, err, "command paginated: paginated commands can't be converted")
+}
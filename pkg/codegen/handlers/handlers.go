@@ -0,0 +1,189 @@
+// Package handlers contains the helpers used by the net/http handlers generated by `sqleton codegen --http`.
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/middlewares"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ParseParameters parses the flags and arguments of the command from the request into dst,
+// the parameters struct of a generated command.
+//
+// GET requests pass the parameters in the query string, list values being either repeated or comma-separated.
+// Other requests pass them as a JSON object in their body.
+// Missing parameters get their default value, and missing required parameters are an error.
+//
+// Parameters reading files (stringFromFile, keyValue starting with @, ...) can't be passed in requests,
+// since they would be read from the disk of the server.
+func ParseParameters(r *http.Request, description *cmds.CommandDescription, dst interface{}) error {
+	defaultLayer, ok := description.GetDefaultLayer()
+	if !ok {
+		return errors.Errorf("command %s has no default layer", description.Name)
+	}
+	pds := defaultLayer.GetParameterDefinitions()
+
+	// the values of the parameters, as strings to be parsed by glazed
+	raw := map[string][]string{}
+	if r.Method == http.MethodGet {
+		query := r.URL.Query()
+		for name, vs := range query {
+			raw[name] = vs
+		}
+	} else if r.Body != nil && r.Body != http.NoBody {
+		body := map[string]interface{}{}
+		decoder := json.NewDecoder(r.Body)
+		decoder.UseNumber()
+		err := decoder.Decode(&body)
+		if err != nil {
+			return errors.Wrap(err, "could not decode the parameters of the request")
+		}
+		for name, v := range body {
+			vs, err := jsonValueToStrings(v)
+			if err != nil {
+				return errors.Wrapf(err, "invalid value for parameter %s", name)
+			}
+			if vs != nil {
+				raw[name] = vs
+			}
+		}
+	}
+
+	values := map[string]interface{}{}
+	err := pds.ForEachE(func(pd *parameters.ParameterDefinition) error {
+		vs, ok := raw[pd.Name]
+		if !ok {
+			return nil
+		}
+		if pd.Type.IsList() && len(vs) == 1 {
+			vs = strings.Split(vs[0], ",")
+		}
+		for _, v := range vs {
+			if readsFiles(pd, v) {
+				return errors.Errorf("parameter %s can't be passed in a request", pd.Name)
+			}
+		}
+
+		parsed, err := pd.ParseParameter(vs)
+		if err != nil {
+			return errors.Wrapf(err, "invalid value for parameter %s", pd.Name)
+		}
+		values[pd.Name] = parsed.Value
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	layers_ := layers.NewParameterLayers(layers.WithLayers(defaultLayer))
+	parsedLayers := layers.NewParsedLayers()
+	err = middlewares.ExecuteMiddlewares(layers_, parsedLayers,
+		middlewares.UpdateFromMap(map[string]map[string]interface{}{layers.DefaultSlug: values},
+			parameters.WithParseStepSource("request"),
+		),
+		middlewares.SetFromDefaults(parameters.WithParseStepSource(parameters.SourceDefaults)),
+	)
+	if err != nil {
+		return err
+	}
+
+	err = pds.ForEachE(func(pd *parameters.ParameterDefinition) error {
+		if _, ok := parsedLayers.GetParameter(layers.DefaultSlug, pd.Name); pd.Required && !ok {
+			return errors.Errorf("missing required parameter %s", pd.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return parsedLayers.InitializeStruct(layers.DefaultSlug, dst)
+}
+
+// jsonValueToStrings converts a value of the JSON body of a request to the strings it would be passed as
+// in a query string. It returns nil for null values.
+func jsonValueToStrings(v interface{}) ([]string, error) {
+	switch v_ := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v_}, nil
+	case json.Number:
+		return []string{v_.String()}, nil
+	case bool:
+		return []string{strconv.FormatBool(v_)}, nil
+	case []interface{}:
+		ret := []string{}
+		for _, e := range v_ {
+			s, err := jsonValueToStrings(e)
+			if err != nil {
+				return nil, err
+			}
+			if len(s) != 1 {
+				return nil, errors.New("lists can only contain strings, numbers and booleans")
+			}
+			ret = append(ret, s[0])
+		}
+		return ret, nil
+	default:
+		return nil, errors.Errorf("unsupported value %v", v)
+	}
+}
+
+func readsFiles(pd *parameters.ParameterDefinition, value string) bool {
+	return pd.Type.IsFile() || pd.Type.NeedsFileContent(value)
+}
+
+// QueryRows runs the query and returns the rows as maps, for the commands that don't declare their columns.
+func QueryRows(ctx context.Context, db *sqlx.DB, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	rows, err := db.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sqlx.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	ret := []map[string]interface{}{}
+	for rows.Next() {
+		row := map[string]interface{}{}
+		err = rows.MapScan(row)
+		if err != nil {
+			return nil, err
+		}
+		// some drivers return text columns as bytes, which would be encoded as base64
+		for k, v := range row {
+			if b, ok := v.([]byte); ok {
+				row[k] = string(b)
+			}
+		}
+		ret = append(ret, row)
+	}
+
+	return ret, rows.Err()
+}
+
+// WriteJSON writes v as the JSON response of the request.
+func WriteJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// Error is the JSON response of failed requests.
+type Error struct {
+	Error string `json:"error"`
+}
+
+// WriteError writes err as the JSON response of the request.
+func WriteError(w http.ResponseWriter, status int, err error) {
+	WriteJSON(w, status, Error{Error: err.Error()})
+}
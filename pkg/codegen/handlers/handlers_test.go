@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type lsParameters struct {
+	Limit int      `glazed.parameter:"limit"`
+	Ids   []int    `glazed.parameter:"ids"`
+	Query string   `glazed.parameter:"query"`
+	Tags  []string `glazed.parameter:"tags"`
+	Name  string   `glazed.parameter:"name"`
+}
+
+func newLsDescription() *cmds.CommandDescription {
+	return cmds.NewCommandDescription("ls",
+		cmds.WithFlags(
+			parameters.NewParameterDefinition("limit", parameters.ParameterTypeInteger, parameters.WithDefault(10)),
+			parameters.NewParameterDefinition("ids", parameters.ParameterTypeIntegerList),
+			parameters.NewParameterDefinition("query", parameters.ParameterTypeStringFromFile),
+			parameters.NewParameterDefinition("tags", parameters.ParameterTypeStringList),
+		),
+		cmds.WithArguments(
+			parameters.NewParameterDefinition("name", parameters.ParameterTypeString, parameters.WithRequired(true)),
+		),
+	)
+}
+
+func TestParseParametersFromQueryString(t *testing.T) {
+	description := newLsDescription()
+
+	params := &lsParameters{}
+	r := httptest.NewRequest("GET", "/ls?name=foo&ids=1,2&tags=a&tags=b", nil)
+	require.NoError(t, ParseParameters(r, description, params))
+	assert.Equal(t, &lsParameters{Limit: 10, Ids: []int{1, 2}, Tags: []string{"a", "b"}, Name: "foo"}, params)
+
+	r = httptest.NewRequest("GET", "/ls?name=foo&limit=abc", nil)
+	assert.Error(t, ParseParameters(r, description, &lsParameters{}))
+
+	r = httptest.NewRequest("GET", "/ls?limit=2", nil)
+	assert.EqualError(t, ParseParameters(r, description, &lsParameters{}), "missing required parameter name")
+
+	r = httptest.NewRequest("GET", "/ls?name=foo&query=/etc/passwd", nil)
+	assert.EqualError(t, ParseParameters(r, description, &lsParameters{}), "parameter query can't be passed in a request")
+}
+
+func TestParseParametersFromJSON(t *testing.T) {
+	description := newLsDescription()
+
+	params := &lsParameters{}
+	r := httptest.NewRequest("POST", "/ls", strings.NewReader(`{"name": "foo", "limit": 3, "ids": [4, 5]}`))
+	require.NoError(t, ParseParameters(r, description, params))
+	assert.Equal(t, &lsParameters{Limit: 3, Ids: []int{4, 5}, Name: "foo"}, params)
+
+	r = httptest.NewRequest("POST", "/ls", strings.NewReader(`{"name": "foo", "query": "/etc/passwd"}`))
+	assert.EqualError(t, ParseParameters(r, description, &lsParameters{}), "parameter query can't be passed in a request")
+}
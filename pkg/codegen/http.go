@@ -0,0 +1,118 @@
+package codegen
+
+import (
+	"github.com/dave/jennifer/jen"
+	"github.com/go-go-golems/sqleton/pkg/cmds"
+	"github.com/iancoleman/strcase"
+	"strings"
+)
+
+const SqletonHandlersPath = "github.com/go-go-golems/sqleton/pkg/codegen/handlers"
+
+// OpenAPIFileName is the name of the OpenAPI document generated along with the handlers,
+// which the generated code embeds.
+const OpenAPIFileName = "openapi.json"
+
+// handlerPath returns the path the handler of the command is registered at, /parents/name.
+func handlerPath(cmd *cmds.SqlCommand) string {
+	return "/" + strings.Join(commandPath(cmd), "/")
+}
+
+// defineHandlerMethod defines the Handler method, returning a net/http handler which parses the parameters
+// from the request and returns the rows as JSON.
+func (s *SqlCommandCodeGenerator) defineHandlerMethod(f *jen.File, cmdName string, cmd *cmds.SqlCommand) {
+	receiver := strcase.ToCamel(cmdName) + "Command"
+	parametersStruct := strcase.ToCamel(cmdName) + "CommandParameters"
+
+	writeError := func(status string) []jen.Code {
+		return []jen.Code{
+			jen.Qual(SqletonHandlersPath, "WriteError").Call(jen.Id("w"), jen.Qual("net/http", status), jen.Err()),
+			jen.Return(),
+		}
+	}
+
+	f.Comment("Handler returns a net/http handler running the command, which returns the rows as JSON.")
+	f.Comment("The parameters are passed in the query string of GET requests, or as a JSON object in the body of POST requests.")
+	f.Func().
+		Params(jen.Id("p").Op("*").Id(receiver)).Id("Handler").
+		Params(jen.Id("db").Op("*").Qual("github.com/jmoiron/sqlx", "DB")).
+		Qual("net/http", "Handler").
+		Block(
+			jen.Return(jen.Qual("net/http", "HandlerFunc").Call(
+				jen.Func().Params(
+					jen.Id("w").Qual("net/http", "ResponseWriter"),
+					jen.Id("r").Op("*").Qual("net/http", "Request"),
+				).BlockFunc(func(g *jen.Group) {
+					g.Id("ctx").Op(":=").Id("r").Dot("Context").Call()
+					g.Id("params").Op(":=").Op("&").Id(parametersStruct).Values()
+					g.Err().Op(":=").Qual(SqletonHandlersPath, "ParseParameters").Call(
+						jen.Id("r"), jen.Id("p").Dot("CommandDescription"), jen.Id("params"),
+					)
+					g.If(jen.Err().Op("!=").Nil()).Block(writeError("StatusBadRequest")...)
+					g.Line()
+
+					if len(cmd.Columns) > 0 {
+						g.List(jen.Id("rows"), jen.Err()).Op(":=").Id("p").Dot("QueryRows").Call(
+							jen.Id("ctx"), jen.Id("db"), jen.Id("params"),
+						)
+					} else {
+						for _, c := range s.renderQuery(cmd, writeError("StatusInternalServerError")...) {
+							g.Add(c)
+						}
+						g.List(jen.Id("rows"), jen.Err()).Op(":=").Qual(SqletonHandlersPath, "QueryRows").Call(
+							jen.Id("ctx"), jen.Id("db"), jen.Id("renderedQuery"), jen.Id("args").Op("..."),
+						)
+					}
+					g.If(jen.Err().Op("!=").Nil()).Block(writeError("StatusInternalServerError")...)
+					g.Qual(SqletonHandlersPath, "WriteJSON").Call(jen.Id("w"), jen.Qual("net/http", "StatusOK"), jen.Id("rows"))
+				}),
+			)),
+		)
+}
+
+// GenerateHandlersCode generates the RegisterHandlers function, which adds the handlers of the generated commands
+// to a http.ServeMux at /parents/name, along with the OpenAPI document describing them at /openapi.json.
+// The document is embedded from OpenAPIFileName, see GenerateOpenAPIDocument.
+func (s *SqlCommandCodeGenerator) GenerateHandlersCode(commands []*cmds.SqlCommand) (*jen.File, error) {
+	err := checkCommands(commands)
+	if err != nil {
+		return nil, err
+	}
+
+	f := jen.NewFile(s.PackageName)
+	f.Anon("embed")
+
+	f.Comment("//go:embed " + OpenAPIFileName)
+	f.Var().Id("openAPIDocument").Index().Byte()
+	f.Line()
+
+	f.Comment("RegisterHandlers adds the handlers of the generated commands to mux, along with their OpenAPI document at /openapi.json.")
+	f.Func().Id("RegisterHandlers").
+		Params(
+			jen.Id("mux").Op("*").Qual("net/http", "ServeMux"),
+			jen.Id("db").Op("*").Qual("github.com/jmoiron/sqlx", "DB"),
+		).Error().
+		BlockFunc(func(g *jen.Group) {
+			for _, cmd := range commands {
+				cmdName := commandName(cmd)
+				v := strcase.ToLowerCamel(cmdName) + "Command"
+				g.List(jen.Id(v), jen.Err()).Op(":=").Id("New" + strcase.ToCamel(cmdName) + "Command").Call()
+				g.If(jen.Err().Op("!=").Nil()).Block(jen.Return(jen.Err()))
+				g.Id("mux").Dot("Handle").Call(jen.Lit(handlerPath(cmd)), jen.Id(v).Dot("Handler").Call(jen.Id("db")))
+				g.Line()
+			}
+			g.Id("mux").Dot("HandleFunc").Call(
+				jen.Lit("/"+OpenAPIFileName),
+				jen.Func().Params(
+					jen.Id("w").Qual("net/http", "ResponseWriter"),
+					jen.Id("r").Op("*").Qual("net/http", "Request"),
+				).Block(
+					jen.Id("w").Dot("Header").Call().Dot("Set").Call(jen.Lit("Content-Type"), jen.Lit("application/json")),
+					jen.List(jen.Id("_"), jen.Id("_")).Op("=").Id("w").Dot("Write").Call(jen.Id("openAPIDocument")),
+				),
+			)
+			g.Return(jen.Nil())
+		})
+
+	return f, nil
+}
@@ -0,0 +1,104 @@
+//go:debug httpmuxgo121=0
+
+package codegen
+
+import (
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	sqleton_cmds "github.com/go-go-golems/sqleton/pkg/cmds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func newHTTPTestCommand(t *testing.T, columns []*sqleton_cmds.ColumnDefinition) *sqleton_cmds.SqlCommand {
+	cmd, err := sqleton_cmds.NewSqlCommand(
+		cmds.NewCommandDescription("ls",
+			cmds.WithShort("List posts"),
+			cmds.WithParents("posts"),
+			cmds.WithFlags(
+				parameters.NewParameterDefinition("limit", parameters.ParameterTypeInteger,
+					parameters.WithHelp("Maximum number of posts"), parameters.WithDefault(10)),
+				parameters.NewParameterDefinition("order", parameters.ParameterTypeChoice,
+					parameters.WithChoices("id", "title")),
+				parameters.NewParameterDefinition("query", parameters.ParameterTypeStringFromFile),
+			),
+			cmds.WithArguments(
+				parameters.NewParameterDefinition("type", parameters.ParameterTypeString, parameters.WithRequired(true)),
+			),
+		),
+		sqleton_cmds.WithQuery("SELECT id, title FROM posts LIMIT {{ .limit }}"),
+		sqleton_cmds.WithColumns(columns),
+	)
+	require.NoError(t, err)
+	return cmd
+}
+
+func TestGenerateHandler(t *testing.T) {
+	s := &SqlCommandCodeGenerator{PackageName: "queries", HTTP: true}
+
+	f, err := s.GenerateCommandCode(newHTTPTestCommand(t, nil))
+	require.NoError(t, err)
+	code := f.GoString()
+	assert.Contains(t, code, "func (p *PostsLsCommand) Handler(db *sqlx.DB) http.Handler {")
+	assert.Contains(t, code, "err := handlers.ParseParameters(r, p.CommandDescription, params)")
+	assert.Contains(t, code, "rows, err := handlers.QueryRows(ctx, db, renderedQuery, args...)")
+
+	// commands declaring their columns return typed rows
+	f, err = s.GenerateCommandCode(newHTTPTestCommand(t, []*sqleton_cmds.ColumnDefinition{
+		{Name: "id", Type: "int64"},
+	}))
+	require.NoError(t, err)
+	assert.Contains(t, f.GoString(), "rows, err := p.QueryRows(ctx, db, params)")
+
+	s.HTTP = false
+	f, err = s.GenerateCommandCode(newHTTPTestCommand(t, nil))
+	require.NoError(t, err)
+	assert.NotContains(t, f.GoString(), "Handler(")
+}
+
+func TestGenerateHandlersCode(t *testing.T) {
+	s := &SqlCommandCodeGenerator{PackageName: "queries", HTTP: true}
+	f, err := s.GenerateHandlersCode([]*sqleton_cmds.SqlCommand{newHTTPTestCommand(t, nil)})
+	require.NoError(t, err)
+	code := f.GoString()
+
+	assert.Contains(t, code, "//go:embed openapi.json\nvar openAPIDocument []byte")
+	assert.Contains(t, code, "func RegisterHandlers(mux *http.ServeMux, db *sqlx.DB) error {")
+	assert.Contains(t, code, `mux.Handle("/posts/ls", postsLsCommand.Handler(db))`)
+	assert.Contains(t, code, `mux.HandleFunc("/openapi.json", `)
+}
+
+func TestGenerateHandlersCodeRegistersOnServeMux(t *testing.T) {
+	// the name of examples/03-get-posts-by-type.yaml, whose usage isn't part of the path
+	cmd, err := sqleton_cmds.NewSqlCommand(
+		cmds.NewCommandDescription("ls-posts-type [types...]", cmds.WithParents("examples")),
+		sqleton_cmds.WithQuery("SELECT id FROM posts"),
+	)
+	require.NoError(t, err)
+
+	s := &SqlCommandCodeGenerator{PackageName: "queries", HTTP: true}
+	f, err := s.GenerateHandlersCode([]*sqleton_cmds.SqlCommand{cmd})
+	require.NoError(t, err)
+
+	matches := regexp.MustCompile(`mux\.Handle(?:Func)?\("([^"]*)"`).FindAllStringSubmatch(f.GoString(), -1)
+	require.Len(t, matches, 2)
+	assert.Equal(t, "/examples/ls-posts-type", matches[0][1])
+
+	// registering the generated paths panics if they aren't valid patterns
+	mux := http.NewServeMux()
+	for _, m := range matches {
+		path := m[1]
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(path))
+		})
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/examples/ls-posts-type?types=post", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "/examples/ls-posts-type", w.Body.String())
+}
@@ -0,0 +1,196 @@
+package codegen
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/middlewares"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/sqleton/pkg/cmds"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// InferColumns renders the query of the command with the default values of its parameters,
+// runs it with LIMIT 0 and derives the columns of its results from the column metadata of the driver.
+//
+// The columns declared by the command take precedence over the inferred ones with the same name,
+// which allows declaring the columns whose type can't be inferred (for example expressions with sqlite).
+func InferColumns(ctx context.Context, db *sqlx.DB, cmd *cmds.SqlCommand) ([]*cmds.ColumnDefinition, error) {
+	parsedLayers := layers.NewParsedLayers()
+	err := middlewares.ExecuteMiddlewares(cmd.Description().Layers, parsedLayers,
+		middlewares.SetFromDefaults(parameters.WithParseStepSource(parameters.SourceDefaults)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := cmd.RenderQueryWithArgs(ctx, db, parsedLayers.GetDataMap())
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not render %s with its default parameters", cmd.Name)
+	}
+	query = strings.TrimSuffix(strings.TrimSpace(query), ";")
+
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT * FROM (\n%s\n) sqleton_columns LIMIT 0", query), args...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not run %s with its default parameters", cmd.Name)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	declared := map[string]*cmds.ColumnDefinition{}
+	for _, c := range cmd.Columns {
+		declared[c.Name] = c
+	}
+
+	ret := make([]*cmds.ColumnDefinition, 0, len(columnTypes))
+	for _, ct := range columnTypes {
+		if c, ok := declared[ct.Name()]; ok {
+			ret = append(ret, c)
+			continue
+		}
+
+		c, err := inferColumn(ct)
+		if err != nil {
+			return nil, errors.Wrapf(err, "command %s", cmd.Name)
+		}
+		ret = append(ret, c)
+	}
+
+	return ret, nil
+}
+
+// databaseTypes maps the database type names reported by the drivers to column types,
+// for the columns whose scan type doesn't tell.
+var databaseTypes = map[string]string{
+	"INTEGER":     "int64",
+	"INT":         "int64",
+	"INT2":        "int64",
+	"INT4":        "int64",
+	"INT8":        "int64",
+	"TINYINT":     "int64",
+	"SMALLINT":    "int64",
+	"MEDIUMINT":   "int64",
+	"BIGINT":      "int64",
+	"REAL":        "float64",
+	"FLOAT":       "float64",
+	"FLOAT4":      "float64",
+	"FLOAT8":      "float64",
+	"DOUBLE":      "float64",
+	"NUMERIC":     "string",
+	"DECIMAL":     "string",
+	"CHAR":        "string",
+	"BPCHAR":      "string",
+	"VARCHAR":     "string",
+	"TEXT":        "string",
+	"TINYTEXT":    "string",
+	"MEDIUMTEXT":  "string",
+	"LONGTEXT":    "string",
+	"ENUM":        "string",
+	"SET":         "string",
+	"JSON":        "string",
+	"UUID":        "string",
+	"BOOL":        "bool",
+	"BOOLEAN":     "bool",
+	"DATE":        "time.Time",
+	"DATETIME":    "time.Time",
+	"TIMESTAMP":   "time.Time",
+	"TIMESTAMPTZ": "time.Time",
+	"BLOB":        "[]byte",
+	"TINYBLOB":    "[]byte",
+	"MEDIUMBLOB":  "[]byte",
+	"LONGBLOB":    "[]byte",
+	"BINARY":      "[]byte",
+	"VARBINARY":   "[]byte",
+	"BYTEA":       "[]byte",
+}
+
+func inferColumn(ct *sql.ColumnType) (*cmds.ColumnDefinition, error) {
+	type_, nullable := scanTypeToColumnType(ct.ScanType())
+
+	// text columns are often scanned as bytes (sql.RawBytes), the database type tells them apart from binary ones
+	if type_ == "" || type_ == "[]byte" {
+		name := strings.ToUpper(ct.DatabaseTypeName())
+		name, _, _ = strings.Cut(name, "(")
+		name = strings.TrimPrefix(strings.TrimSpace(name), "UNSIGNED ")
+		if t, ok := databaseTypes[name]; ok {
+			type_ = t
+		}
+	}
+	if type_ == "" {
+		return nil, errors.Errorf("could not infer the type of column %s (database type %q), declare it in columns:",
+			ct.Name(), ct.DatabaseTypeName())
+	}
+
+	// drivers that don't know whether a column is nullable get a pointer, to be on the safe side
+	if n, ok := ct.Nullable(); n || !ok {
+		nullable = true
+	}
+
+	return &cmds.ColumnDefinition{
+		Name:     ct.Name(),
+		Type:     type_,
+		Nullable: nullable,
+	}, nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// scanTypeToColumnType returns the column type matching the scan type reported by the driver,
+// and whether the scan type can hold NULL values. It returns an empty type if there is no match.
+func scanTypeToColumnType(t reflect.Type) (string, bool) {
+	if t == nil {
+		return "", true
+	}
+
+	nullable := false
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+		nullable = true
+	}
+	// sql.NullInt64, sql.NullString, sql.Null[T] and the like store the value along with a Valid flag
+	if t.Kind() == reflect.Struct && t.NumField() == 2 && t.Field(1).Name == "Valid" {
+		t = t.Field(0).Type
+		nullable = true
+	}
+
+	if t == timeType {
+		return "time.Time", nullable
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int64:
+		return "int64", nullable
+	case reflect.Int32:
+		return "int32", nullable
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint64:
+		return "uint64", nullable
+	case reflect.Uint32:
+		return "uint32", nullable
+	case reflect.Float32:
+		return "float32", nullable
+	case reflect.Float64:
+		return "float64", nullable
+	case reflect.Bool:
+		return "bool", nullable
+	case reflect.String:
+		return "string", nullable
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "[]byte", nullable
+		}
+	default:
+	}
+
+	return "", nullable
+}
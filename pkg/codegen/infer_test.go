@@ -0,0 +1,64 @@
+package codegen
+
+import (
+	"context"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	sqleton_cmds "github.com/go-go-golems/sqleton/pkg/cmds"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+	"testing"
+)
+
+func TestInferColumns(t *testing.T) {
+	db, err := sqlx.Connect("sqlite", ":memory:")
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	_, err = db.Exec("CREATE TABLE posts (id INTEGER PRIMARY KEY, title TEXT NOT NULL, score REAL, created_at DATETIME, body BLOB)")
+	require.NoError(t, err)
+
+	newCommand := func(query string, columns []*sqleton_cmds.ColumnDefinition) *sqleton_cmds.SqlCommand {
+		cmd, err := sqleton_cmds.NewSqlCommand(
+			cmds.NewCommandDescription("ls-posts",
+				cmds.WithFlags(parameters.NewParameterDefinition("limit", parameters.ParameterTypeInteger,
+					parameters.WithDefault(10))),
+			),
+			sqleton_cmds.WithQuery(query),
+			sqleton_cmds.WithColumns(columns),
+		)
+		require.NoError(t, err)
+		return cmd
+	}
+
+	cmd := newCommand("SELECT id, title, score, created_at, body FROM posts LIMIT {{ .limit }};", nil)
+	columns, err := InferColumns(context.Background(), db, cmd)
+	require.NoError(t, err)
+	// the sqlite driver reports every column as nullable
+	assert.Equal(t, []*sqleton_cmds.ColumnDefinition{
+		{Name: "id", Type: "int64", Nullable: true},
+		{Name: "title", Type: "string", Nullable: true},
+		{Name: "score", Type: "float64", Nullable: true},
+		{Name: "created_at", Type: "time.Time", Nullable: true},
+		{Name: "body", Type: "[]byte", Nullable: true},
+	}, columns)
+
+	cmd = newCommand("SELECT title, COUNT(*) AS count FROM posts GROUP BY title", nil)
+	_, err = InferColumns(context.Background(), db, cmd)
+	assert.EqualError(t, err, `command ls-posts: could not infer the type of column count (database type ""), declare it in columns:`)
+
+	// declared columns take precedence
+	cmd = newCommand("SELECT title, COUNT(*) AS count FROM posts GROUP BY title", []*sqleton_cmds.ColumnDefinition{
+		{Name: "count", Type: "int64"},
+		{Name: "title", Type: "string"},
+	})
+	columns, err = InferColumns(context.Background(), db, cmd)
+	require.NoError(t, err)
+	assert.Equal(t, []*sqleton_cmds.ColumnDefinition{
+		{Name: "title", Type: "string"},
+		{Name: "count", Type: "int64"},
+	}, columns)
+}
@@ -0,0 +1,254 @@
+package codegen
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/sqleton/pkg/cmds"
+	"github.com/iancoleman/strcase"
+)
+
+// openAPISchema is a schema object of an OpenAPI document, a subset of JSON schema.
+type openAPISchema map[string]interface{}
+
+type openAPIDocument struct {
+	OpenAPI    string                     `json:"openapi"`
+	Info       openAPIInfo                `json:"info"`
+	Paths      map[string]openAPIPathItem `json:"paths"`
+	Components openAPIComponents          `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIComponents struct {
+	Schemas map[string]openAPISchema `json:"schemas"`
+}
+
+type openAPIPathItem struct {
+	Get  *openAPIOperation `json:"get,omitempty"`
+	Post *openAPIOperation `json:"post,omitempty"`
+}
+
+type openAPIOperation struct {
+	OperationID string                     `json:"operationId"`
+	Summary     string                     `json:"summary,omitempty"`
+	Description string                     `json:"description,omitempty"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name        string        `json:"name"`
+	In          string        `json:"in"`
+	Description string        `json:"description,omitempty"`
+	Required    bool          `json:"required,omitempty"`
+	Schema      openAPISchema `json:"schema"`
+}
+
+type openAPIMediaType struct {
+	Schema openAPISchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                        `json:"required,omitempty"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+func jsonContent(schema openAPISchema) map[string]openAPIMediaType {
+	return map[string]openAPIMediaType{"application/json": {Schema: schema}}
+}
+
+func schemaRef(name string) openAPISchema {
+	return openAPISchema{"$ref": "#/components/schemas/" + name}
+}
+
+// parameterSchema returns the schema of the values of the parameter, and false if the parameter
+// can't be passed in requests (see handlers.ParseParameters).
+func parameterSchema(pd *parameters.ParameterDefinition) (openAPISchema, bool) {
+	if pd.Type.IsFile() || pd.Type.NeedsFileContent("") {
+		return nil, false
+	}
+
+	var ret openAPISchema
+	//exhaustive:ignore
+	switch pd.Type {
+	case parameters.ParameterTypeInteger:
+		ret = openAPISchema{"type": "integer"}
+	case parameters.ParameterTypeFloat:
+		ret = openAPISchema{"type": "number"}
+	case parameters.ParameterTypeBool:
+		ret = openAPISchema{"type": "boolean"}
+	case parameters.ParameterTypeChoice:
+		ret = openAPISchema{"type": "string", "enum": pd.Choices}
+	case parameters.ParameterTypeStringList, parameters.ParameterTypeKeyValue:
+		ret = openAPISchema{"type": "array", "items": openAPISchema{"type": "string"}}
+	case parameters.ParameterTypeIntegerList:
+		ret = openAPISchema{"type": "array", "items": openAPISchema{"type": "integer"}}
+	case parameters.ParameterTypeFloatList:
+		ret = openAPISchema{"type": "array", "items": openAPISchema{"type": "number"}}
+	case parameters.ParameterTypeChoiceList:
+		ret = openAPISchema{"type": "array", "items": openAPISchema{"type": "string", "enum": pd.Choices}}
+	default:
+		// strings, and dates which glazed parses in many formats
+		ret = openAPISchema{"type": "string"}
+	}
+
+	if pd.Default != nil {
+		ret["default"] = *pd.Default
+	}
+	return ret, true
+}
+
+// columnSchema returns the schema of the values of the column, as encoded by encoding/json.
+func columnSchema(c *cmds.ColumnDefinition) openAPISchema {
+	var ret openAPISchema
+	switch c.Type {
+	case "int32", "uint32":
+		ret = openAPISchema{"type": "integer", "format": "int32"}
+	case "int", "int64", "uint", "uint64":
+		ret = openAPISchema{"type": "integer", "format": "int64"}
+	case "float32":
+		ret = openAPISchema{"type": "number", "format": "float"}
+	case "float64":
+		ret = openAPISchema{"type": "number", "format": "double"}
+	case "bool":
+		ret = openAPISchema{"type": "boolean"}
+	case "time.Time":
+		ret = openAPISchema{"type": "string", "format": "date-time"}
+	case "[]byte":
+		ret = openAPISchema{"type": "string", "format": "byte"}
+	default:
+		ret = openAPISchema{"type": "string"}
+	}
+
+	if c.Nullable || c.Type == "[]byte" {
+		ret["nullable"] = true
+	}
+	return ret
+}
+
+// GenerateOpenAPIDocument generates the OpenAPI 3 document describing the handlers registered by
+// the code of GenerateHandlersCode. The row schemas of the commands declaring their columns
+// are added to the components of the document.
+func (s *SqlCommandCodeGenerator) GenerateOpenAPIDocument(commands []*cmds.SqlCommand) ([]byte, error) {
+	err := checkCommands(commands)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := openAPIDocument{
+		OpenAPI: "3.0.3",
+		Info: openAPIInfo{
+			Title:   fmt.Sprintf("%s queries", s.PackageName),
+			Version: "1.0.0",
+		},
+		Paths: map[string]openAPIPathItem{},
+		Components: openAPIComponents{
+			Schemas: map[string]openAPISchema{
+				"Error": {
+					"type":       "object",
+					"properties": map[string]openAPISchema{"error": {"type": "string"}},
+					"required":   []string{"error"},
+				},
+			},
+		},
+	}
+
+	for _, cmd := range commands {
+		cmdName := commandName(cmd)
+		description := cmd.Description()
+
+		queryParameters := []openAPIParameter{}
+		properties := map[string]openAPISchema{}
+		required := []string{}
+		addParameter := func(pd *parameters.ParameterDefinition) {
+			schema, ok := parameterSchema(pd)
+			if !ok {
+				return
+			}
+			queryParameters = append(queryParameters, openAPIParameter{
+				Name:        pd.Name,
+				In:          "query",
+				Description: pd.Help,
+				Required:    pd.Required,
+				Schema:      schema,
+			})
+
+			property := openAPISchema{}
+			for k, v := range schema {
+				property[k] = v
+			}
+			if pd.Help != "" {
+				property["description"] = pd.Help
+			}
+			properties[pd.Name] = property
+			if pd.Required {
+				required = append(required, pd.Name)
+			}
+		}
+		commandFlags(cmd).ForEach(addParameter)
+		cmd.GetDefaultArguments().ForEach(addParameter)
+
+		rowSchema := openAPISchema{"type": "object", "additionalProperties": true}
+		if len(cmd.Columns) > 0 {
+			rowName := strcase.ToCamel(cmdName) + "Row"
+			columns := map[string]openAPISchema{}
+			columnNames := []string{}
+			for _, c := range cmd.Columns {
+				columns[c.Name] = columnSchema(c)
+				columnNames = append(columnNames, c.Name)
+			}
+			doc.Components.Schemas[rowName] = openAPISchema{
+				"type":       "object",
+				"properties": columns,
+				"required":   columnNames,
+			}
+			rowSchema = schemaRef(rowName)
+		}
+
+		responses := map[string]openAPIResponse{
+			"200": {
+				Description: "The rows returned by the query",
+				Content:     jsonContent(openAPISchema{"type": "array", "items": rowSchema}),
+			},
+			"400": {Description: "Invalid parameters", Content: jsonContent(schemaRef("Error"))},
+			"500": {Description: "The query failed", Content: jsonContent(schemaRef("Error"))},
+		}
+
+		bodySchema := openAPISchema{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			bodySchema["required"] = required
+		}
+
+		doc.Paths[handlerPath(cmd)] = openAPIPathItem{
+			Get: &openAPIOperation{
+				OperationID: strcase.ToLowerCamel(cmdName),
+				Summary:     description.Short,
+				Description: description.Long,
+				Parameters:  queryParameters,
+				Responses:   responses,
+			},
+			Post: &openAPIOperation{
+				OperationID: strcase.ToLowerCamel(cmdName) + "Post",
+				Summary:     description.Short,
+				Description: description.Long,
+				RequestBody: &openAPIRequestBody{
+					Required: len(required) > 0,
+					Content:  jsonContent(bodySchema),
+				},
+				Responses: responses,
+			},
+		}
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
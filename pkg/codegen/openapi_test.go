@@ -0,0 +1,61 @@
+package codegen
+
+import (
+	"encoding/json"
+	sqleton_cmds "github.com/go-go-golems/sqleton/pkg/cmds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestGenerateOpenAPIDocument(t *testing.T) {
+	s := &SqlCommandCodeGenerator{PackageName: "queries"}
+	b, err := s.GenerateOpenAPIDocument([]*sqleton_cmds.SqlCommand{
+		newHTTPTestCommand(t, []*sqleton_cmds.ColumnDefinition{
+			{Name: "id", Type: "int64"},
+			{Name: "title", Type: "string", Nullable: true},
+		}),
+	})
+	require.NoError(t, err)
+
+	doc := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(b, &doc))
+	assert.Equal(t, "3.0.3", doc["openapi"])
+
+	get := doc["paths"].(map[string]interface{})["/posts/ls"].(map[string]interface{})["get"].(map[string]interface{})
+	assert.Equal(t, "postsLs", get["operationId"])
+	assert.Equal(t, "List posts", get["summary"])
+	// the stringFromFile flag can't be passed in requests
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{
+			"name": "limit", "in": "query", "description": "Maximum number of posts",
+			"schema": map[string]interface{}{"type": "integer", "default": float64(10)},
+		},
+		map[string]interface{}{
+			"name": "order", "in": "query",
+			"schema": map[string]interface{}{"type": "string", "enum": []interface{}{"id", "title"}},
+		},
+		map[string]interface{}{
+			"name": "type", "in": "query", "required": true,
+			"schema": map[string]interface{}{"type": "string"},
+		},
+	}, get["parameters"])
+
+	rows := get["responses"].(map[string]interface{})["200"].(map[string]interface{})["content"].(map[string]interface{})["application/json"]
+	assert.Equal(t, map[string]interface{}{
+		"schema": map[string]interface{}{
+			"type":  "array",
+			"items": map[string]interface{}{"$ref": "#/components/schemas/PostsLsRow"},
+		},
+	}, rows)
+
+	row := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})["PostsLsRow"]
+	assert.Equal(t, map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"id":    map[string]interface{}{"type": "integer", "format": "int64"},
+			"title": map[string]interface{}{"type": "string", "nullable": true},
+		},
+		"required": []interface{}{"id", "title"},
+	}, row)
+}
@@ -0,0 +1,116 @@
+package codegen
+
+import (
+	"fmt"
+	"github.com/dave/jennifer/jen"
+	"github.com/go-go-golems/glazed/pkg/codegen"
+	"github.com/go-go-golems/sqleton/pkg/cmds"
+	"github.com/iancoleman/strcase"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+const CobraPath = "github.com/spf13/cobra"
+
+// GenerateRegisterCode generates the Register function, which adds a cobra command for each of the
+// generated commands to a root command. The parents of the commands (the directories they were loaded from)
+// become parent commands, the same way sqleton lays out the commands of a repository.
+//
+// It fails if two commands (or two parents) generate the same Go identifiers.
+func (s *SqlCommandCodeGenerator) GenerateRegisterCode(commands []*cmds.SqlCommand) (*jen.File, error) {
+	err := checkCommands(commands)
+	if err != nil {
+		return nil, err
+	}
+
+	identifiers := map[string]string{}
+	checkIdentifier := func(identifier string, path string) error {
+		if other, ok := identifiers[identifier]; ok {
+			return errors.Errorf("%s and %s both generate the identifier %s", other, path, identifier)
+		}
+		identifiers[identifier] = path
+		return nil
+	}
+
+	f := jen.NewFile(s.PackageName)
+
+	parentVariables := map[string]string{}
+	f.Comment("Register adds the generated commands to root.")
+	f.Func().Id("Register").Params(jen.Id("root").Op("*").Qual(CobraPath, "Command")).Error().
+		BlockFunc(func(g *jen.Group) {
+			for _, cmd := range commands {
+				description := cmd.Description()
+
+				parentVariable := "root"
+				for i, parent := range description.Parents {
+					path := strings.Join(description.Parents[:i+1], " ")
+					if v, ok := parentVariables[path]; ok {
+						parentVariable = v
+						continue
+					}
+
+					v := strcase.ToLowerCamel(path) + "Cmd"
+					if err = checkIdentifier(v, path); err != nil {
+						return
+					}
+					g.Id(v).Op(":=").Op("&").Qual(CobraPath, "Command").Values(jen.Dict{
+						jen.Id("Use"):   jen.Lit(parent),
+						jen.Id("Short"): jen.Lit(fmt.Sprintf("All commands for %s", parent)),
+					})
+					g.Id(parentVariable).Dot("AddCommand").Call(jen.Id(v))
+					g.Line()
+					parentVariables[path] = v
+					parentVariable = v
+				}
+
+				cmdName := commandName(cmd)
+				v := strcase.ToLowerCamel(cmdName) + "Command"
+				cobraV := "cobra" + strcase.ToCamel(cmdName) + "Command"
+				g.List(jen.Id(v), jen.Err()).Op(":=").Id("New" + strcase.ToCamel(cmdName) + "Command").Call()
+				g.If(jen.Err().Op("!=").Nil()).Block(jen.Return(jen.Err()))
+				g.List(jen.Id(cobraV), jen.Err()).Op(":=").
+					Qual(codegen.ClaySqlPath, "BuildCobraCommandWithSqletonMiddlewares").Call(jen.Id(v))
+				g.If(jen.Err().Op("!=").Nil()).Block(jen.Return(jen.Err()))
+				g.Id(parentVariable).Dot("AddCommand").Call(jen.Id(cobraV))
+				g.Line()
+			}
+			g.Return(jen.Nil())
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// checkCommands returns an error if the commands can't be generated together, see CheckCommandNames
+// and checkConvertible.
+func checkCommands(commands []*cmds.SqlCommand) error {
+	for _, cmd := range commands {
+		err := checkConvertible(cmd)
+		if err != nil {
+			return err
+		}
+	}
+	return CheckCommandNames(commands)
+}
+
+// CheckCommandNames returns an error if two of the commands generate the same Go identifiers,
+// which can't be generated in the same package.
+func CheckCommandNames(commands []*cmds.SqlCommand) error {
+	paths := map[string]string{}
+	for _, cmd := range commands {
+		description := cmd.Description()
+		path := strings.Join(append(append([]string{}, description.Parents...), description.Name), " ")
+		if description.Source != "" {
+			path = fmt.Sprintf("%s (%s)", path, description.Source)
+		}
+
+		identifier := strcase.ToCamel(commandName(cmd)) + "Command"
+		if other, ok := paths[identifier]; ok {
+			return errors.Errorf("%s and %s both generate the identifier %s", other, path, identifier)
+		}
+		paths[identifier] = path
+	}
+	return nil
+}
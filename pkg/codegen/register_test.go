@@ -0,0 +1,48 @@
+package codegen
+
+import (
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	sqleton_cmds "github.com/go-go-golems/sqleton/pkg/cmds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func newRegisterTestCommand(t *testing.T, name string, parents ...string) *sqleton_cmds.SqlCommand {
+	cmd, err := sqleton_cmds.NewSqlCommand(
+		cmds.NewCommandDescription(name, cmds.WithParents(parents...)),
+		sqleton_cmds.WithQuery("SELECT 1"),
+	)
+	require.NoError(t, err)
+	return cmd
+}
+
+func TestGenerateRegisterCode(t *testing.T) {
+	s := &SqlCommandCodeGenerator{PackageName: "queries"}
+	f, err := s.GenerateRegisterCode([]*sqleton_cmds.SqlCommand{
+		newRegisterTestCommand(t, "ls", "posts"),
+		newRegisterTestCommand(t, "count", "posts", "admin"),
+		newRegisterTestCommand(t, "version"),
+	})
+	require.NoError(t, err)
+	code := f.GoString()
+
+	assert.Contains(t, code, "func Register(root *cobra.Command) error {")
+	assert.Contains(t, code, "postsCmd := &cobra.Command{")
+	assert.Contains(t, code, "root.AddCommand(postsCmd)")
+	assert.Contains(t, code, "postsCmd.AddCommand(postsAdminCmd)")
+	assert.Contains(t, code, "postsLsCommand, err := NewPostsLsCommand()")
+	assert.Contains(t, code, "cobraPostsLsCommand, err := sql.BuildCobraCommandWithSqletonMiddlewares(postsLsCommand)")
+	assert.Contains(t, code, "postsCmd.AddCommand(cobraPostsLsCommand)")
+	assert.Contains(t, code, "postsAdminCmd.AddCommand(cobraPostsAdminCountCommand)")
+	assert.Contains(t, code, "root.AddCommand(cobraVersionCommand)")
+	// parents are only created once
+	assert.Equal(t, 1, strings.Count(code, "postsCmd := "))
+
+	_, err = s.GenerateRegisterCode([]*sqleton_cmds.SqlCommand{
+		newRegisterTestCommand(t, "ls-all", "posts"),
+		newRegisterTestCommand(t, "ls_all", "posts"),
+	})
+	assert.EqualError(t, err, "posts ls-all and posts ls_all both generate the identifier PostsLsAllCommand")
+}
@@ -0,0 +1,281 @@
+package codegen
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/sqleton/pkg/cmds"
+	"github.com/iancoleman/strcase"
+	"regexp"
+	"strings"
+)
+
+// TypeScriptClientFileName is the name of the file the TypeScript client is generated to.
+const TypeScriptClientFileName = "client.ts"
+
+// typeScriptPreamble holds the helpers shared by the fetch wrappers of the commands.
+const typeScriptPreamble = `// Code generated by sqleton codegen. DO NOT EDIT.
+
+export interface ClientOptions {
+  /** The URL sqleton serve is listening on, for example http://localhost:8080. Defaults to the current origin. */
+  baseUrl?: string;
+  /** Options passed to fetch, for example headers or an AbortSignal. */
+  init?: RequestInit;
+}
+
+/** SqletonError is thrown when sqleton serve fails to run a command. */
+export class SqletonError extends Error {
+  constructor(readonly status: number, message: string) {
+    super(message);
+    this.name = "SqletonError";
+  }
+}
+
+function formatValue(value: string | number | boolean | Date): string {
+  return value instanceof Date ? value.toISOString() : String(value);
+}
+
+function appendValue(query: URLSearchParams, name: string, value: string | number | boolean | Date | undefined): void {
+  if (value !== undefined) {
+    query.append(name, formatValue(value));
+  }
+}
+
+// lists are passed as name[] parameters, so that their values can contain commas
+function appendList(query: URLSearchParams, name: string, values: (string | number | Date)[] | undefined): void {
+  for (const value of values ?? []) {
+    query.append(name + "[]", formatValue(value));
+  }
+}
+
+function appendKeyValue(query: URLSearchParams, name: string, values: Record<string, string> | undefined): void {
+  for (const [key, value] of Object.entries(values ?? {})) {
+    query.append(name + "[]", key + ":" + value);
+  }
+}
+
+// parameters read from files on the command line are passed as the content of the file
+function appendContent(query: URLSearchParams, name: string, value: unknown, json: boolean): void {
+  if (value === undefined) {
+    return;
+  }
+  if (json) {
+    query.append(name, JSON.stringify(value));
+  } else {
+    query.append(name, Array.isArray(value) ? value.join("\n") : String(value));
+  }
+}
+
+async function fetchRows<T>(path: string, query: URLSearchParams, options: ClientOptions): Promise<T[]> {
+  const response = await fetch((options.baseUrl ?? "") + "/data" + path + "?" + query.toString(), options.init);
+  const text = await response.text();
+  if (!response.ok) {
+    let message = text || response.statusText;
+    try {
+      message = JSON.parse(text).error ?? message;
+    } catch {
+      // not a JSON error
+    }
+    throw new SqletonError(response.status, message);
+  }
+  // commands returning no rows return an empty body
+  return text.trim() === "" ? [] : JSON.parse(text);
+}
+`
+
+var typeScriptIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// typeScriptString returns s as a TypeScript string literal.
+func typeScriptString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// typeScriptPropertyName returns name as a property name, quoting names which aren't identifiers, like from-date.
+func typeScriptPropertyName(name string) string {
+	if typeScriptIdentifier.MatchString(name) {
+		return name
+	}
+	return typeScriptString(name)
+}
+
+func typeScriptPropertyAccess(object string, name string) string {
+	if typeScriptIdentifier.MatchString(name) {
+		return object + "." + name
+	}
+	return object + "[" + typeScriptString(name) + "]"
+}
+
+func typeScriptUnion(choices []string) string {
+	if len(choices) == 0 {
+		return "string"
+	}
+	ret := []string{}
+	for _, c := range choices {
+		ret = append(ret, typeScriptString(c))
+	}
+	return strings.Join(ret, " | ")
+}
+
+// parameterTypeScriptType returns the type of the values of the parameter, along with the helper
+// adding them to the query string of the request, in the format parsed by the handlers of sqleton serve.
+// It returns false if the parameter can't be passed in a request.
+func parameterTypeScriptType(pd *parameters.ParameterDefinition, value string) (string, string, bool) {
+	name := typeScriptString(pd.Name)
+	//exhaustive:ignore
+	switch pd.Type {
+	case parameters.ParameterTypeFile, parameters.ParameterTypeFileList:
+		return "", "", false
+	case parameters.ParameterTypeInteger, parameters.ParameterTypeFloat:
+		return "number", fmt.Sprintf("appendValue(query, %s, %s);", name, value), true
+	case parameters.ParameterTypeBool:
+		return "boolean", fmt.Sprintf("appendValue(query, %s, %s);", name, value), true
+	case parameters.ParameterTypeDate:
+		return "string | Date", fmt.Sprintf("appendValue(query, %s, %s);", name, value), true
+	case parameters.ParameterTypeChoice:
+		return typeScriptUnion(pd.Choices), fmt.Sprintf("appendValue(query, %s, %s);", name, value), true
+	case parameters.ParameterTypeStringList:
+		return "string[]", fmt.Sprintf("appendList(query, %s, %s);", name, value), true
+	case parameters.ParameterTypeIntegerList, parameters.ParameterTypeFloatList:
+		return "number[]", fmt.Sprintf("appendList(query, %s, %s);", name, value), true
+	case parameters.ParameterTypeChoiceList:
+		return "(" + typeScriptUnion(pd.Choices) + ")[]", fmt.Sprintf("appendList(query, %s, %s);", name, value), true
+	case parameters.ParameterTypeKeyValue:
+		return "Record<string, string>", fmt.Sprintf("appendKeyValue(query, %s, %s);", name, value), true
+	case parameters.ParameterTypeStringFromFile, parameters.ParameterTypeStringFromFiles:
+		return "string", fmt.Sprintf("appendContent(query, %s, %s, false);", name, value), true
+	case parameters.ParameterTypeStringListFromFile, parameters.ParameterTypeStringListFromFiles:
+		return "string[]", fmt.Sprintf("appendContent(query, %s, %s, false);", name, value), true
+	case parameters.ParameterTypeObjectFromFile:
+		return "Record<string, unknown>", fmt.Sprintf("appendContent(query, %s, %s, true);", name, value), true
+	case parameters.ParameterTypeObjectListFromFile, parameters.ParameterTypeObjectListFromFiles:
+		return "Record<string, unknown>[]", fmt.Sprintf("appendContent(query, %s, %s, true);", name, value), true
+	default:
+		return "string", fmt.Sprintf("appendValue(query, %s, %s);", name, value), true
+	}
+}
+
+// columnTypeScriptType returns the type of the values of the column, as returned by sqleton serve.
+func columnTypeScriptType(c *cmds.ColumnDefinition) string {
+	ret := "string"
+	switch c.Type {
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64",
+		"float32", "float64":
+		ret = "number"
+	case "bool":
+		ret = "boolean"
+	}
+	if c.Nullable || c.Type == "[]byte" {
+		ret += " | null"
+	}
+	return ret
+}
+
+// writeTypeScriptComment writes a JSDoc comment made of the given paragraphs, skipping empty ones.
+func writeTypeScriptComment(b *strings.Builder, indent string, paragraphs ...string) {
+	lines := []string{}
+	for _, p := range paragraphs {
+		p = strings.TrimSpace(strings.ReplaceAll(p, "*/", "*\\/"))
+		if p == "" {
+			continue
+		}
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, strings.Split(p, "\n")...)
+	}
+	if len(lines) == 0 {
+		return
+	}
+	if len(lines) == 1 {
+		fmt.Fprintf(b, "%s/** %s */\n", indent, lines[0])
+		return
+	}
+	fmt.Fprintf(b, "%s/**\n", indent)
+	for _, l := range lines {
+		fmt.Fprintf(b, "%s%s\n", indent, strings.TrimRight(" * "+l, " "))
+	}
+	fmt.Fprintf(b, "%s */\n", indent)
+}
+
+// GenerateTypeScriptClient generates a TypeScript client for the commands, as served by the command directory
+// handler of sqleton serve at /data/parents/name. Each command gets a parameters interface, a row interface
+// if it declares its columns, and a fetch wrapper named after the command, like fetchPostsLs.
+//
+// Parameters which can't be passed in a query string (file and fileList) are left out.
+func (s *SqlCommandCodeGenerator) GenerateTypeScriptClient(commands []*cmds.SqlCommand) ([]byte, error) {
+	err := checkCommands(commands)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &strings.Builder{}
+	b.WriteString(typeScriptPreamble)
+
+	for _, cmd := range commands {
+		cmdName := strcase.ToCamel(commandName(cmd))
+		description := cmd.Description()
+		parametersInterface := cmdName + "Parameters"
+
+		var appends []string
+		hasRequired := false
+		b.WriteString("\n")
+		writeTypeScriptComment(b, "", fmt.Sprintf("The parameters of %s.", strings.TrimPrefix(handlerPath(cmd), "/")))
+		fmt.Fprintf(b, "export interface %s {\n", parametersInterface)
+		addParameter := func(pd *parameters.ParameterDefinition) {
+			type_, append_, ok := parameterTypeScriptType(pd, typeScriptPropertyAccess("params", pd.Name))
+			if !ok {
+				return
+			}
+			appends = append(appends, append_)
+
+			defaultHelp := ""
+			if pd.Default != nil {
+				v, err := json.Marshal(*pd.Default)
+				if err == nil {
+					defaultHelp = "@default " + string(v)
+				}
+			}
+			writeTypeScriptComment(b, "  ", pd.Help, defaultHelp)
+
+			optional := "?"
+			if pd.Required {
+				optional = ""
+				hasRequired = true
+			}
+			fmt.Fprintf(b, "  %s%s: %s;\n", typeScriptPropertyName(pd.Name), optional, type_)
+		}
+		commandFlags(cmd).ForEach(addParameter)
+		cmd.GetDefaultArguments().ForEach(addParameter)
+		b.WriteString("}\n")
+
+		rowType := "Record<string, unknown>"
+		if len(cmd.Columns) > 0 {
+			rowType = cmdName + "Row"
+			b.WriteString("\n")
+			writeTypeScriptComment(b, "", fmt.Sprintf("A row returned by %s.", strings.TrimPrefix(handlerPath(cmd), "/")))
+			fmt.Fprintf(b, "export interface %s {\n", rowType)
+			for _, c := range cmd.Columns {
+				fmt.Fprintf(b, "  %s: %s;\n", typeScriptPropertyName(c.Name), columnTypeScriptType(c))
+			}
+			b.WriteString("}\n")
+		}
+
+		paramsDefault := " = {}"
+		if hasRequired {
+			paramsDefault = ""
+		}
+		b.WriteString("\n")
+		writeTypeScriptComment(b, "", description.Short, description.Long)
+		fmt.Fprintf(b, "export async function fetch%s(params: %s%s, options: ClientOptions = {}): Promise<%s[]> {\n",
+			cmdName, parametersInterface, paramsDefault, rowType)
+		b.WriteString("  const query = new URLSearchParams();\n")
+		for _, a := range appends {
+			fmt.Fprintf(b, "  %s\n", a)
+		}
+		fmt.Fprintf(b, "  return fetchRows<%s>(%s, query, options);\n", rowType, typeScriptString(handlerPath(cmd)))
+		b.WriteString("}\n")
+	}
+
+	return []byte(b.String()), nil
+}
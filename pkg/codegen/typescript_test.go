@@ -0,0 +1,89 @@
+package codegen
+
+import (
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	sqleton_cmds "github.com/go-go-golems/sqleton/pkg/cmds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestGenerateTypeScriptClient(t *testing.T) {
+	s := &SqlCommandCodeGenerator{PackageName: "queries"}
+	b, err := s.GenerateTypeScriptClient([]*sqleton_cmds.SqlCommand{
+		newHTTPTestCommand(t, []*sqleton_cmds.ColumnDefinition{
+			{Name: "id", Type: "int64"},
+			{Name: "title", Type: "string", Nullable: true},
+		}),
+	})
+	require.NoError(t, err)
+	code := string(b)
+
+	assert.Contains(t, code, `export interface PostsLsParameters {
+  /**
+   * Maximum number of posts
+   *
+   * @default 10
+   */
+  limit?: number;
+  order?: "id" | "title";
+  query?: string;
+  type: string;
+}`)
+	assert.Contains(t, code, `export interface PostsLsRow {
+  id: number;
+  title: string | null;
+}`)
+	// the type argument is required, so are the parameters
+	assert.Contains(t, code, `/** List posts */
+export async function fetchPostsLs(params: PostsLsParameters, options: ClientOptions = {}): Promise<PostsLsRow[]> {
+  const query = new URLSearchParams();
+  appendValue(query, "limit", params.limit);
+  appendValue(query, "order", params.order);
+  appendContent(query, "query", params.query, false);
+  appendValue(query, "type", params.type);
+  return fetchRows<PostsLsRow>("/posts/ls", query, options);
+}`)
+}
+
+func TestGenerateTypeScriptClientParameterTypes(t *testing.T) {
+	cmd, err := sqleton_cmds.NewSqlCommand(
+		cmds.NewCommandDescription("search",
+			cmds.WithFlags(
+				parameters.NewParameterDefinition("from-date", parameters.ParameterTypeDate),
+				parameters.NewParameterDefinition("tags", parameters.ParameterTypeStringList),
+				parameters.NewParameterDefinition("kinds", parameters.ParameterTypeChoiceList,
+					parameters.WithChoices("a", "b")),
+				parameters.NewParameterDefinition("labels", parameters.ParameterTypeKeyValue),
+				parameters.NewParameterDefinition("filter", parameters.ParameterTypeObjectFromFile),
+				parameters.NewParameterDefinition("attachment", parameters.ParameterTypeFile),
+			),
+		),
+		sqleton_cmds.WithQuery("SELECT 1"),
+	)
+	require.NoError(t, err)
+
+	s := &SqlCommandCodeGenerator{PackageName: "queries"}
+	b, err := s.GenerateTypeScriptClient([]*sqleton_cmds.SqlCommand{cmd})
+	require.NoError(t, err)
+	code := string(b)
+
+	// file parameters can't be passed in a query string and are left out
+	assert.Contains(t, code, `export interface SearchParameters {
+  "from-date"?: string | Date;
+  tags?: string[];
+  kinds?: ("a" | "b")[];
+  labels?: Record<string, string>;
+  filter?: Record<string, unknown>;
+}`)
+	assert.Contains(t, code, `export async function fetchSearch(params: SearchParameters = {}, options: ClientOptions = {}): Promise<Record<string, unknown>[]> {
+  const query = new URLSearchParams();
+  appendValue(query, "from-date", params["from-date"]);
+  appendList(query, "tags", params.tags);
+  appendList(query, "kinds", params.kinds);
+  appendKeyValue(query, "labels", params.labels);
+  appendContent(query, "filter", params.filter, true);
+  return fetchRows<Record<string, unknown>>("/search", query, options);
+}`)
+}
@@ -0,0 +1,209 @@
+package connection
+
+import (
+	"fmt"
+	"github.com/go-go-golems/clay/pkg/sql"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"os"
+	"strings"
+
+	// register the drivers for all the database types sqleton ships queries for.
+	// The sqlite driver is written in pure Go, so that the binaries built without cgo support sqlite.
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	TypeMySQL    = "mysql"
+	TypePostgres = "postgres"
+	TypeSqlite   = "sqlite"
+)
+
+func init() {
+	// sqlx only knows the bind type of the cgo sqlite3 driver
+	sqlx.BindDriver(TypeSqlite, sqlx.QUESTION)
+}
+
+// defaultPorts is used when the port was not set explicitly, since the
+// sql-connection layer defaults to the MySQL port.
+var defaultPorts = map[string]int{
+	TypeMySQL:    3306,
+	TypePostgres: 5432,
+	TypeSqlite:   0,
+}
+
+// NormalizeType maps the different spellings of a database type to the name of
+// the database/sql driver that handles it.
+func NormalizeType(type_ string) string {
+	switch strings.ToLower(type_) {
+	case "mysql", "mariadb":
+		return TypeMySQL
+	case "postgres", "postgresql", "pg", "pgsql":
+		return TypePostgres
+	case "sqlite", "sqlite3":
+		return TypeSqlite
+	default:
+		return type_
+	}
+}
+
+// IsInMemorySqlite returns true if the sqlite database is not backed by a plain file path.
+func IsInMemorySqlite(database string) bool {
+	return database == ":memory:" || strings.HasPrefix(database, "file:")
+}
+
+// NewConfigFromParsedLayers creates a DatabaseConfig from the sql-connection and dbt layers,
+// normalizing the database type and using the default port of the database type
+// if the port was not passed explicitly.
+//
+// If a DSN is given, it is used as is and the structured connection flags are ignored.
+// The driver is inferred from the DSN or the database type if --driver is not passed.
+func NewConfigFromParsedLayers(
+	sqlConnectionLayer *layers.ParsedLayer,
+	dbtLayer *layers.ParsedLayer,
+) (*sql.DatabaseConfig, error) {
+	config, err := sql.NewConfigFromParsedLayers(sqlConnectionLayer, dbtLayer)
+	if err != nil {
+		return nil, err
+	}
+
+	config.Type = NormalizeType(config.Type)
+	if config.DSN != "" {
+		if config.Driver == "" {
+			config.Driver = InferDriver(config.DSN, config.Type)
+		}
+		config.Driver = NormalizeType(config.Driver)
+		config.Type = config.Driver
+	}
+	if !isExplicitlySet(sqlConnectionLayer, "port") {
+		if port, ok := defaultPorts[config.Type]; ok {
+			config.Port = port
+		}
+	}
+
+	return config, nil
+}
+
+// ExplicitValues returns the values of the parameters of parsedLayer that were not set from their defaults,
+// so that copying them into another command doesn't turn a default (like the mysql port) into an explicit setting.
+func ExplicitValues(parsedLayer *layers.ParsedLayer) map[string]interface{} {
+	ret := map[string]interface{}{}
+	parsedLayer.Parameters.ForEach(func(name string, p *parameters.ParsedParameter) {
+		if isExplicitlySet(parsedLayer, name) {
+			ret[name] = p.Value
+		}
+	})
+	return ret
+}
+
+func isExplicitlySet(parsedLayer *layers.ParsedLayer, name string) bool {
+	p, ok := parsedLayer.Parameters.Get(name)
+	if !ok {
+		return false
+	}
+	for _, step := range p.Log {
+		if step.Source != parameters.SourceDefaults && step.Source != "default" {
+			return true
+		}
+	}
+	return false
+}
+
+// GetSource returns the source described by the config (either from the flags or from the dbt profiles),
+// with its type normalized.
+func GetSource(config *sql.DatabaseConfig) (*sql.Source, error) {
+	source, err := config.GetSource()
+	if err != nil {
+		return nil, err
+	}
+	source.Type = NormalizeType(source.Type)
+	return source, nil
+}
+
+// Connect opens the database described by config.
+// Contrary to the sqlite driver, it refuses to create a sqlite database file that doesn't exist.
+func Connect(config *sql.DatabaseConfig) (*sqlx.DB, error) {
+	if config.DSN != "" {
+		if config.Driver == "" {
+			return nil, errors.New("no driver given for DSN, use --driver")
+		}
+		driver := NormalizeType(config.Driver)
+		log.Debug().
+			Str("dsn", MaskDSN(driver, config.DSN)).
+			Str("driver", driver).
+			Msg("Using DSN")
+		return sqlx.Connect(driver, config.DSN)
+	}
+
+	source, err := GetSource(config)
+	if err != nil {
+		return nil, err
+	}
+
+	switch source.Type {
+	case TypeSqlite:
+		if source.Database == "" {
+			return nil, errors.New("no sqlite database file given, use --database to pass a path or :memory:")
+		}
+		if !IsInMemorySqlite(source.Database) {
+			if _, err := os.Stat(source.Database); err != nil {
+				return nil, errors.Wrapf(err, "could not open sqlite database %s", source.Database)
+			}
+		}
+	case TypeMySQL, TypePostgres:
+	default:
+		return nil, errors.Errorf("unsupported database type %s", source.Type)
+	}
+
+	config.LogVerbose()
+	return sqlx.Connect(source.Type, source.ToConnectionString())
+}
+
+// ToString describes the database connection, for example to show which database is being tested.
+// The password contained in a DSN is masked.
+func ToString(config *sql.DatabaseConfig) string {
+	if config.DSN != "" {
+		return fmt.Sprintf("dsn: %s, driver: %s", MaskDSN(config.Driver, config.DSN), config.Driver)
+	}
+
+	source, err := GetSource(config)
+	if err == nil && source.Type == TypeSqlite {
+		return fmt.Sprintf("%s:%s", TypeSqlite, source.Database)
+	}
+	return config.ToString()
+}
+
+func OpenDatabaseFromSqlConnectionLayer(
+	parsedLayers *layers.ParsedLayers,
+	sqlConnectionLayerName string,
+	dbtLayerName string,
+) (*sqlx.DB, error) {
+	sqlConnectionLayer, ok := parsedLayers.Get(sqlConnectionLayerName)
+	if !ok {
+		return nil, errors.New("No sql-connection layer found")
+	}
+	dbtLayer, ok := parsedLayers.Get(dbtLayerName)
+	if !ok {
+		return nil, errors.New("No dbt layer found")
+	}
+
+	config, err := NewConfigFromParsedLayers(sqlConnectionLayer, dbtLayer)
+	if err != nil {
+		return nil, err
+	}
+	return Connect(config)
+}
+
+// OpenDatabaseFromDefaultSqlConnectionLayer is the DBConnectionFactory used by the sqleton commands.
+func OpenDatabaseFromDefaultSqlConnectionLayer(
+	parsedLayers *layers.ParsedLayers,
+) (*sqlx.DB, error) {
+	return OpenDatabaseFromSqlConnectionLayer(parsedLayers, sql.SqlConnectionSlug, sql.DbtSlug)
+}
+
+var _ sql.DBConnectionFactory = OpenDatabaseFromDefaultSqlConnectionLayer
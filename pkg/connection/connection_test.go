@@ -0,0 +1,111 @@
+package connection
+
+import (
+	"github.com/go-go-golems/clay/pkg/sql"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/middlewares"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func parseConnectionLayers(t *testing.T, values map[string]interface{}) *layers.ParsedLayers {
+	connectionLayer, err := sql.NewSqlConnectionParameterLayer()
+	require.NoError(t, err)
+	dbtLayer, err := sql.NewDbtParameterLayer()
+	require.NoError(t, err)
+
+	layers_ := layers.NewParameterLayers(layers.WithLayers(connectionLayer, dbtLayer))
+	parsedLayers := layers.NewParsedLayers()
+	err = middlewares.ExecuteMiddlewares(layers_, parsedLayers,
+		middlewares.UpdateFromMap(
+			map[string]map[string]interface{}{
+				sql.SqlConnectionSlug: values,
+			},
+			parameters.WithParseStepSource("map"),
+		),
+		middlewares.SetFromDefaults(parameters.WithParseStepSource(parameters.SourceDefaults)),
+	)
+	require.NoError(t, err)
+
+	return parsedLayers
+}
+
+func newConfig(t *testing.T, values map[string]interface{}) *sql.DatabaseConfig {
+	parsedLayers := parseConnectionLayers(t, values)
+	sqlConnectionLayer, ok := parsedLayers.Get(sql.SqlConnectionSlug)
+	require.True(t, ok)
+	dbtLayer, ok := parsedLayers.Get(sql.DbtSlug)
+	require.True(t, ok)
+
+	config, err := NewConfigFromParsedLayers(sqlConnectionLayer, dbtLayer)
+	require.NoError(t, err)
+	return config
+}
+
+func TestNormalizeType(t *testing.T) {
+	assert.Equal(t, TypePostgres, NormalizeType("postgresql"))
+	assert.Equal(t, TypePostgres, NormalizeType("pg"))
+	assert.Equal(t, TypeSqlite, NormalizeType("sqlite"))
+	assert.Equal(t, TypeMySQL, NormalizeType("MariaDB"))
+	assert.Equal(t, "oracle", NormalizeType("oracle"))
+}
+
+func TestDefaultPort(t *testing.T) {
+	config := newConfig(t, map[string]interface{}{})
+	assert.Equal(t, TypeMySQL, config.Type)
+	assert.Equal(t, 3306, config.Port)
+
+	config = newConfig(t, map[string]interface{}{"db-type": "postgresql"})
+	assert.Equal(t, TypePostgres, config.Type)
+	assert.Equal(t, 5432, config.Port)
+
+	config = newConfig(t, map[string]interface{}{"db-type": "pg", "port": 3306})
+	assert.Equal(t, 3306, config.Port)
+}
+
+func TestExplicitValuesKeepDefaultPort(t *testing.T) {
+	parsedLayers := parseConnectionLayers(t, map[string]interface{}{"host": "db.example.com"})
+	sqlConnectionLayer, ok := parsedLayers.Get(sql.SqlConnectionSlug)
+	require.True(t, ok)
+
+	values := ExplicitValues(sqlConnectionLayer)
+	assert.Equal(t, map[string]interface{}{"host": "db.example.com"}, values)
+
+	// copying the explicit values into a postgres connection keeps the postgres default port
+	values["db-type"] = "postgres"
+	config := newConfig(t, values)
+	assert.Equal(t, 5432, config.Port)
+}
+
+func TestConnectSqlite(t *testing.T) {
+	config := newConfig(t, map[string]interface{}{
+		"db-type":  "sqlite",
+		"database": ":memory:",
+	})
+	db, err := Connect(config)
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	assert.Equal(t, TypeSqlite, db.DriverName())
+
+	var one int
+	err = db.Get(&one, "SELECT 1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, one)
+	assert.Equal(t, "sqlite::memory:", ToString(config))
+}
+
+func TestConnectSqliteMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.db")
+	config := newConfig(t, map[string]interface{}{
+		"db-type":  "sqlite",
+		"database": path,
+	})
+	_, err := Connect(config)
+	assert.Error(t, err)
+	assert.NoFileExists(t, path)
+}
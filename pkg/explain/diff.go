@@ -0,0 +1,123 @@
+package explain
+
+import (
+	"fmt"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"math"
+	"strings"
+)
+
+// NodeDiff compares a node of plan A to the matching node of plan B.
+// A or B is nil if the node only appears in one of the plans.
+type NodeDiff struct {
+	Key     string
+	A       *Node
+	B       *Node
+	Changes []string
+}
+
+func (d *NodeDiff) IsChanged() bool {
+	return len(d.Changes) > 0
+}
+
+func (d *NodeDiff) ToRow() types.Row {
+	ret := types.NewRow(types.MRP("node", d.Key))
+	for _, side := range []struct {
+		prefix string
+		node   *Node
+	}{{"a", d.A}, {"b", d.B}} {
+		n := side.node
+		if n == nil {
+			n = &Node{}
+		}
+		ret.Set(side.prefix+"_operation", n.Operation)
+		ret.Set(side.prefix+"_index", n.Index)
+		ret.Set(side.prefix+"_rows", floatValue(n.Rows))
+		ret.Set(side.prefix+"_cost", floatValue(n.Cost))
+	}
+	ret.Set("changes", strings.Join(d.Changes, ", "))
+	return ret
+}
+
+// DiffPlans matches the nodes of two plans and reports the differences in operation
+// (the access type for MySQL), index, estimated rows and cost.
+//
+// Nodes accessing a table are matched by table name, the other nodes by operation,
+// in the order in which they appear. Estimates are only reported as changed if they
+// differ by more than threshold percent.
+func DiffPlans(a []*Node, b []*Node, threshold float64) []*NodeDiff {
+	keysA := nodeKeys(a)
+	keysB := nodeKeys(b)
+
+	nodesB := map[string]*Node{}
+	for i, key := range keysB {
+		nodesB[key] = b[i]
+	}
+
+	ret := []*NodeDiff{}
+	matched := map[string]bool{}
+	for i, key := range keysA {
+		d := &NodeDiff{Key: key, A: a[i], B: nodesB[key]}
+		if d.B == nil {
+			d.Changes = []string{"only in a"}
+		} else {
+			matched[key] = true
+			d.Changes = compareNodes(d.A, d.B, threshold)
+		}
+		ret = append(ret, d)
+	}
+	for i, key := range keysB {
+		if !matched[key] {
+			ret = append(ret, &NodeDiff{Key: key, B: b[i], Changes: []string{"only in b"}})
+		}
+	}
+
+	return ret
+}
+
+// nodeKeys identifies each node by its table (or operation if it has no table),
+// numbering repeated keys.
+func nodeKeys(nodes []*Node) []string {
+	ret := make([]string, 0, len(nodes))
+	counts := map[string]int{}
+	for _, n := range nodes {
+		key := n.Table
+		if key == "" {
+			key = n.Operation
+		}
+		counts[key]++
+		if counts[key] > 1 {
+			key = fmt.Sprintf("%s #%d", key, counts[key])
+		}
+		ret = append(ret, key)
+	}
+	return ret
+}
+
+func compareNodes(a *Node, b *Node, threshold float64) []string {
+	ret := []string{}
+	if a.Operation != b.Operation {
+		ret = append(ret, "operation")
+	}
+	if a.Index != b.Index {
+		ret = append(ret, "index")
+	}
+	if estimateChanged(a.Rows, b.Rows, threshold) {
+		ret = append(ret, "rows")
+	}
+	if estimateChanged(a.Cost, b.Cost, threshold) {
+		ret = append(ret, "cost")
+	}
+	return ret
+}
+
+func estimateChanged(a *float64, b *float64, threshold float64) bool {
+	if a == nil || b == nil {
+		return (a == nil) != (b == nil)
+	}
+	largest := math.Max(math.Abs(*a), math.Abs(*b))
+	if largest == 0 {
+		return false
+	}
+	return math.Abs(*a-*b)/largest*100 > threshold
+}
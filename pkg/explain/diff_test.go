@@ -0,0 +1,62 @@
+package explain
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func f(v float64) *float64 {
+	return &v
+}
+
+func TestDiffPlans(t *testing.T) {
+	a := []*Node{
+		{Operation: "query_block", Cost: f(100)},
+		{Operation: "ALL", Table: "posts", Rows: f(1000), Cost: f(95)},
+		{Operation: "eq_ref", Table: "users", Index: "PRIMARY", Rows: f(1), Cost: f(100)},
+	}
+	b := []*Node{
+		{Operation: "query_block", Cost: f(105)},
+		{Operation: "ref", Table: "posts", Index: "idx_status", Rows: f(10), Cost: f(5)},
+		{Operation: "eq_ref", Table: "users", Index: "PRIMARY", Rows: f(1), Cost: f(104)},
+		{Operation: "ordering_operation"},
+	}
+
+	diffs := DiffPlans(a, b, 10)
+	require.Len(t, diffs, 4)
+
+	assert.Equal(t, "query_block", diffs[0].Key)
+	assert.False(t, diffs[0].IsChanged())
+
+	assert.Equal(t, "posts", diffs[1].Key)
+	assert.Equal(t, []string{"operation", "index", "rows", "cost"}, diffs[1].Changes)
+
+	assert.Equal(t, "users", diffs[2].Key)
+	assert.False(t, diffs[2].IsChanged())
+
+	assert.Equal(t, "ordering_operation", diffs[3].Key)
+	assert.Nil(t, diffs[3].A)
+	assert.Equal(t, []string{"only in b"}, diffs[3].Changes)
+
+	// a lower threshold reports the small cost changes
+	diffs = DiffPlans(a, b, 1)
+	assert.Equal(t, []string{"cost"}, diffs[0].Changes)
+	assert.Equal(t, []string{"cost"}, diffs[2].Changes)
+}
+
+func TestDiffPlansRepeatedTables(t *testing.T) {
+	a := []*Node{
+		{Operation: "SCAN", Table: "t"},
+		{Operation: "SCAN", Table: "t"},
+	}
+	b := []*Node{
+		{Operation: "SCAN", Table: "t"},
+		{Operation: "SEARCH", Table: "t", Index: "idx"},
+	}
+
+	diffs := DiffPlans(a, b, 10)
+	require.Len(t, diffs, 2)
+	assert.Equal(t, "t #2", diffs[1].Key)
+	assert.Equal(t, []string{"operation", "index"}, diffs[1].Changes)
+}
@@ -0,0 +1,333 @@
+package explain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/go-go-golems/sqleton/pkg/connection"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"strconv"
+	"strings"
+)
+
+const (
+	// FormatNodes outputs the plan as a table of plan nodes, see Node.
+	FormatNodes = "nodes"
+	// FormatTree outputs the plan as rendered by the database, one row per line.
+	FormatTree = "tree"
+	// FormatJSON outputs the JSON plan returned by the database as a single row.
+	FormatJSON = "json"
+)
+
+// Node is a single step of a query plan.
+//
+// The nodes of a plan are flattened in depth-first order. Root nodes have a ParentID of 0.
+// Rows and Cost are the estimates of the planner (the cost unit depends on the database),
+// the Actual fields are only set when the plan was obtained with ANALYZE.
+type Node struct {
+	ID        int
+	ParentID  int
+	Depth     int
+	Operation string
+	Table     string
+	Index     string
+	Rows      *float64
+	Cost      *float64
+	Detail    string
+
+	ActualRows   *float64
+	ActualTimeMs *float64
+	Loops        *float64
+}
+
+func (n *Node) ToRow(analyze bool) types.Row {
+	ret := types.NewRow(
+		types.MRP("id", n.ID),
+		types.MRP("parent_id", n.ParentID),
+		types.MRP("depth", n.Depth),
+		types.MRP("operation", n.Operation),
+		types.MRP("table", n.Table),
+		types.MRP("index", n.Index),
+		types.MRP("rows", floatValue(n.Rows)),
+		types.MRP("cost", floatValue(n.Cost)),
+	)
+	if analyze {
+		ret.Set("actual_rows", floatValue(n.ActualRows))
+		ret.Set("actual_time_ms", floatValue(n.ActualTimeMs))
+		ret.Set("loops", floatValue(n.Loops))
+	}
+	ret.Set("detail", n.Detail)
+	return ret
+}
+
+// Explainer builds and runs the EXPLAIN statement matching the database dialect.
+type Explainer struct {
+	dialect string
+	format  string
+	analyze bool
+}
+
+// NewExplainer returns an Explainer for the database/sql driver driverName.
+// analyze runs the query to report the actual rows and timings.
+func NewExplainer(driverName string, format string, analyze bool) (*Explainer, error) {
+	ret := &Explainer{
+		dialect: connection.NormalizeType(driverName),
+		format:  format,
+		analyze: analyze,
+	}
+	if ret.format == "" {
+		ret.format = FormatNodes
+	}
+
+	switch ret.format {
+	case FormatNodes, FormatTree, FormatJSON:
+	default:
+		return nil, errors.Errorf("unknown explain format %s (expected %s, %s or %s)",
+			format, FormatNodes, FormatTree, FormatJSON)
+	}
+
+	switch ret.dialect {
+	case connection.TypeMySQL:
+		if analyze && ret.format != FormatTree {
+			return nil, errors.Errorf("MySQL only supports EXPLAIN ANALYZE with the %s format", FormatTree)
+		}
+	case connection.TypePostgres:
+	case connection.TypeSqlite:
+		if analyze {
+			return nil, errors.New("SQLite doesn't support EXPLAIN ANALYZE")
+		}
+		if ret.format == FormatJSON {
+			return nil, errors.Errorf("SQLite doesn't support the %s explain format", FormatJSON)
+		}
+	default:
+		return nil, errors.Errorf("explain is not supported for driver %s", driverName)
+	}
+
+	return ret, nil
+}
+
+func (e *Explainer) Format() string {
+	return e.format
+}
+
+func (e *Explainer) Analyze() bool {
+	return e.analyze
+}
+
+// Statement returns the EXPLAIN statement for query. The nodes format uses the
+// machine readable output of the database.
+func (e *Explainer) Statement(query string) string {
+	switch e.dialect {
+	case connection.TypeMySQL:
+		switch {
+		case e.analyze:
+			return "EXPLAIN ANALYZE " + query
+		case e.format == FormatTree:
+			return "EXPLAIN FORMAT=TREE " + query
+		default:
+			return "EXPLAIN FORMAT=JSON " + query
+		}
+	case connection.TypePostgres:
+		options := []string{}
+		if e.analyze {
+			options = append(options, "ANALYZE", "BUFFERS")
+		}
+		if e.format == FormatTree {
+			options = append(options, "FORMAT TEXT")
+		} else {
+			options = append(options, "FORMAT JSON")
+		}
+		return fmt.Sprintf("EXPLAIN (%s) %s", strings.Join(options, ", "), query)
+	default:
+		return "EXPLAIN QUERY PLAN " + query
+	}
+}
+
+// Nodes returns the flattened plan of query.
+func (e *Explainer) Nodes(
+	ctx context.Context,
+	db sqlx.QueryerContext,
+	query string,
+	args []interface{},
+) ([]*Node, error) {
+	if e.dialect == connection.TypeMySQL && e.analyze {
+		return nil, errors.Errorf("MySQL only supports EXPLAIN ANALYZE with the %s format", FormatTree)
+	}
+
+	statement := e.Statement(query)
+	if e.dialect == connection.TypeSqlite {
+		return explainSqlite(ctx, db, statement, args)
+	}
+
+	plan, err := queryPlanText(ctx, db, statement, args)
+	if err != nil {
+		return nil, err
+	}
+	if e.dialect == connection.TypeMySQL {
+		return ParseMySQLJSON([]byte(plan))
+	}
+	return ParsePostgresJSON([]byte(plan))
+}
+
+// Explain outputs the plan of query into gp, in the format of the Explainer.
+func (e *Explainer) Explain(
+	ctx context.Context,
+	db sqlx.QueryerContext,
+	query string,
+	args []interface{},
+	gp middlewares.Processor,
+) error {
+	switch e.format {
+	case FormatTree:
+		lines, err := e.treeLines(ctx, db, query, args)
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			err = gp.AddRow(ctx, types.NewRow(types.MRP("plan", line)))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case FormatJSON:
+		plan, err := queryPlanText(ctx, db, e.Statement(query), args)
+		if err != nil {
+			return err
+		}
+		return gp.AddRow(ctx, types.NewRow(types.MRP("plan", plan)))
+
+	default:
+		nodes, err := e.Nodes(ctx, db, query, args)
+		if err != nil {
+			return err
+		}
+		for _, node := range nodes {
+			err = gp.AddRow(ctx, node.ToRow(e.analyze))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func (e *Explainer) treeLines(
+	ctx context.Context,
+	db sqlx.QueryerContext,
+	query string,
+	args []interface{},
+) ([]string, error) {
+	if e.dialect == connection.TypeSqlite {
+		// sqlite doesn't render its plan, so we draw it like the sqlite3 shell
+		nodes, err := explainSqlite(ctx, db, e.Statement(query), args)
+		if err != nil {
+			return nil, err
+		}
+		ret := make([]string, 0, len(nodes))
+		for _, node := range nodes {
+			ret = append(ret, strings.Repeat("   ", node.Depth)+"|--"+node.Detail)
+		}
+		return ret, nil
+	}
+
+	rows, err := db.QueryxContext(ctx, e.Statement(query), args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not explain query")
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	ret := []string{}
+	for rows.Next() {
+		var line string
+		err = rows.Scan(&line)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not read query plan")
+		}
+		// MySQL returns the whole tree in a single row
+		ret = append(ret, strings.Split(strings.TrimRight(line, "\n"), "\n")...)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "could not read query plan")
+	}
+	return ret, nil
+}
+
+// queryPlanText runs an EXPLAIN statement returning its plan in a single row and column.
+func queryPlanText(ctx context.Context, db sqlx.QueryerContext, statement string, args []interface{}) (string, error) {
+	var plan string
+	err := db.QueryRowxContext(ctx, statement, args...).Scan(&plan)
+	if err != nil {
+		return "", errors.Wrap(err, "could not explain query")
+	}
+	return plan, nil
+}
+
+// flattener assigns the ids of the nodes while walking a plan.
+type flattener struct {
+	nodes []*Node
+}
+
+func (f *flattener) add(node *Node, parent *Node) *Node {
+	node.ID = len(f.nodes) + 1
+	if parent != nil {
+		node.ParentID = parent.ID
+		node.Depth = parent.Depth + 1
+	}
+	f.nodes = append(f.nodes, node)
+	return node
+}
+
+func floatValue(f *float64) interface{} {
+	if f == nil {
+		return nil
+	}
+	return *f
+}
+
+// toFloat converts the numbers found in JSON plans, which MySQL sometimes returns as strings.
+func toFloat(v interface{}) *float64 {
+	var ret float64
+	switch v := v.(type) {
+	case float64:
+		ret = v
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return nil
+		}
+		ret = f
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil
+		}
+		ret = f
+	default:
+		return nil
+	}
+	return &ret
+}
+
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, p := range v {
+			parts = append(parts, toString(p))
+		}
+		return strings.Join(parts, ", ")
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
@@ -0,0 +1,215 @@
+package explain
+
+import (
+	"context"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/middlewares/table"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+const postgresPlan = `[
+  {
+    "Plan": {
+      "Node Type": "Hash Join",
+      "Join Type": "Inner",
+      "Startup Cost": 1.09,
+      "Total Cost": 25.51,
+      "Plan Rows": 8,
+      "Hash Cond": "(p.user_id = u.id)",
+      "Actual Rows": 3,
+      "Actual Total Time": 0.051,
+      "Actual Loops": 1,
+      "Shared Hit Blocks": 2,
+      "Shared Read Blocks": 0,
+      "Plans": [
+        {
+          "Node Type": "Seq Scan",
+          "Relation Name": "posts",
+          "Alias": "p",
+          "Total Cost": 20.7,
+          "Plan Rows": 1070,
+          "Filter": "(status = 'publish'::text)"
+        },
+        {
+          "Node Type": "Hash",
+          "Total Cost": 1.05,
+          "Plan Rows": 3,
+          "Plans": [
+            {
+              "Node Type": "Index Scan",
+              "Relation Name": "users",
+              "Index Name": "users_pkey",
+              "Total Cost": 1.05,
+              "Plan Rows": 3,
+              "Index Cond": "(id < 10)"
+            }
+          ]
+        }
+      ]
+    },
+    "Planning Time": 0.2
+  }
+]`
+
+const mysqlPlan = `{
+  "query_block": {
+    "select_id": 1,
+    "cost_info": {"query_cost": "2.75"},
+    "ordering_operation": {
+      "using_filesort": true,
+      "nested_loop": [
+        {
+          "table": {
+            "table_name": "u",
+            "access_type": "ALL",
+            "possible_keys": ["PRIMARY"],
+            "rows_examined_per_scan": 5,
+            "filtered": "100.00",
+            "cost_info": {"read_cost": "0.25", "eval_cost": "0.50", "prefix_cost": "0.75"}
+          }
+        },
+        {
+          "table": {
+            "table_name": "p",
+            "access_type": "ref",
+            "key": "idx_user_id",
+            "rows_examined_per_scan": 2,
+            "cost_info": {"prefix_cost": "2.75"},
+            "attached_condition": "(p.status = 'publish')"
+          }
+        }
+      ]
+    }
+  }
+}`
+
+const mysqlIteratorPlan = `{
+  "query": "/* select#1 */ select ...",
+  "operation": "Nested loop inner join",
+  "estimated_rows": 10,
+  "estimated_total_cost": 4.5,
+  "inputs": [
+    {"operation": "Table scan on u", "table_name": "u", "access_type": "table", "estimated_rows": 5, "estimated_total_cost": 0.75},
+    {"operation": "Index lookup on p using idx_user_id", "table_name": "p", "index_name": "idx_user_id",
+     "access_type": "index", "estimated_rows": 2, "estimated_total_cost": 1.1}
+  ]
+}`
+
+func TestParsePostgresJSON(t *testing.T) {
+	nodes, err := ParsePostgresJSON([]byte(postgresPlan))
+	require.NoError(t, err)
+	require.Len(t, nodes, 4)
+
+	assert.Equal(t, "Hash Join", nodes[0].Operation)
+	assert.Equal(t, 25.51, *nodes[0].Cost)
+	assert.Equal(t, float64(3), *nodes[0].ActualRows)
+	assert.Equal(t, "Join Type: Inner; Hash Cond: (p.user_id = u.id); Buffers: shared hit=2 read=0", nodes[0].Detail)
+
+	assert.Equal(t, "posts", nodes[1].Table)
+	assert.Equal(t, 1, nodes[1].ParentID)
+	assert.Equal(t, float64(1070), *nodes[1].Rows)
+	assert.Nil(t, nodes[1].ActualRows)
+
+	assert.Equal(t, "Index Scan", nodes[3].Operation)
+	assert.Equal(t, "users_pkey", nodes[3].Index)
+	assert.Equal(t, 3, nodes[3].ParentID)
+	assert.Equal(t, 2, nodes[3].Depth)
+}
+
+func TestParseMySQLJSON(t *testing.T) {
+	nodes, err := ParseMySQLJSON([]byte(mysqlPlan))
+	require.NoError(t, err)
+	require.Len(t, nodes, 4)
+
+	assert.Equal(t, "query_block", nodes[0].Operation)
+	assert.Equal(t, 2.75, *nodes[0].Cost)
+	assert.Equal(t, "select #1", nodes[0].Detail)
+
+	assert.Equal(t, "ordering_operation", nodes[1].Operation)
+	assert.Equal(t, "using filesort", nodes[1].Detail)
+
+	assert.Equal(t, "ALL", nodes[2].Operation)
+	assert.Equal(t, "u", nodes[2].Table)
+	assert.Equal(t, 2, nodes[2].ParentID)
+	assert.Equal(t, float64(5), *nodes[2].Rows)
+
+	assert.Equal(t, "ref", nodes[3].Operation)
+	assert.Equal(t, "idx_user_id", nodes[3].Index)
+	assert.Equal(t, 2, nodes[3].ParentID)
+	assert.Equal(t, 2.75, *nodes[3].Cost)
+	assert.Equal(t, "condition: (p.status = 'publish')", nodes[3].Detail)
+}
+
+func TestParseMySQLIteratorJSON(t *testing.T) {
+	nodes, err := ParseMySQLJSON([]byte(mysqlIteratorPlan))
+	require.NoError(t, err)
+	require.Len(t, nodes, 3)
+
+	assert.Equal(t, "Nested loop inner join", nodes[0].Operation)
+	assert.Equal(t, 1, nodes[2].ParentID)
+	assert.Equal(t, "idx_user_id", nodes[2].Index)
+	assert.Equal(t, 1.1, *nodes[2].Cost)
+}
+
+func TestStatement(t *testing.T) {
+	e, err := NewExplainer("postgres", FormatNodes, true)
+	require.NoError(t, err)
+	assert.Equal(t, "EXPLAIN (ANALYZE, BUFFERS, FORMAT JSON) SELECT 1", e.Statement("SELECT 1"))
+
+	e, err = NewExplainer("mysql", FormatTree, false)
+	require.NoError(t, err)
+	assert.Equal(t, "EXPLAIN FORMAT=TREE SELECT 1", e.Statement("SELECT 1"))
+
+	e, err = NewExplainer("mysql", "", false)
+	require.NoError(t, err)
+	assert.Equal(t, "EXPLAIN FORMAT=JSON SELECT 1", e.Statement("SELECT 1"))
+
+	_, err = NewExplainer("mysql", FormatNodes, true)
+	assert.Error(t, err)
+	_, err = NewExplainer("sqlite", FormatJSON, false)
+	assert.Error(t, err)
+	_, err = NewExplainer("sqlite", "graph", false)
+	assert.Error(t, err)
+}
+
+func TestExplainSqlite(t *testing.T) {
+	db, err := sqlx.Connect("sqlite", ":memory:")
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	_, err = db.Exec(`
+		CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);
+		CREATE TABLE posts (id INTEGER PRIMARY KEY, user_id INTEGER, title TEXT);
+		CREATE INDEX idx_posts_user_id ON posts (user_id);`)
+	require.NoError(t, err)
+
+	e, err := NewExplainer(db.DriverName(), FormatNodes, false)
+	require.NoError(t, err)
+
+	gp := middlewares.NewTableProcessor()
+	gp.AddTableMiddleware(&table.NullTableMiddleware{})
+	ctx := context.Background()
+	err = e.Explain(ctx, db,
+		"SELECT * FROM users u JOIN posts p ON p.user_id = u.id WHERE u.id = ?", []interface{}{1}, gp)
+	require.NoError(t, err)
+	require.NoError(t, gp.Close(ctx))
+
+	rows := gp.GetTable().Rows
+	require.Len(t, rows, 2)
+	tables := []interface{}{}
+	indexes := []interface{}{}
+	for _, row := range rows {
+		v, _ := row.Get("table")
+		tables = append(tables, v)
+		v, _ = row.Get("index")
+		indexes = append(indexes, v)
+	}
+	assert.ElementsMatch(t, []interface{}{"u", "p"}, tables)
+	assert.ElementsMatch(t, []interface{}{"INTEGER PRIMARY KEY", "idx_posts_user_id"}, indexes)
+}
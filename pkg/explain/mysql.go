@@ -0,0 +1,143 @@
+package explain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// mysqlOperations are the keys of the JSON plan (format version 1) holding a single plan step,
+// walked in that order.
+var mysqlOperations = []string{
+	"query_block",
+	"union_result",
+	"windowing",
+	"grouping_operation",
+	"ordering_operation",
+	"duplicates_removal",
+	"buffer_result",
+	"table",
+	"materialized_from_subquery",
+}
+
+// mysqlLists are the keys of the JSON plan (format version 1) holding a list of plan steps.
+var mysqlLists = []string{
+	"nested_loop",
+	"query_specifications",
+	"select_list_subqueries",
+	"attached_subqueries",
+	"optimized_away_subqueries",
+	"order_by_subqueries",
+	"group_by_subqueries",
+	"having_subqueries",
+	"update_value_subqueries",
+}
+
+// ParseMySQLJSON flattens the output of EXPLAIN FORMAT=JSON, in both the classic format
+// and the iterator based format of explain_json_format_version=2.
+func ParseMySQLJSON(data []byte) ([]*Node, error) {
+	plan := map[string]interface{}{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err := decoder.Decode(&plan)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse MySQL plan")
+	}
+
+	f := &flattener{}
+	switch {
+	case plan["query_block"] != nil:
+		f.walkMySQLChildren(plan, nil)
+	case plan["operation"] != nil:
+		f.walkMySQLIterator(plan, nil)
+	default:
+		return nil, errors.New("unknown MySQL plan format")
+	}
+	return f.nodes, nil
+}
+
+func (f *flattener) walkMySQLChildren(plan map[string]interface{}, parent *Node) {
+	for _, key := range mysqlOperations {
+		if step, ok := plan[key].(map[string]interface{}); ok {
+			f.walkMySQLOperation(key, step, parent)
+		}
+	}
+	for _, key := range mysqlLists {
+		steps, _ := plan[key].([]interface{})
+		for _, step := range steps {
+			if step, ok := step.(map[string]interface{}); ok {
+				f.walkMySQLChildren(step, parent)
+			}
+		}
+	}
+}
+
+func (f *flattener) walkMySQLOperation(key string, step map[string]interface{}, parent *Node) {
+	costInfo, _ := step["cost_info"].(map[string]interface{})
+	details := []string{}
+
+	node := &Node{Operation: key}
+	switch key {
+	case "table":
+		node.Operation = toString(step["access_type"])
+		node.Table = toString(step["table_name"])
+		node.Index = toString(step["key"])
+		node.Rows = toFloat(step["rows_examined_per_scan"])
+		node.Cost = toFloat(costInfo["prefix_cost"])
+		if v, ok := step["possible_keys"]; ok {
+			details = append(details, fmt.Sprintf("possible keys: %s", toString(v)))
+		}
+		if v, ok := step["filtered"]; ok {
+			details = append(details, fmt.Sprintf("filtered: %s%%", toString(v)))
+		}
+		if v, ok := step["attached_condition"]; ok {
+			details = append(details, fmt.Sprintf("condition: %s", toString(v)))
+		}
+	case "query_block":
+		node.Cost = toFloat(costInfo["query_cost"])
+		if v, ok := step["select_id"]; ok {
+			details = append(details, fmt.Sprintf("select #%s", toString(v)))
+		}
+	default:
+		node.Cost = toFloat(costInfo["sort_cost"])
+	}
+	for _, flag := range []string{"using_filesort", "using_temporary_table", "using_index"} {
+		if v, ok := step[flag].(bool); ok && v {
+			details = append(details, strings.ReplaceAll(flag, "_", " "))
+		}
+	}
+	if v, ok := step["message"]; ok {
+		details = append(details, toString(v))
+	}
+	node.Detail = strings.Join(details, "; ")
+
+	f.add(node, parent)
+	f.walkMySQLChildren(step, node)
+}
+
+func (f *flattener) walkMySQLIterator(step map[string]interface{}, parent *Node) {
+	node := &Node{
+		Operation:    toString(step["operation"]),
+		Table:        toString(step["table_name"]),
+		Index:        toString(step["index_name"]),
+		Rows:         toFloat(step["estimated_rows"]),
+		Cost:         toFloat(step["estimated_total_cost"]),
+		ActualRows:   toFloat(step["actual_rows"]),
+		ActualTimeMs: toFloat(step["actual_last_row_ms"]),
+		Loops:        toFloat(step["actual_loops"]),
+	}
+	if v, ok := step["access_type"]; ok {
+		node.Detail = fmt.Sprintf("access type: %s", toString(v))
+	}
+
+	f.add(node, parent)
+
+	inputs, _ := step["inputs"].([]interface{})
+	for _, input := range inputs {
+		if input, ok := input.(map[string]interface{}); ok {
+			f.walkMySQLIterator(input, node)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package explain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// postgresConditions are the node properties that end up in the detail of a node, in that order.
+var postgresConditions = []string{
+	"Join Type",
+	"Strategy",
+	"Hash Cond",
+	"Merge Cond",
+	"Index Cond",
+	"Recheck Cond",
+	"Join Filter",
+	"Filter",
+	"Sort Key",
+	"Group Key",
+}
+
+// ParsePostgresJSON flattens the output of EXPLAIN (FORMAT JSON).
+func ParsePostgresJSON(data []byte) ([]*Node, error) {
+	plans := []map[string]interface{}{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err := decoder.Decode(&plans)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse PostgreSQL plan")
+	}
+
+	f := &flattener{}
+	for _, plan := range plans {
+		root, ok := plan["Plan"].(map[string]interface{})
+		if !ok {
+			return nil, errors.New("PostgreSQL plan has no Plan node")
+		}
+		f.walkPostgres(root, nil)
+	}
+	return f.nodes, nil
+}
+
+func (f *flattener) walkPostgres(plan map[string]interface{}, parent *Node) {
+	node := &Node{
+		Operation:    toString(plan["Node Type"]),
+		Table:        toString(plan["Relation Name"]),
+		Index:        toString(plan["Index Name"]),
+		Rows:         toFloat(plan["Plan Rows"]),
+		Cost:         toFloat(plan["Total Cost"]),
+		ActualRows:   toFloat(plan["Actual Rows"]),
+		ActualTimeMs: toFloat(plan["Actual Total Time"]),
+		Loops:        toFloat(plan["Actual Loops"]),
+	}
+
+	details := []string{}
+	for _, key := range postgresConditions {
+		if v, ok := plan[key]; ok {
+			details = append(details, fmt.Sprintf("%s: %s", key, toString(v)))
+		}
+	}
+	hit, hasHit := plan["Shared Hit Blocks"]
+	read, hasRead := plan["Shared Read Blocks"]
+	if hasHit || hasRead {
+		details = append(details, fmt.Sprintf("Buffers: shared hit=%s read=%s", toString(hit), toString(read)))
+	}
+	node.Detail = strings.Join(details, "; ")
+
+	f.add(node, parent)
+
+	children, _ := plan["Plans"].([]interface{})
+	for _, child := range children {
+		if child, ok := child.(map[string]interface{}); ok {
+			f.walkPostgres(child, node)
+		}
+	}
+}
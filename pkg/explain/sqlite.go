@@ -0,0 +1,74 @@
+package explain
+
+import (
+	"context"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"regexp"
+	"strings"
+)
+
+var sqliteIndexRegexp = regexp.MustCompile(
+	`USING (?:COVERING )?INDEX (\w+)|USING (INTEGER PRIMARY KEY)|USING (AUTOMATIC)(?: PARTIAL)?(?: COVERING)? INDEX`)
+
+// SqlitePlanRow is a row returned by EXPLAIN QUERY PLAN.
+type SqlitePlanRow struct {
+	ID      int    `db:"id"`
+	Parent  int    `db:"parent"`
+	NotUsed int    `db:"notused"`
+	Detail  string `db:"detail"`
+}
+
+func explainSqlite(ctx context.Context, db sqlx.QueryerContext, statement string, args []interface{}) ([]*Node, error) {
+	rows, err := db.QueryxContext(ctx, statement, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not explain query")
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	planRows := []SqlitePlanRow{}
+	for rows.Next() {
+		row := SqlitePlanRow{}
+		err = rows.StructScan(&row)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not read query plan")
+		}
+		planRows = append(planRows, row)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "could not read query plan")
+	}
+
+	return ParseSqlitePlan(planRows), nil
+}
+
+// ParseSqlitePlan turns the rows of EXPLAIN QUERY PLAN into nodes. SQLite doesn't report
+// costs or row estimates, the table and index are extracted from the detail of each row.
+func ParseSqlitePlan(rows []SqlitePlanRow) []*Node {
+	f := &flattener{}
+	nodesBySqliteID := map[int]*Node{}
+
+	for _, row := range rows {
+		node := &Node{
+			Operation: row.Detail,
+			Detail:    row.Detail,
+		}
+		words := strings.Fields(row.Detail)
+		if len(words) >= 2 && (words[0] == "SCAN" || words[0] == "SEARCH") && row.Detail != "SCAN CONSTANT ROW" {
+			node.Operation = words[0]
+			node.Table = words[1]
+		}
+		if m := sqliteIndexRegexp.FindStringSubmatch(row.Detail); m != nil {
+			node.Index = m[1] + m[2]
+			if m[3] != "" {
+				node.Index = "automatic index"
+			}
+		}
+
+		nodesBySqliteID[row.ID] = f.add(node, nodesBySqliteID[row.Parent])
+	}
+
+	return f.nodes
+}
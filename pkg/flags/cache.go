@@ -0,0 +1,28 @@
+package flags
+
+import (
+	_ "embed"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/pkg/errors"
+)
+
+//go:embed "cache.yaml"
+var cacheFlagsYaml []byte
+
+const SqlCacheSlug = "sql-cache"
+
+type SqlCacheSettings struct {
+	Cache    bool   `glazed.parameter:"cache"`
+	NoCache  bool   `glazed.parameter:"no-cache"`
+	CacheDir string `glazed.parameter:"cache-dir"`
+}
+
+func NewSqlCacheParameterLayer(
+	options ...layers.ParameterLayerOptions,
+) (*layers.ParameterLayerImpl, error) {
+	ret, err := layers.NewParameterLayerFromYAML(cacheFlagsYaml, options...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to initialize cache parameter layer")
+	}
+	return ret, nil
+}
@@ -0,0 +1,27 @@
+package flags
+
+import (
+	_ "embed"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/pkg/errors"
+)
+
+//go:embed "exec.yaml"
+var execFlagsYaml []byte
+
+const SqlExecSlug = "sql-exec"
+
+type SqlExecSettings struct {
+	DryRun bool `glazed.parameter:"dry-run"`
+	Yes    bool `glazed.parameter:"yes"`
+}
+
+func NewSqlExecParameterLayer(
+	options ...layers.ParameterLayerOptions,
+) (*layers.ParameterLayerImpl, error) {
+	ret, err := layers.NewParameterLayerFromYAML(execFlagsYaml, options...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to initialize exec parameter layer")
+	}
+	return ret, nil
+}
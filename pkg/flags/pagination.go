@@ -0,0 +1,36 @@
+package flags
+
+import (
+	_ "embed"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/pkg/errors"
+)
+
+//go:embed "pagination.yaml"
+var paginationFlagsYaml []byte
+
+const SqlPaginationSlug = "sql-pagination"
+
+type SqlPaginationSettings struct {
+	PageSize int    `glazed.parameter:"page-size"`
+	Cursor   string `glazed.parameter:"cursor"`
+	AllPages bool   `glazed.parameter:"all-pages"`
+}
+
+// NewSqlPaginationParameterLayer creates the pagination layer, with pageSize as the default of --page-size.
+func NewSqlPaginationParameterLayer(
+	pageSize int,
+	options ...layers.ParameterLayerOptions,
+) (*layers.ParameterLayerImpl, error) {
+	ret, err := layers.NewParameterLayerFromYAML(paginationFlagsYaml, options...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to initialize pagination parameter layer")
+	}
+	pd, ok := ret.GetParameterDefinitions().Get("page-size")
+	if !ok {
+		return nil, errors.New("pagination parameter layer has no page-size flag")
+	}
+	var default_ interface{} = pageSize
+	pd.Default = &default_
+	return ret, nil
+}
@@ -0,0 +1,28 @@
+package flags
+
+import (
+	_ "embed"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/pkg/errors"
+)
+
+//go:embed "params.yaml"
+var paramsFlagsYaml []byte
+
+const SqlParamsSlug = "sql-params"
+
+type SqlParamsSettings struct {
+	Params         []string `glazed.parameter:"param"`
+	ParamsFile     string   `glazed.parameter:"params-file"`
+	RenderTemplate bool     `glazed.parameter:"render-template"`
+}
+
+func NewSqlParamsParameterLayer(
+	options ...layers.ParameterLayerOptions,
+) (*layers.ParameterLayerImpl, error) {
+	ret, err := layers.NewParameterLayerFromYAML(paramsFlagsYaml, options...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to initialize params parameter layer")
+	}
+	return ret, nil
+}
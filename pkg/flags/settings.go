@@ -12,8 +12,11 @@ var helpersFlagsYaml []byte
 const SqlHelpersSlug = "sql-helpers"
 
 type SqlHelpersSettings struct {
-	Explain    bool `glazed.parameter:"explain"`
-	PrintQuery bool `glazed.parameter:"print-query"`
+	Explain        bool   `glazed.parameter:"explain"`
+	ExplainFormat  string `glazed.parameter:"explain-format"`
+	ExplainAnalyze bool   `glazed.parameter:"explain-analyze"`
+	PrintQuery     bool   `glazed.parameter:"print-query"`
+	Timeout        string `glazed.parameter:"timeout"`
 }
 
 func NewSqlHelpersParameterLayer(
@@ -0,0 +1,247 @@
+package lint
+
+import (
+	"fmt"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/sqleton/pkg/cmds"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// escapingFunctions are the template functions whose output can be inserted into a query:
+// the quoting and binding helpers, and functions returning numbers.
+var escapingFunctions = map[string]bool{
+	"sqlString":      true,
+	"sqlStringIn":    true,
+	"sqlStringLike":  true,
+	"sqlIn":          true,
+	"sqlIntIn":       true,
+	"sqlLike":        true,
+	"sqlDate":        true,
+	"sqlDateTime":    true,
+	"sqliteDate":     true,
+	"sqliteDateTime": true,
+	"sqlEscape":      true,
+	"sqlBind":        true,
+	"sqlBindIn":      true,
+	"len":            true,
+}
+
+// databaseFunctions are the template functions returning values read from the database.
+var databaseFunctions = map[string]bool{
+	"sqlColumn": true,
+	"sqlSlice":  true,
+	"sqlSingle": true,
+	"sqlMap":    true,
+}
+
+// stringParameterTypes are the parameter types whose values are free-form strings.
+// Choices are restricted to their declared values, and dates are parsed, so they can't inject SQL.
+var stringParameterTypes = map[parameters.ParameterType]bool{
+	parameters.ParameterTypeString:              true,
+	parameters.ParameterTypeStringList:          true,
+	parameters.ParameterTypeStringFromFile:      true,
+	parameters.ParameterTypeStringFromFiles:     true,
+	parameters.ParameterTypeStringListFromFile:  true,
+	parameters.ParameterTypeStringListFromFiles: true,
+	parameters.ParameterTypeKeyValue:            true,
+}
+
+// interpolation is an output action inserting a free-form value into the query without escaping it.
+type interpolation struct {
+	pos parse.Pos
+	// source describes where the value comes from
+	source string
+}
+
+// taint tracks which values of a template are free-form strings: the string parameters,
+// the values read from the database, and the variables (and dot) derived from them.
+type taint struct {
+	parameters map[string]bool
+	variables  map[string]string
+	// dot is the source of the current value of dot, if it is tainted. At the root, dot is the data map.
+	dot       string
+	dotIsRoot bool
+	found     []interpolation
+}
+
+func getUnescapedInterpolations(templates []*template.Template, parameters map[string]bool) []interpolation {
+	ret := []interpolation{}
+	for _, t := range templates {
+		tt := &taint{
+			parameters: parameters,
+			variables:  map[string]string{},
+			dotIsRoot:  true,
+		}
+		tt.walk(t.Tree.Root)
+		ret = append(ret, tt.found...)
+	}
+	return ret
+}
+
+// scope returns a copy of the taint for a nested block, where variables can be shadowed
+// and dot can be rebound without affecting the enclosing block.
+func (t *taint) scope() *taint {
+	variables := map[string]string{}
+	for k, v := range t.variables {
+		variables[k] = v
+	}
+	return &taint{
+		parameters: t.parameters,
+		variables:  variables,
+		dot:        t.dot,
+		dotIsRoot:  t.dotIsRoot,
+	}
+}
+
+func (t *taint) walk(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			t.walk(child)
+		}
+	case *parse.ActionNode:
+		source := t.pipe(n.Pipe)
+		if len(n.Pipe.Decl) > 0 {
+			for _, v := range n.Pipe.Decl {
+				t.variables[v.Ident[0]] = source
+			}
+			return
+		}
+		if source != "" {
+			t.found = append(t.found, interpolation{pos: n.Pos, source: source})
+		}
+	case *parse.IfNode:
+		t.pipe(n.Pipe)
+		t.block(n.List, t.dot, t.dotIsRoot, nil, "")
+		t.block(n.ElseList, t.dot, t.dotIsRoot, nil, "")
+	case *parse.RangeNode:
+		// ranging over a tainted value yields tainted elements, the index is never tainted
+		source := t.pipe(n.Pipe)
+		var element *parse.VariableNode
+		if len(n.Pipe.Decl) > 0 {
+			element = n.Pipe.Decl[len(n.Pipe.Decl)-1]
+		}
+		t.block(n.List, source, false, element, source)
+		t.block(n.ElseList, t.dot, t.dotIsRoot, nil, "")
+	case *parse.WithNode:
+		source := t.pipe(n.Pipe)
+		var value *parse.VariableNode
+		if len(n.Pipe.Decl) > 0 {
+			value = n.Pipe.Decl[0]
+		}
+		t.block(n.List, source, false, value, source)
+		t.block(n.ElseList, t.dot, t.dotIsRoot, nil, "")
+	}
+}
+
+// block walks a nested list, with dot bound to a value from dotSource, and variable bound to a value from source.
+func (t *taint) block(list *parse.ListNode, dotSource string, dotIsRoot bool, variable *parse.VariableNode, source string) {
+	if list == nil {
+		return
+	}
+	t_ := t.scope()
+	t_.dot = dotSource
+	t_.dotIsRoot = dotIsRoot
+	if variable != nil {
+		t_.variables[variable.Ident[0]] = source
+	}
+	t_.walk(list)
+	t.found = append(t.found, t_.found...)
+}
+
+// pipe returns the source of the value of a pipeline if it is a free-form value, or "".
+func (t *taint) pipe(pipe *parse.PipeNode) string {
+	if pipe == nil {
+		return ""
+	}
+	source := ""
+	for _, cmd := range pipe.Cmds {
+		source = t.command(cmd, source)
+	}
+	return source
+}
+
+// command returns the source of the value of a command, given the source of the value piped into it.
+func (t *taint) command(cmd *parse.CommandNode, piped string) string {
+	if len(cmd.Args) == 0 {
+		return piped
+	}
+	if identifier, ok := cmd.Args[0].(*parse.IdentifierNode); ok {
+		if escapingFunctions[identifier.Ident] {
+			return ""
+		}
+		if databaseFunctions[identifier.Ident] {
+			return "the result of " + identifier.Ident
+		}
+		// other functions (printf, join, ...) pass their arguments through
+		for _, arg := range cmd.Args[1:] {
+			if source := t.operand(arg); source != "" {
+				return source
+			}
+		}
+		return piped
+	}
+	if source := t.operand(cmd.Args[0]); source != "" {
+		return source
+	}
+	return piped
+}
+
+func (t *taint) operand(node parse.Node) string {
+	switch n := node.(type) {
+	case *parse.FieldNode:
+		if t.dotIsRoot {
+			if t.parameters[n.Ident[0]] {
+				return "parameter " + n.Ident[0]
+			}
+			return ""
+		}
+		return t.dot
+	case *parse.VariableNode:
+		if n.Ident[0] == "$" {
+			if len(n.Ident) > 1 && t.parameters[n.Ident[1]] {
+				return "parameter " + n.Ident[1]
+			}
+			return ""
+		}
+		return t.variables[n.Ident[0]]
+	case *parse.DotNode:
+		if t.dotIsRoot {
+			return ""
+		}
+		return t.dot
+	case *parse.ChainNode:
+		return t.operand(n.Node)
+	case *parse.PipeNode:
+		return t.pipe(n)
+	}
+	return ""
+}
+
+// stringParameters returns the names of the parameters of the command with a free-form string type.
+func stringParameters(s *cmds.SqlCommand) map[string]bool {
+	ret := map[string]bool{}
+	s.Description().Layers.ForEach(func(_ string, l layers.ParameterLayer) {
+		for _, pd := range l.GetParameterDefinitions().ToList() {
+			if stringParameterTypes[pd.Type] {
+				ret[pd.Name] = true
+			}
+		}
+	})
+	return ret
+}
+
+func unescapedMessage(templateName string, source string) string {
+	ret := fmt.Sprintf("%s inserts %s without escaping it, pass it through sqlString, sqlStringIn, sqlLike, ...",
+		templateName, source)
+	if strings.HasPrefix(source, "parameter ") {
+		ret += " or declare the parameter as a choice"
+	}
+	return ret
+}
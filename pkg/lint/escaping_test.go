@@ -0,0 +1,54 @@
+package lint
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestCheckEscaping(t *testing.T) {
+	problems := lintString(t, `name: ls
+short: List
+flags:
+  - name: title
+    type: string
+  - name: slugs
+    type: stringList
+  - name: order_by
+    type: choice
+    choices: [id, title]
+  - name: limit
+    type: int
+query: |
+  SELECT * FROM posts WHERE 1=1
+  {{ if .title }} AND title = {{ .title | sqlString }} AND title LIKE '%{{ .title }}%'{{ end }}
+  {{ range $i, $slug := .slugs }} OR slug = '{{ $slug }}' OR id = {{ $i }}{{ end }}
+  {{ range .slugs }} OR slug LIKE {{ sqlLike . }} OR slug = '{{ . | upper }}'{{ end }}
+  {{ $title := printf "%s%%" .title }} AND title LIKE '{{ $title }}'
+  ORDER BY {{ .order_by }} LIMIT {{ .limit }}
+`)
+	require.Len(t, problems, 4)
+	for i, line := range []int{15, 16, 17, 18} {
+		assert.Equal(t, line, problems[i].Line, problems[i].String())
+		assert.Equal(t, LevelWarning, problems[i].Level)
+	}
+	assert.Contains(t, problems[0].Message, "inserts parameter title without escaping it")
+	assert.Contains(t, problems[1].Message, "inserts parameter slugs without escaping it")
+}
+
+func TestCheckEscapingDatabaseValues(t *testing.T) {
+	problems := lintString(t, `name: counts
+short: Counts
+query: |
+  {{ $types := sqlColumn "SELECT DISTINCT post_type FROM posts" }}
+  SELECT
+  {{ range $i, $type := $types }}
+    {{ if $i }},{{ end }}
+    SUM(post_type = {{ $type | sqlString }}) AS `+"`{{ $type }}`"+`
+  {{ end }}
+  FROM posts
+`)
+	require.Len(t, problems, 1)
+	assert.Equal(t, 8, problems[0].Line)
+	assert.Contains(t, problems[0].Message, "inserts the result of sqlColumn without escaping it")
+}
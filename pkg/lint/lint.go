@@ -0,0 +1,379 @@
+// Package lint checks sqleton command files: their YAML has to match the command format,
+// and their query templates have to reference existing parameters.
+package lint
+
+import (
+	"fmt"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/sqleton/pkg/cmds"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+type Level string
+
+const (
+	LevelError   Level = "error"
+	LevelWarning Level = "warning"
+)
+
+// Problem is an issue found in a command file. Line is 0 if the issue can't be located.
+type Problem struct {
+	File    string
+	Line    int
+	Level   Level
+	Message string
+}
+
+func (p *Problem) String() string {
+	if p.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s: %s", p.File, p.Line, p.Level, p.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", p.File, p.Level, p.Message)
+}
+
+// HasErrors returns true if one of the problems is an error.
+func HasErrors(problems []*Problem) bool {
+	for _, p := range problems {
+		if p.Level == LevelError {
+			return true
+		}
+	}
+	return false
+}
+
+// LintFS lints all the command files of the repository rooted at root in f.
+// The problems are reported with prefix prepended to the path of the files.
+func LintFS(f fs.FS, root string, prefix string) ([]*Problem, error) {
+	loader := &cmds.SqlCommandLoader{}
+	ret := []*Problem{}
+
+	err := fs.WalkDir(f, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			// the doc directory of a repository contains help pages, not commands
+			if p == path.Join(root, "doc") {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !loader.IsFileSupported(f, p) {
+			return nil
+		}
+		ret = append(ret, LintFile(f, p, path.Join(prefix, p))...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// LintFile lints the command file fileName of f, reporting the problems under name.
+func LintFile(f fs.FS, fileName string, name string) []*Problem {
+	content, err := fs.ReadFile(f, fileName)
+	if err != nil {
+		return []*Problem{{File: name, Level: LevelError, Message: err.Error()}}
+	}
+
+	loader := &cmds.SqlCommandLoader{}
+	commands, err := loader.LoadCommands(f, fileName, nil, nil)
+	if err != nil {
+		return loadProblems(name, err)
+	}
+
+	root, queryLine, err := parseCommandFile(fileName, content)
+	if err != nil {
+		return loadProblems(name, err)
+	}
+
+	ret := []*Problem{}
+	for _, command := range commands {
+		switch c := command.(type) {
+		case *cmds.SqlCommand:
+			ret = append(ret, lintSqlCommand(name, root, queryLine, c)...)
+		case *cmds.ExecCommand:
+			ret = append(ret, lintSqlCommand(name, root, queryLine, c.SqlCommand)...)
+		default:
+			// aliases don't have a query of their own
+		}
+	}
+
+	return ret
+}
+
+// parseCommandFile returns the YAML document describing the command of a file, and the line on which its query starts.
+// For .sql files, the document is the front matter, whose lines are the lines of the file.
+func parseCommandFile(fileName string, content []byte) (*yaml.Node, int, error) {
+	root := &yaml.Node{}
+	if cmds.IsSqlFileName(fileName) {
+		sf, err := cmds.ParseSqlFile(string(content))
+		if err != nil {
+			return nil, 0, err
+		}
+		if sf == nil {
+			return nil, 0, errors.Errorf("%s doesn't start with a front matter describing the command", fileName)
+		}
+		err = yaml.Unmarshal([]byte(sf.FrontMatter), root)
+		if err != nil {
+			return nil, 0, err
+		}
+		return root, sf.QueryLine, nil
+	}
+
+	err := yaml.Unmarshal(content, root)
+	if err != nil {
+		return nil, 0, err
+	}
+	return root, scalarLine(lookup(root, "query")), nil
+}
+
+// scalarLine returns the line on which the content of a scalar starts.
+func scalarLine(node *yaml.Node) int {
+	if node == nil {
+		return 0
+	}
+	// the content of block scalars starts on the line after the | or >
+	if node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		return node.Line + 1
+	}
+	return node.Line
+}
+
+var yamlErrorRegexp = regexp.MustCompile(`line (\d+): (.*)`)
+
+// loadProblems splits the error returned when loading a command into one problem per YAML error.
+func loadProblems(name string, err error) []*Problem {
+	ret := []*Problem{}
+	for _, match := range yamlErrorRegexp.FindAllStringSubmatch(err.Error(), -1) {
+		line, _ := strconv.Atoi(match[1])
+		ret = append(ret, &Problem{File: name, Line: line, Level: LevelError, Message: match[2]})
+	}
+	if len(ret) == 0 {
+		ret = append(ret, &Problem{File: name, Level: LevelError, Message: err.Error()})
+	}
+	return ret
+}
+
+var templateErrorRegexp = regexp.MustCompile(`template: [^:]*:(\d+):`)
+
+// queryTemplate is one of the templates of a command: its query, one of its subqueries
+// or one of the partials it includes.
+type queryTemplate struct {
+	name string
+	text string
+	// firstLine is the line of the file on which the template starts, 0 if unknown
+	firstLine int
+	// templates are the parsed templates, the template itself along with the templates it defines
+	templates []*template.Template
+}
+
+// line returns the line of the file containing the given line of the template.
+func (qt *queryTemplate) line(templateLine int) int {
+	if qt.firstLine == 0 {
+		return 0
+	}
+	return qt.firstLine + templateLine - 1
+}
+
+func (qt *queryTemplate) lineAt(offset int) int {
+	if offset > len(qt.text) {
+		offset = len(qt.text)
+	}
+	return qt.line(strings.Count(qt.text[:offset], "\n") + 1)
+}
+
+// parseCommandTemplates parses the query and the subqueries of a command, and the partials included by the query.
+// The templates that can't be parsed are reported as errors.
+func parseCommandTemplates(name string, root *yaml.Node, queryLine int, s *cmds.SqlCommand) ([]*queryTemplate, []*Problem) {
+	texts := []*queryTemplate{{name: "query", text: s.Query, firstLine: queryLine}}
+	subQueryNames := make([]string, 0, len(s.SubQueries))
+	for subQueryName := range s.SubQueries {
+		subQueryNames = append(subQueryNames, subQueryName)
+	}
+	sort.Strings(subQueryNames)
+	for _, subQueryName := range subQueryNames {
+		texts = append(texts, &queryTemplate{
+			name:      "subquery " + subQueryName,
+			text:      s.SubQueries[subQueryName],
+			firstLine: scalarLine(lookup(root, "subqueries", subQueryName)),
+		})
+	}
+
+	ret := []*queryTemplate{}
+	problems := []*Problem{}
+	for i, qt := range texts {
+		// subqueries are rendered by clay, without the partials
+		var partials map[string]string
+		if i == 0 {
+			partials = s.Partials
+		}
+		t, err := cmds.ParseQueryTemplate(qt.text, s.SubQueries, partials)
+		if err != nil {
+			line := 0
+			if match := templateErrorRegexp.FindStringSubmatch(err.Error()); match != nil {
+				templateLine, _ := strconv.Atoi(match[1])
+				line = qt.line(templateLine)
+			}
+			problems = append(problems, &Problem{File: name, Line: line, Level: LevelError,
+				Message: fmt.Sprintf("%s: %s", qt.name, err.Error())})
+			continue
+		}
+
+		// the templates defined by a partial are reported as part of the partial
+		byParseName := map[string][]*template.Template{}
+		for _, t_ := range t.Templates() {
+			if t_.Tree != nil && t_.Tree.Root != nil {
+				byParseName[t_.Tree.ParseName] = append(byParseName[t_.Tree.ParseName], t_)
+			}
+		}
+		qt.templates = byParseName[t.Name()]
+		ret = append(ret, qt)
+
+		partialNames := make([]string, 0, len(partials))
+		for partialName := range partials {
+			partialNames = append(partialNames, partialName)
+		}
+		sort.Strings(partialNames)
+		for _, partialName := range partialNames {
+			ret = append(ret, &queryTemplate{
+				name:      "partial " + partialName,
+				text:      partials[partialName],
+				templates: byParseName[partialName],
+			})
+		}
+	}
+
+	return ret, problems
+}
+
+// CheckEscaping reports the free-form values (string parameters and values read from the database)
+// that the templates of the command insert into the query without escaping them.
+// content is the content of the file name the command was loaded from, used to locate the problems.
+func CheckEscaping(name string, content []byte, s *cmds.SqlCommand) []*Problem {
+	root, queryLine, err := parseCommandFile(name, content)
+	if err != nil {
+		root, queryLine = nil, 0
+	}
+
+	ret := []*Problem{}
+	stringParameters_ := stringParameters(s)
+	templates, _ := parseCommandTemplates(name, root, queryLine, s)
+	for _, qt := range templates {
+		ret = append(ret, qt.unescapedProblems(name, stringParameters_)...)
+	}
+	return ret
+}
+
+func (qt *queryTemplate) unescapedProblems(name string, stringParameters map[string]bool) []*Problem {
+	ret := []*Problem{}
+	for _, i := range getUnescapedInterpolations(qt.templates, stringParameters) {
+		ret = append(ret, &Problem{File: name, Line: qt.lineAt(int(i.pos)), Level: LevelWarning,
+			Message: unescapedMessage(qt.name, i.source)})
+	}
+	return ret
+}
+
+// lintSqlCommand checks that the templates of the command only reference existing parameters
+// and escape the values they insert, and that all the flags and arguments of the command are used.
+func lintSqlCommand(name string, root *yaml.Node, queryLine int, s *cmds.SqlCommand) []*Problem {
+	description := s.Description()
+
+	known := map[string]bool{}
+	description.Layers.ForEach(func(_ string, l layers.ParameterLayer) {
+		for _, pd := range l.GetParameterDefinitions().ToList() {
+			known[pd.Name] = true
+		}
+	})
+
+	stringParameters_ := stringParameters(s)
+	used := map[string]bool{}
+	usesDot := false
+	templates, ret := parseCommandTemplates(name, root, queryLine, s)
+	for _, qt := range templates {
+		ret = append(ret, qt.unescapedProblems(name, stringParameters_)...)
+
+		references := getTemplateReferences(qt.templates)
+		usesDot = usesDot || references.usesDot
+		for _, ref := range references.references {
+			used[ref.name] = true
+			if known[ref.name] {
+				continue
+			}
+			// subqueries run with sqlSlice and friends can be passed additional values
+			level := LevelError
+			if strings.HasPrefix(qt.name, "subquery ") {
+				level = LevelWarning
+			}
+			ret = append(ret, &Problem{File: name, Line: qt.lineAt(int(ref.pos)), Level: level,
+				Message: fmt.Sprintf("%s references unknown parameter %s", qt.name, ref.name)})
+		}
+	}
+
+	if usesDot {
+		return ret
+	}
+
+	defaultLayer, ok := description.GetDefaultLayer()
+	if !ok {
+		return ret
+	}
+	for _, pd := range defaultLayer.GetParameterDefinitions().ToList() {
+		if used[pd.Name] {
+			continue
+		}
+		kind, key := "flag", "flags"
+		if pd.IsArgument {
+			kind, key = "argument", "arguments"
+		}
+		ret = append(ret, &Problem{File: name, Line: parameterLine(root, key, pd.Name), Level: LevelWarning,
+			Message: fmt.Sprintf("%s %s is declared but never used in the query", kind, pd.Name)})
+	}
+
+	return ret
+}
+
+// lookup returns the value of the given path of keys in a YAML document, or nil.
+func lookup(node *yaml.Node, keys ...string) *yaml.Node {
+	if node != nil && node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	for _, key := range keys {
+		if node == nil || node.Kind != yaml.MappingNode {
+			return nil
+		}
+		var value *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				value = node.Content[i+1]
+				break
+			}
+		}
+		node = value
+	}
+	return node
+}
+
+// parameterLine returns the line of the definition of the given flag or argument.
+func parameterLine(root *yaml.Node, key string, name string) int {
+	list := lookup(root, key)
+	if list == nil || list.Kind != yaml.SequenceNode {
+		return 0
+	}
+	for _, item := range list.Content {
+		if nameNode := lookup(item, "name"); nameNode != nil && nameNode.Value == name {
+			return item.Line
+		}
+	}
+	return 0
+}
@@ -0,0 +1,125 @@
+package lint
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+func lintString(t *testing.T, content string) []*Problem {
+	f := fstest.MapFS{"cmd.yaml": &fstest.MapFile{Data: []byte(content)}}
+	problems, err := LintFS(f, ".", "")
+	require.NoError(t, err)
+	return problems
+}
+
+func TestLintUnknownKey(t *testing.T) {
+	problems := lintString(t, `name: ls
+short: List
+flags:
+  - name: status
+    type: string
+    descripton: Status
+query: SELECT * FROM posts WHERE status = {{ .status | sqlString }}
+`)
+	require.Len(t, problems, 1)
+	assert.Equal(t, "cmd.yaml:6: error: field descripton not found in type parameters.ParameterDefinition",
+		problems[0].String())
+}
+
+func TestLintTemplateReferences(t *testing.T) {
+	problems := lintString(t, `name: ls
+short: List
+flags:
+  - name: status
+    type: stringList
+  - name: unused
+    type: int
+  - name: limit
+    type: int
+query: |
+  SELECT * FROM posts
+  WHERE author = {{ .author | sqlString }}
+  {{ range .status }} AND status = {{ . | sqlString }} {{ .ignored | sqlString }} {{ $.limit }}{{ end }}
+  {{ if .explain }}{{ end }}
+`)
+	require.Len(t, problems, 2)
+	assert.Equal(t, "cmd.yaml:12: error: query references unknown parameter author", problems[0].String())
+	assert.Equal(t, "cmd.yaml:6: warning: flag unused is declared but never used in the query", problems[1].String())
+	assert.True(t, HasErrors(problems))
+}
+
+func TestLintSubQueries(t *testing.T) {
+	problems := lintString(t, `name: ls
+short: List
+arguments:
+  - name: type
+    type: string
+subqueries:
+  types: SELECT post_type FROM posts WHERE post_type = {{ .type | sqlString }} AND {{ .extra }}
+query: SELECT * FROM posts WHERE post_type IN ({{ sqlColumn (subQuery "types") | sqlStringIn }})
+`)
+	require.Len(t, problems, 1)
+	assert.Equal(t, "cmd.yaml:7: warning: subquery types references unknown parameter extra", problems[0].String())
+	assert.False(t, HasErrors(problems))
+}
+
+func TestLintInvalidSettings(t *testing.T) {
+	problems := lintString(t, `name: ls
+short: List
+timeout: forever
+query: SELECT 1
+`)
+	require.Len(t, problems, 1)
+	assert.Equal(t, LevelError, problems[0].Level)
+	assert.Contains(t, problems[0].Message, "invalid timeout")
+}
+
+func TestLintEmbeddedQueries(t *testing.T) {
+	problems, err := LintFS(os.DirFS("../../cmd/sqleton/queries"), ".", "queries")
+	require.NoError(t, err)
+	for _, p := range problems {
+		assert.NotEqual(t, LevelError, p.Level, p.String())
+	}
+}
+
+func TestLintSqlFile(t *testing.T) {
+	f := fstest.MapFS{"ls.sql": &fstest.MapFile{Data: []byte(`-- ---
+-- name: ls
+-- short: List
+-- flags:
+--   - name: unused
+--     type: int
+-- ---
+SELECT * FROM posts
+WHERE author = {{ .author | sqlString }}
+`)}}
+	problems, err := LintFS(f, ".", "")
+	require.NoError(t, err)
+	require.Len(t, problems, 2)
+	assert.Equal(t, "ls.sql:9: error: query references unknown parameter author", problems[0].String())
+	assert.Equal(t, "ls.sql:5: warning: flag unused is declared but never used in the query", problems[1].String())
+}
+
+func TestLintPartials(t *testing.T) {
+	f := fstest.MapFS{
+		"_partials/status.yaml": &fstest.MapFile{Data: []byte(`flags:
+  - name: status
+    type: string
+template: AND status = {{ .status }} AND {{ .missing | sqlString }}
+`)},
+		"cmd.yaml": &fstest.MapFile{Data: []byte(`name: ls
+short: List
+includes: [status]
+query: SELECT * FROM posts WHERE 1=1 {{ template "status" . }}
+`)},
+	}
+	problems, err := LintFS(f, ".", "")
+	require.NoError(t, err)
+	require.Len(t, problems, 2)
+	assert.Equal(t, "cmd.yaml: warning: partial status inserts parameter status without escaping it, "+
+		"pass it through sqlString, sqlStringIn, sqlLike, ... or declare the parameter as a choice", problems[0].String())
+	assert.Equal(t, "cmd.yaml: error: partial status references unknown parameter missing", problems[1].String())
+}
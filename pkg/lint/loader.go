@@ -0,0 +1,56 @@
+package lint
+
+import (
+	glazed_cmds "github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/alias"
+	"github.com/go-go-golems/glazed/pkg/cmds/loaders"
+	"github.com/go-go-golems/sqleton/pkg/cmds"
+	"github.com/rs/zerolog/log"
+	"io/fs"
+)
+
+// EscapingCheckLoader wraps a command loader, and logs a warning for each unescaped value
+// inserted by the queries of the commands it loads (see CheckEscaping).
+type EscapingCheckLoader struct {
+	loaders.CommandLoader
+}
+
+var _ loaders.CommandLoader = (*EscapingCheckLoader)(nil)
+
+// NewEscapingCheckLoader wraps loader in an EscapingCheckLoader, it can be passed to cmds.NewRepositoryFactory.
+func NewEscapingCheckLoader(loader loaders.CommandLoader) loaders.CommandLoader {
+	return &EscapingCheckLoader{CommandLoader: loader}
+}
+
+func (l *EscapingCheckLoader) LoadCommands(
+	f fs.FS, entryName string,
+	options []glazed_cmds.CommandDescriptionOption,
+	aliasOptions []alias.Option,
+) ([]glazed_cmds.Command, error) {
+	commands, err := l.CommandLoader.LoadCommands(f, entryName, options, aliasOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := fs.ReadFile(f, entryName)
+	if err != nil {
+		return commands, nil
+	}
+
+	for _, command := range commands {
+		var s *cmds.SqlCommand
+		switch c := command.(type) {
+		case *cmds.SqlCommand:
+			s = c
+		case *cmds.ExecCommand:
+			s = c.SqlCommand
+		default:
+			continue
+		}
+		for _, p := range CheckEscaping(entryName, content, s) {
+			log.Warn().Str("file", entryName).Int("line", p.Line).Msg(p.Message)
+		}
+	}
+
+	return commands, nil
+}
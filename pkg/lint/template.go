@@ -0,0 +1,90 @@
+package lint
+
+import (
+	"text/template"
+	"text/template/parse"
+)
+
+// reference is a parameter referenced by a query template, as .name or $.name.
+type reference struct {
+	name string
+	pos  parse.Pos
+}
+
+// templateReferences collects the parameters referenced by a parsed query template.
+// Inside range and with blocks, . is rebound and only $.name refers to a parameter.
+type templateReferences struct {
+	references []reference
+	// usesDot is set if the data map is passed as a whole (to a function or a nested template),
+	// in which case any parameter might be used
+	usesDot bool
+}
+
+func getTemplateReferences(templates []*template.Template) *templateReferences {
+	ret := &templateReferences{}
+	for _, t := range templates {
+		ret.walk(t.Tree.Root, true)
+	}
+	return ret
+}
+
+func (r *templateReferences) walk(node parse.Node, dotIsRoot bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			r.walk(child, dotIsRoot)
+		}
+	case *parse.ActionNode:
+		r.walk(n.Pipe, dotIsRoot)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			r.walk(cmd, dotIsRoot)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			r.walk(arg, dotIsRoot)
+		}
+	case *parse.ChainNode:
+		r.walk(n.Node, dotIsRoot)
+	case *parse.FieldNode:
+		if dotIsRoot {
+			r.references = append(r.references, reference{name: n.Ident[0], pos: n.Pos})
+		}
+	case *parse.VariableNode:
+		if n.Ident[0] == "$" && len(n.Ident) > 1 {
+			r.references = append(r.references, reference{name: n.Ident[1], pos: n.Pos})
+		} else if n.Ident[0] == "$" {
+			r.usesDot = true
+		}
+	case *parse.DotNode:
+		if dotIsRoot {
+			r.usesDot = true
+		}
+	case *parse.IfNode:
+		r.walk(n.Pipe, dotIsRoot)
+		r.walk(n.List, dotIsRoot)
+		r.walk(n.ElseList, dotIsRoot)
+	case *parse.RangeNode:
+		r.walk(n.Pipe, dotIsRoot)
+		r.walk(n.List, false)
+		r.walk(n.ElseList, dotIsRoot)
+	case *parse.WithNode:
+		r.walk(n.Pipe, dotIsRoot)
+		r.walk(n.List, false)
+		r.walk(n.ElseList, dotIsRoot)
+	case *parse.TemplateNode:
+		// {{ template "partial" . }} passes the data map on to a template whose references are collected as well
+		if dotIsRoot && n.Pipe != nil && len(n.Pipe.Cmds) == 1 && len(n.Pipe.Cmds[0].Args) == 1 {
+			if _, ok := n.Pipe.Cmds[0].Args[0].(*parse.DotNode); ok {
+				return
+			}
+		}
+		r.walk(n.Pipe, dotIsRoot)
+	}
+}
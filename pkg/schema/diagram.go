@@ -0,0 +1,307 @@
+package schema
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"html"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	DiagramFormatMermaid  = "mermaid"
+	DiagramFormatDot      = "dot"
+	DiagramFormatPlantUML = "plantuml"
+)
+
+var DiagramFormats = []string{DiagramFormatMermaid, DiagramFormatDot, DiagramFormatPlantUML}
+
+// DiagramTable is a table with its columns, as shown in an ER diagram.
+type DiagramTable struct {
+	Name    string
+	Columns []*Column
+	// ForeignKeyColumns contains the names of the columns that are part of a foreign key
+	ForeignKeyColumns map[string]bool
+}
+
+// Diagram contains the tables and the foreign keys between them.
+// Only foreign keys between tables of the diagram are kept.
+type Diagram struct {
+	Tables      []*DiagramTable
+	ForeignKeys []*ForeignKey
+}
+
+// matchesAny returns true if name matches one of the glob patterns.
+func matchesAny(name string, patterns []string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadDiagram introspects the database and builds the diagram of its tables.
+// tables and exclude are lists of glob patterns (`wp_*`) selecting the tables to show.
+// If tables is empty, all tables are shown.
+func LoadDiagram(
+	ctx context.Context,
+	introspector Introspector,
+	tables []string,
+	exclude []string,
+) (*Diagram, error) {
+	allTables, err := introspector.Tables(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &Diagram{
+		Tables:      []*DiagramTable{},
+		ForeignKeys: []*ForeignKey{},
+	}
+	tablesByName := map[string]*DiagramTable{}
+	for _, t := range allTables {
+		if len(tables) > 0 && !matchesAny(t.Name, tables) {
+			continue
+		}
+		if matchesAny(t.Name, exclude) {
+			continue
+		}
+		dt := &DiagramTable{
+			Name:              t.Name,
+			Columns:           []*Column{},
+			ForeignKeyColumns: map[string]bool{},
+		}
+		ret.Tables = append(ret.Tables, dt)
+		tablesByName[t.Name] = dt
+	}
+
+	columns, err := introspector.Columns(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range columns {
+		if dt, ok := tablesByName[c.Table]; ok {
+			dt.Columns = append(dt.Columns, c)
+		}
+	}
+
+	fks, err := introspector.ForeignKeys(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, fk := range fks {
+		dt, ok := tablesByName[fk.Table]
+		if !ok {
+			continue
+		}
+		if _, ok := tablesByName[fk.ReferencedTable]; !ok {
+			continue
+		}
+		for _, c := range fk.Columns {
+			dt.ForeignKeyColumns[c] = true
+		}
+		ret.ForeignKeys = append(ret.ForeignKeys, fk)
+	}
+
+	sort.SliceStable(ret.ForeignKeys, func(i, j int) bool {
+		if ret.ForeignKeys[i].Table != ret.ForeignKeys[j].Table {
+			return ret.ForeignKeys[i].Table < ret.ForeignKeys[j].Table
+		}
+		return ret.ForeignKeys[i].Name < ret.ForeignKeys[j].Name
+	})
+
+	return ret, nil
+}
+
+func (d *Diagram) getTable(name string) *DiagramTable {
+	for _, t := range d.Tables {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// isNullable returns true if one of the columns of the foreign key is nullable,
+// which means that the relationship is optional.
+func (d *Diagram) isNullable(fk *ForeignKey) bool {
+	t := d.getTable(fk.Table)
+	if t == nil {
+		return false
+	}
+	for _, c := range t.Columns {
+		for _, fc := range fk.Columns {
+			if c.Name == fc && c.Nullable {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Render writes the diagram in the given format (mermaid, dot or plantuml).
+func (d *Diagram) Render(w io.Writer, format string) error {
+	switch format {
+	case DiagramFormatMermaid:
+		return d.RenderMermaid(w)
+	case DiagramFormatDot:
+		return d.RenderDot(w)
+	case DiagramFormatPlantUML:
+		return d.RenderPlantUML(w)
+	default:
+		return errors.Errorf("unknown diagram format %s (expected one of %s)", format, strings.Join(DiagramFormats, ", "))
+	}
+}
+
+var nonIdentifierRegexp = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
+
+// identifier turns a table name into a name that can be used as an identifier by mermaid and plantuml.
+func identifier(name string) string {
+	return nonIdentifierRegexp.ReplaceAllString(name, "_")
+}
+
+var mermaidTypeRegexp = regexp.MustCompile(`[^a-zA-Z0-9_()\[\]-]+`)
+
+func (d *Diagram) RenderMermaid(w io.Writer) error {
+	b := &strings.Builder{}
+	b.WriteString("erDiagram\n")
+	for _, t := range d.Tables {
+		fmt.Fprintf(b, "    %s {\n", identifier(t.Name))
+		for _, c := range t.Columns {
+			keys := []string{}
+			if c.PrimaryKey {
+				keys = append(keys, "PK")
+			}
+			if t.ForeignKeyColumns[c.Name] {
+				keys = append(keys, "FK")
+			}
+			type_ := mermaidTypeRegexp.ReplaceAllString(c.Type, "_")
+			if type_ == "" {
+				type_ = "ANY"
+			}
+			fmt.Fprintf(b, "        %s %s", type_, identifier(c.Name))
+			if len(keys) > 0 {
+				fmt.Fprintf(b, " %s", strings.Join(keys, ", "))
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("    }\n")
+	}
+
+	for _, fk := range d.ForeignKeys {
+		cardinality := "||--o{"
+		if d.isNullable(fk) {
+			cardinality = "|o--o{"
+		}
+		fmt.Fprintf(b, "    %s %s %s : %q\n",
+			identifier(fk.ReferencedTable), cardinality, identifier(fk.Table), strings.Join(fk.Columns, ", "))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (d *Diagram) RenderDot(w io.Writer) error {
+	b := &strings.Builder{}
+	b.WriteString("digraph schema {\n")
+	b.WriteString("    rankdir=LR;\n")
+	b.WriteString("    node [shape=plaintext, fontname=\"Helvetica\"];\n")
+	b.WriteString("    edge [fontname=\"Helvetica\", fontsize=10];\n")
+	for _, t := range d.Tables {
+		fmt.Fprintf(b, "    %q [label=<\n", t.Name)
+		b.WriteString("        <TABLE BORDER=\"0\" CELLBORDER=\"1\" CELLSPACING=\"0\">\n")
+		fmt.Fprintf(b, "            <TR><TD BGCOLOR=\"lightgrey\"><B>%s</B></TD></TR>\n", html.EscapeString(t.Name))
+		for _, c := range t.Columns {
+			name := html.EscapeString(c.Name)
+			if c.PrimaryKey {
+				name = "<U>" + name + "</U>"
+			}
+			if t.ForeignKeyColumns[c.Name] {
+				name = "<I>" + name + "</I>"
+			}
+			fmt.Fprintf(b, "            <TR><TD PORT=\"%s\" ALIGN=\"LEFT\">%s : %s</TD></TR>\n",
+				html.EscapeString(c.Name), name, html.EscapeString(c.Type))
+		}
+		b.WriteString("        </TABLE>\n")
+		b.WriteString("    >];\n")
+	}
+
+	for _, fk := range d.ForeignKeys {
+		from := fk.Table
+		to := fk.ReferencedTable
+		fromPort, toPort := "", ""
+		if len(fk.Columns) == 1 && len(fk.ReferencedColumns) == 1 {
+			fromPort = fmt.Sprintf(":%q", fk.Columns[0])
+			toPort = fmt.Sprintf(":%q", fk.ReferencedColumns[0])
+		}
+		fmt.Fprintf(b, "    %q%s -> %q%s [label=%q];\n", from, fromPort, to, toPort, fk.Name)
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (d *Diagram) RenderPlantUML(w io.Writer) error {
+	b := &strings.Builder{}
+	b.WriteString("@startuml\n")
+	b.WriteString("hide circle\n")
+	b.WriteString("skinparam linetype ortho\n")
+	for _, t := range d.Tables {
+		fmt.Fprintf(b, "\nentity %q as %s {\n", t.Name, identifier(t.Name))
+
+		primaryKeys := []*Column{}
+		others := []*Column{}
+		for _, c := range t.Columns {
+			if c.PrimaryKey {
+				primaryKeys = append(primaryKeys, c)
+			} else {
+				others = append(others, c)
+			}
+		}
+
+		writeColumn := func(c *Column) {
+			mandatory := ""
+			if !c.Nullable {
+				mandatory = "* "
+			}
+			stereotypes := ""
+			if c.PrimaryKey {
+				stereotypes += " <<PK>>"
+			}
+			if t.ForeignKeyColumns[c.Name] {
+				stereotypes += " <<FK>>"
+			}
+			fmt.Fprintf(b, "  %s%s : %s%s\n", mandatory, c.Name, c.Type, stereotypes)
+		}
+		for _, c := range primaryKeys {
+			writeColumn(c)
+		}
+		b.WriteString("  --\n")
+		for _, c := range others {
+			writeColumn(c)
+		}
+		b.WriteString("}\n")
+	}
+
+	if len(d.ForeignKeys) > 0 {
+		b.WriteString("\n")
+	}
+	for _, fk := range d.ForeignKeys {
+		cardinality := "||--o{"
+		if d.isNullable(fk) {
+			cardinality = "|o--o{"
+		}
+		fmt.Fprintf(b, "%s %s %s : %s\n",
+			identifier(fk.ReferencedTable), cardinality, identifier(fk.Table), strings.Join(fk.Columns, ", "))
+	}
+	b.WriteString("@enduml\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,93 @@
+package schema
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func loadTestDiagram(t *testing.T, tables []string, exclude []string) *Diagram {
+	db := createSchemaDB(t)
+	introspector, err := NewIntrospector(db, "")
+	require.NoError(t, err)
+
+	diagram, err := LoadDiagram(context.Background(), introspector, tables, exclude)
+	require.NoError(t, err)
+	return diagram
+}
+
+func TestMermaidDiagram(t *testing.T) {
+	diagram := loadTestDiagram(t, nil, nil)
+
+	b := &strings.Builder{}
+	err := diagram.Render(b, DiagramFormatMermaid)
+	require.NoError(t, err)
+
+	expected := `erDiagram
+    posts {
+        INTEGER id PK
+        INTEGER user_id FK
+        TEXT title
+    }
+    tags {
+        INTEGER post_id PK, FK
+        TEXT tag PK
+    }
+    users {
+        INTEGER id PK
+        TEXT email
+        TEXT name
+    }
+    users ||--o{ posts : "user_id"
+    posts ||--o{ tags : "post_id"
+`
+	assert.Equal(t, expected, b.String())
+}
+
+func TestDiagramFilters(t *testing.T) {
+	diagram := loadTestDiagram(t, []string{"p*", "users"}, []string{"tags"})
+	names := []string{}
+	for _, table := range diagram.Tables {
+		names = append(names, table.Name)
+	}
+	assert.Equal(t, []string{"posts", "users"}, names)
+
+	// foreign keys to excluded tables are dropped
+	require.Len(t, diagram.ForeignKeys, 1)
+	assert.Equal(t, "posts", diagram.ForeignKeys[0].Table)
+}
+
+func TestDotDiagram(t *testing.T) {
+	diagram := loadTestDiagram(t, nil, []string{"tags"})
+
+	b := &strings.Builder{}
+	err := diagram.Render(b, DiagramFormatDot)
+	require.NoError(t, err)
+
+	s := b.String()
+	assert.True(t, strings.HasPrefix(s, "digraph schema {\n"))
+	assert.Contains(t, s, `<TR><TD PORT="id" ALIGN="LEFT"><U>id</U> : INTEGER</TD></TR>`)
+	assert.Contains(t, s, `"posts":"user_id" -> "users":"id" [label="fk_posts_0"];`)
+}
+
+func TestPlantUMLDiagram(t *testing.T) {
+	diagram := loadTestDiagram(t, []string{"users", "posts"}, nil)
+
+	b := &strings.Builder{}
+	err := diagram.Render(b, DiagramFormatPlantUML)
+	require.NoError(t, err)
+
+	s := b.String()
+	assert.True(t, strings.HasPrefix(s, "@startuml\n"))
+	assert.Contains(t, s, "entity \"users\" as users {\n  * id : INTEGER <<PK>>\n  --\n  * email : TEXT\n  name : TEXT\n}\n")
+	assert.Contains(t, s, "users ||--o{ posts : user_id\n")
+	assert.True(t, strings.HasSuffix(s, "@enduml\n"))
+}
+
+func TestUnknownDiagramFormat(t *testing.T) {
+	diagram := &Diagram{}
+	err := diagram.Render(&strings.Builder{}, "svg")
+	assert.Error(t, err)
+}
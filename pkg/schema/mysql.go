@@ -0,0 +1,194 @@
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// mysqlIntrospector reads the schema from information_schema.
+// If no schema is given, the current database is used.
+type mysqlIntrospector struct {
+	db     *sqlx.DB
+	schema string
+}
+
+const mysqlSchemaCondition = `IFNULL(NULLIF(?, ''), DATABASE())`
+
+func (m *mysqlIntrospector) Tables(ctx context.Context) ([]*Table, error) {
+	tables := []struct {
+		Schema  string `db:"TABLE_SCHEMA"`
+		Name    string `db:"TABLE_NAME"`
+		Comment string `db:"TABLE_COMMENT"`
+	}{}
+	err := m.db.SelectContext(ctx, &tables, `
+		SELECT TABLE_SCHEMA, TABLE_NAME, IFNULL(TABLE_COMMENT, '') AS TABLE_COMMENT
+		FROM information_schema.TABLES
+		WHERE TABLE_SCHEMA = `+mysqlSchemaCondition+` AND TABLE_TYPE = 'BASE TABLE'
+		ORDER BY TABLE_NAME`, m.schema)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list tables")
+	}
+
+	ret := []*Table{}
+	for _, t := range tables {
+		ret = append(ret, &Table{Schema: t.Schema, Name: t.Name, Comment: t.Comment})
+	}
+	return ret, nil
+}
+
+func (m *mysqlIntrospector) Columns(ctx context.Context, table string) ([]*Column, error) {
+	columns := []struct {
+		Table    string         `db:"TABLE_NAME"`
+		Position int            `db:"ORDINAL_POSITION"`
+		Name     string         `db:"COLUMN_NAME"`
+		Type     string         `db:"COLUMN_TYPE"`
+		Nullable string         `db:"IS_NULLABLE"`
+		Default  sql.NullString `db:"COLUMN_DEFAULT"`
+		Key      string         `db:"COLUMN_KEY"`
+	}{}
+	err := m.db.SelectContext(ctx, &columns, `
+		SELECT c.TABLE_NAME, c.ORDINAL_POSITION, c.COLUMN_NAME, c.COLUMN_TYPE,
+			c.IS_NULLABLE, c.COLUMN_DEFAULT, c.COLUMN_KEY
+		FROM information_schema.COLUMNS c
+		JOIN information_schema.TABLES t
+			ON t.TABLE_SCHEMA = c.TABLE_SCHEMA AND t.TABLE_NAME = c.TABLE_NAME
+		WHERE c.TABLE_SCHEMA = `+mysqlSchemaCondition+`
+			AND t.TABLE_TYPE = 'BASE TABLE'
+			AND (? = '' OR c.TABLE_NAME = ?)
+		ORDER BY c.TABLE_NAME, c.ORDINAL_POSITION`, m.schema, table, table)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list columns")
+	}
+	if len(columns) == 0 && table != "" {
+		return nil, errors.Errorf("table %s not found", table)
+	}
+
+	ret := []*Column{}
+	for _, c := range columns {
+		column := &Column{
+			Table:      c.Table,
+			Position:   c.Position,
+			Name:       c.Name,
+			Type:       c.Type,
+			Nullable:   c.Nullable == "YES",
+			PrimaryKey: c.Key == "PRI",
+		}
+		if c.Default.Valid {
+			column.Default = &c.Default.String
+		}
+		ret = append(ret, column)
+	}
+	return ret, nil
+}
+
+func (m *mysqlIntrospector) Indexes(ctx context.Context, table string) ([]*Index, error) {
+	rows := []struct {
+		Table     string         `db:"TABLE_NAME"`
+		Name      string         `db:"INDEX_NAME"`
+		NonUnique int            `db:"NON_UNIQUE"`
+		Column    sql.NullString `db:"COLUMN_NAME"`
+	}{}
+	err := m.db.SelectContext(ctx, &rows, `
+		SELECT TABLE_NAME, INDEX_NAME, NON_UNIQUE, COLUMN_NAME
+		FROM information_schema.STATISTICS
+		WHERE TABLE_SCHEMA = `+mysqlSchemaCondition+`
+			AND (? = '' OR TABLE_NAME = ?)
+		ORDER BY TABLE_NAME, INDEX_NAME, SEQ_IN_INDEX`, m.schema, table, table)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list indexes")
+	}
+
+	ret := []*Index{}
+	var index *Index
+	for _, r := range rows {
+		if index == nil || index.Table != r.Table || index.Name != r.Name {
+			index = &Index{
+				Table:   r.Table,
+				Name:    r.Name,
+				Columns: []string{},
+				Unique:  r.NonUnique == 0,
+				Primary: r.Name == "PRIMARY",
+			}
+			ret = append(ret, index)
+		}
+		// functional indexes have no column name
+		if r.Column.Valid {
+			index.Columns = append(index.Columns, r.Column.String)
+		} else {
+			index.Columns = append(index.Columns, "<expression>")
+		}
+	}
+	return ret, nil
+}
+
+func (m *mysqlIntrospector) ForeignKeys(ctx context.Context, table string) ([]*ForeignKey, error) {
+	rows := []struct {
+		Table            string `db:"TABLE_NAME"`
+		Name             string `db:"CONSTRAINT_NAME"`
+		Column           string `db:"COLUMN_NAME"`
+		ReferencedTable  string `db:"REFERENCED_TABLE_NAME"`
+		ReferencedColumn string `db:"REFERENCED_COLUMN_NAME"`
+		OnUpdate         string `db:"UPDATE_RULE"`
+		OnDelete         string `db:"DELETE_RULE"`
+	}{}
+	err := m.db.SelectContext(ctx, &rows, `
+		SELECT k.TABLE_NAME, k.CONSTRAINT_NAME, k.COLUMN_NAME,
+			k.REFERENCED_TABLE_NAME, k.REFERENCED_COLUMN_NAME,
+			r.UPDATE_RULE, r.DELETE_RULE
+		FROM information_schema.KEY_COLUMN_USAGE k
+		JOIN information_schema.REFERENTIAL_CONSTRAINTS r
+			ON r.CONSTRAINT_SCHEMA = k.CONSTRAINT_SCHEMA
+			AND r.TABLE_NAME = k.TABLE_NAME
+			AND r.CONSTRAINT_NAME = k.CONSTRAINT_NAME
+		WHERE k.TABLE_SCHEMA = `+mysqlSchemaCondition+`
+			AND k.REFERENCED_TABLE_NAME IS NOT NULL
+			AND (? = '' OR k.TABLE_NAME = ?)
+		ORDER BY k.TABLE_NAME, k.CONSTRAINT_NAME, k.ORDINAL_POSITION`, m.schema, table, table)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list foreign keys")
+	}
+
+	ret := []*ForeignKey{}
+	var fk *ForeignKey
+	for _, r := range rows {
+		if fk == nil || fk.Table != r.Table || fk.Name != r.Name {
+			fk = &ForeignKey{
+				Table:             r.Table,
+				Name:              r.Name,
+				Columns:           []string{},
+				ReferencedTable:   r.ReferencedTable,
+				ReferencedColumns: []string{},
+				OnUpdate:          r.OnUpdate,
+				OnDelete:          r.OnDelete,
+			}
+			ret = append(ret, fk)
+		}
+		fk.Columns = append(fk.Columns, r.Column)
+		fk.ReferencedColumns = append(fk.ReferencedColumns, r.ReferencedColumn)
+	}
+	return ret, nil
+}
+
+func (m *mysqlIntrospector) Views(ctx context.Context) ([]*View, error) {
+	views := []struct {
+		Schema     string `db:"TABLE_SCHEMA"`
+		Name       string `db:"TABLE_NAME"`
+		Definition string `db:"VIEW_DEFINITION"`
+	}{}
+	err := m.db.SelectContext(ctx, &views, `
+		SELECT TABLE_SCHEMA, TABLE_NAME, IFNULL(VIEW_DEFINITION, '') AS VIEW_DEFINITION
+		FROM information_schema.VIEWS
+		WHERE TABLE_SCHEMA = `+mysqlSchemaCondition+`
+		ORDER BY TABLE_NAME`, m.schema)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list views")
+	}
+
+	ret := []*View{}
+	for _, v := range views {
+		ret = append(ret, &View{Schema: v.Schema, Name: v.Name, Definition: v.Definition})
+	}
+	return ret, nil
+}
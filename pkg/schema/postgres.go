@@ -0,0 +1,219 @@
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// postgresIntrospector reads the schema from pg_catalog.
+// If no schema is given, the current schema (the first one of the search_path) is used.
+type postgresIntrospector struct {
+	db     *sqlx.DB
+	schema string
+}
+
+const postgresSchemaCondition = `COALESCE(NULLIF($1::text, ''), current_schema()::text)`
+
+var postgresForeignKeyActions = map[string]string{
+	"a": "NO ACTION",
+	"r": "RESTRICT",
+	"c": "CASCADE",
+	"n": "SET NULL",
+	"d": "SET DEFAULT",
+}
+
+func (p *postgresIntrospector) Tables(ctx context.Context) ([]*Table, error) {
+	tables := []struct {
+		Schema  string `db:"schema"`
+		Name    string `db:"name"`
+		Comment string `db:"comment"`
+	}{}
+	err := p.db.SelectContext(ctx, &tables, `
+		SELECT n.nspname AS schema, c.relname AS name,
+			COALESCE(obj_description(c.oid, 'pg_class'), '') AS comment
+		FROM pg_catalog.pg_class c
+		JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind IN ('r', 'p')
+			AND NOT c.relispartition
+			AND n.nspname::text = `+postgresSchemaCondition+`
+		ORDER BY c.relname`, p.schema)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list tables")
+	}
+
+	ret := []*Table{}
+	for _, t := range tables {
+		ret = append(ret, &Table{Schema: t.Schema, Name: t.Name, Comment: t.Comment})
+	}
+	return ret, nil
+}
+
+func (p *postgresIntrospector) Columns(ctx context.Context, table string) ([]*Column, error) {
+	columns := []struct {
+		Table      string         `db:"table_name"`
+		Position   int            `db:"position"`
+		Name       string         `db:"name"`
+		Type       string         `db:"type"`
+		Nullable   bool           `db:"nullable"`
+		Default    sql.NullString `db:"default_value"`
+		PrimaryKey bool           `db:"primary_key"`
+	}{}
+	err := p.db.SelectContext(ctx, &columns, `
+		SELECT c.relname AS table_name, a.attnum AS position, a.attname AS name,
+			pg_catalog.format_type(a.atttypid, a.atttypmod) AS type,
+			NOT a.attnotnull AS nullable,
+			pg_catalog.pg_get_expr(d.adbin, d.adrelid) AS default_value,
+			EXISTS (
+				SELECT 1 FROM pg_catalog.pg_index i
+				WHERE i.indrelid = c.oid AND i.indisprimary AND a.attnum = ANY(i.indkey)
+			) AS primary_key
+		FROM pg_catalog.pg_attribute a
+		JOIN pg_catalog.pg_class c ON c.oid = a.attrelid
+		JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+		LEFT JOIN pg_catalog.pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+		WHERE c.relkind IN ('r', 'p')
+			AND NOT c.relispartition
+			AND a.attnum > 0 AND NOT a.attisdropped
+			AND n.nspname::text = `+postgresSchemaCondition+`
+			AND ($2::text = '' OR c.relname::text = $2::text)
+		ORDER BY c.relname, a.attnum`, p.schema, table)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list columns")
+	}
+	if len(columns) == 0 && table != "" {
+		return nil, errors.Errorf("table %s not found", table)
+	}
+
+	ret := []*Column{}
+	for _, c := range columns {
+		column := &Column{
+			Table:      c.Table,
+			Position:   c.Position,
+			Name:       c.Name,
+			Type:       c.Type,
+			Nullable:   c.Nullable,
+			PrimaryKey: c.PrimaryKey,
+		}
+		if c.Default.Valid {
+			column.Default = &c.Default.String
+		}
+		ret = append(ret, column)
+	}
+	return ret, nil
+}
+
+func (p *postgresIntrospector) Indexes(ctx context.Context, table string) ([]*Index, error) {
+	indexes := []struct {
+		Table   string `db:"table_name"`
+		Name    string `db:"name"`
+		Columns string `db:"columns"`
+		Unique  bool   `db:"is_unique"`
+		Primary bool   `db:"is_primary"`
+	}{}
+	err := p.db.SelectContext(ctx, &indexes, `
+		SELECT c.relname AS table_name, ic.relname AS name,
+			array_to_string(ARRAY(
+				SELECT pg_catalog.pg_get_indexdef(i.indexrelid, k + 1, true)
+				FROM generate_subscripts(i.indkey, 1) AS k
+				ORDER BY k
+			), ',') AS columns,
+			i.indisunique AS is_unique, i.indisprimary AS is_primary
+		FROM pg_catalog.pg_index i
+		JOIN pg_catalog.pg_class c ON c.oid = i.indrelid
+		JOIN pg_catalog.pg_class ic ON ic.oid = i.indexrelid
+		JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname::text = `+postgresSchemaCondition+`
+			AND c.relkind IN ('r', 'p')
+			AND ($2::text = '' OR c.relname::text = $2::text)
+		ORDER BY c.relname, ic.relname`, p.schema, table)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list indexes")
+	}
+
+	ret := []*Index{}
+	for _, i := range indexes {
+		ret = append(ret, &Index{
+			Table:   i.Table,
+			Name:    i.Name,
+			Columns: splitList(i.Columns),
+			Unique:  i.Unique,
+			Primary: i.Primary,
+		})
+	}
+	return ret, nil
+}
+
+func (p *postgresIntrospector) ForeignKeys(ctx context.Context, table string) ([]*ForeignKey, error) {
+	fks := []struct {
+		Table             string `db:"table_name"`
+		Name              string `db:"name"`
+		Columns           string `db:"columns"`
+		ReferencedTable   string `db:"referenced_table"`
+		ReferencedColumns string `db:"referenced_columns"`
+		OnUpdate          string `db:"on_update"`
+		OnDelete          string `db:"on_delete"`
+	}{}
+	err := p.db.SelectContext(ctx, &fks, `
+		SELECT cl.relname AS table_name, con.conname AS name,
+			array_to_string(ARRAY(
+				SELECT a.attname FROM unnest(con.conkey) WITH ORDINALITY AS k(attnum, ord)
+				JOIN pg_catalog.pg_attribute a ON a.attrelid = con.conrelid AND a.attnum = k.attnum
+				ORDER BY k.ord
+			), ',') AS columns,
+			rcl.relname AS referenced_table,
+			array_to_string(ARRAY(
+				SELECT a.attname FROM unnest(con.confkey) WITH ORDINALITY AS k(attnum, ord)
+				JOIN pg_catalog.pg_attribute a ON a.attrelid = con.confrelid AND a.attnum = k.attnum
+				ORDER BY k.ord
+			), ',') AS referenced_columns,
+			con.confupdtype::text AS on_update, con.confdeltype::text AS on_delete
+		FROM pg_catalog.pg_constraint con
+		JOIN pg_catalog.pg_class cl ON cl.oid = con.conrelid
+		JOIN pg_catalog.pg_class rcl ON rcl.oid = con.confrelid
+		JOIN pg_catalog.pg_namespace n ON n.oid = cl.relnamespace
+		WHERE con.contype = 'f'
+			AND n.nspname::text = `+postgresSchemaCondition+`
+			AND ($2::text = '' OR cl.relname::text = $2::text)
+		ORDER BY cl.relname, con.conname`, p.schema, table)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list foreign keys")
+	}
+
+	ret := []*ForeignKey{}
+	for _, fk := range fks {
+		ret = append(ret, &ForeignKey{
+			Table:             fk.Table,
+			Name:              fk.Name,
+			Columns:           splitList(fk.Columns),
+			ReferencedTable:   fk.ReferencedTable,
+			ReferencedColumns: splitList(fk.ReferencedColumns),
+			OnUpdate:          postgresForeignKeyActions[fk.OnUpdate],
+			OnDelete:          postgresForeignKeyActions[fk.OnDelete],
+		})
+	}
+	return ret, nil
+}
+
+func (p *postgresIntrospector) Views(ctx context.Context) ([]*View, error) {
+	views := []struct {
+		Schema     string         `db:"schemaname"`
+		Name       string         `db:"viewname"`
+		Definition sql.NullString `db:"definition"`
+	}{}
+	err := p.db.SelectContext(ctx, &views, `
+		SELECT schemaname, viewname, definition
+		FROM pg_catalog.pg_views
+		WHERE schemaname::text = `+postgresSchemaCondition+`
+		ORDER BY viewname`, p.schema)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list views")
+	}
+
+	ret := []*View{}
+	for _, v := range views {
+		ret = append(ret, &View{Schema: v.Schema, Name: v.Name, Definition: v.Definition.String})
+	}
+	return ret, nil
+}
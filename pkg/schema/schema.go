@@ -0,0 +1,141 @@
+package schema
+
+import (
+	"context"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/go-go-golems/sqleton/pkg/connection"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// Table is a base table of the database. Views are returned separately, see View.
+type Table struct {
+	Schema  string
+	Name    string
+	Comment string
+}
+
+type Column struct {
+	Table      string
+	Position   int
+	Name       string
+	Type       string
+	Nullable   bool
+	Default    *string
+	PrimaryKey bool
+}
+
+type Index struct {
+	Table   string
+	Name    string
+	Columns []string
+	Unique  bool
+	Primary bool
+}
+
+type ForeignKey struct {
+	Table             string
+	Name              string
+	Columns           []string
+	ReferencedTable   string
+	ReferencedColumns []string
+	OnUpdate          string
+	OnDelete          string
+}
+
+type View struct {
+	Schema     string
+	Name       string
+	Definition string
+}
+
+// Introspector reads the schema of a database.
+//
+// Columns, Indexes and ForeignKeys return the objects of all the tables
+// if table is the empty string.
+type Introspector interface {
+	Tables(ctx context.Context) ([]*Table, error)
+	Columns(ctx context.Context, table string) ([]*Column, error)
+	Indexes(ctx context.Context, table string) ([]*Index, error)
+	ForeignKeys(ctx context.Context, table string) ([]*ForeignKey, error)
+	Views(ctx context.Context) ([]*View, error)
+}
+
+// NewIntrospector returns the Introspector matching the driver of db.
+// schema selects the schema (or database, for MySQL) to introspect.
+// If empty, the current schema of the connection is used.
+func NewIntrospector(db *sqlx.DB, schema string) (Introspector, error) {
+	switch connection.NormalizeType(db.DriverName()) {
+	case connection.TypeSqlite:
+		return &sqliteIntrospector{db: db}, nil
+	case connection.TypeMySQL:
+		return &mysqlIntrospector{db: db, schema: schema}, nil
+	case connection.TypePostgres:
+		return &postgresIntrospector{db: db, schema: schema}, nil
+	default:
+		return nil, errors.Errorf("schema introspection is not supported for driver %s", db.DriverName())
+	}
+}
+
+func (t *Table) ToRow() types.Row {
+	return types.NewRow(
+		types.MRP("schema", t.Schema),
+		types.MRP("name", t.Name),
+		types.MRP("comment", t.Comment),
+	)
+}
+
+func (c *Column) ToRow() types.Row {
+	var default_ interface{}
+	if c.Default != nil {
+		default_ = *c.Default
+	}
+	return types.NewRow(
+		types.MRP("table", c.Table),
+		types.MRP("position", c.Position),
+		types.MRP("name", c.Name),
+		types.MRP("type", c.Type),
+		types.MRP("nullable", c.Nullable),
+		types.MRP("default", default_),
+		types.MRP("primary_key", c.PrimaryKey),
+	)
+}
+
+func (i *Index) ToRow() types.Row {
+	return types.NewRow(
+		types.MRP("table", i.Table),
+		types.MRP("name", i.Name),
+		types.MRP("columns", strings.Join(i.Columns, ",")),
+		types.MRP("unique", i.Unique),
+		types.MRP("primary", i.Primary),
+	)
+}
+
+func (f *ForeignKey) ToRow() types.Row {
+	return types.NewRow(
+		types.MRP("table", f.Table),
+		types.MRP("name", f.Name),
+		types.MRP("columns", strings.Join(f.Columns, ",")),
+		types.MRP("referenced_table", f.ReferencedTable),
+		types.MRP("referenced_columns", strings.Join(f.ReferencedColumns, ",")),
+		types.MRP("on_update", f.OnUpdate),
+		types.MRP("on_delete", f.OnDelete),
+	)
+}
+
+func (v *View) ToRow() types.Row {
+	return types.NewRow(
+		types.MRP("schema", v.Schema),
+		types.MRP("name", v.Name),
+		types.MRP("definition", v.Definition),
+	)
+}
+
+// splitList splits the comma separated column lists returned by the catalog queries.
+func splitList(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, ",")
+}
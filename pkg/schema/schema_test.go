@@ -0,0 +1,138 @@
+package schema
+
+import (
+	"context"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+
+	// sqlite
+	_ "modernc.org/sqlite"
+)
+
+func createSchemaDB(t *testing.T) *sqlx.DB {
+	db, err := sqlx.Connect("sqlite", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+
+	statements := []string{
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT NOT NULL UNIQUE, name TEXT DEFAULT 'anon')",
+		`CREATE TABLE posts (
+			id INTEGER PRIMARY KEY,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			title TEXT
+		)`,
+		"CREATE INDEX idx_posts_user ON posts (user_id, title)",
+		"CREATE TABLE tags (post_id INTEGER, tag TEXT, PRIMARY KEY (post_id, tag), FOREIGN KEY (post_id) REFERENCES posts)",
+		"CREATE VIEW user_posts AS SELECT u.email, p.title FROM users u JOIN posts p ON p.user_id = u.id",
+	}
+	for _, s := range statements {
+		_, err = db.Exec(s)
+		require.NoError(t, err)
+	}
+
+	return db
+}
+
+func TestSqliteTablesAndViews(t *testing.T) {
+	db := createSchemaDB(t)
+	introspector, err := NewIntrospector(db, "")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	tables, err := introspector.Tables(ctx)
+	require.NoError(t, err)
+	names := []string{}
+	for _, table := range tables {
+		names = append(names, table.Name)
+	}
+	assert.Equal(t, []string{"posts", "tags", "users"}, names)
+
+	views, err := introspector.Views(ctx)
+	require.NoError(t, err)
+	require.Len(t, views, 1)
+	assert.Equal(t, "user_posts", views[0].Name)
+	assert.Contains(t, views[0].Definition, "JOIN posts")
+}
+
+func TestSqliteColumns(t *testing.T) {
+	db := createSchemaDB(t)
+	introspector, err := NewIntrospector(db, "")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	columns, err := introspector.Columns(ctx, "users")
+	require.NoError(t, err)
+	require.Len(t, columns, 3)
+
+	assert.Equal(t, "id", columns[0].Name)
+	assert.True(t, columns[0].PrimaryKey)
+	assert.False(t, columns[0].Nullable)
+
+	assert.Equal(t, "email", columns[1].Name)
+	assert.Equal(t, 2, columns[1].Position)
+	assert.False(t, columns[1].Nullable)
+	assert.Nil(t, columns[1].Default)
+
+	assert.Equal(t, "name", columns[2].Name)
+	assert.True(t, columns[2].Nullable)
+	require.NotNil(t, columns[2].Default)
+	assert.Equal(t, "'anon'", *columns[2].Default)
+
+	allColumns, err := introspector.Columns(ctx, "")
+	require.NoError(t, err)
+	assert.Len(t, allColumns, 8)
+
+	_, err = introspector.Columns(ctx, "missing")
+	assert.Error(t, err)
+}
+
+func TestSqliteIndexes(t *testing.T) {
+	db := createSchemaDB(t)
+	introspector, err := NewIntrospector(db, "")
+	require.NoError(t, err)
+
+	indexes, err := introspector.Indexes(context.Background(), "posts")
+	require.NoError(t, err)
+	require.Len(t, indexes, 1)
+	assert.Equal(t, "idx_posts_user", indexes[0].Name)
+	assert.Equal(t, []string{"user_id", "title"}, indexes[0].Columns)
+	assert.False(t, indexes[0].Unique)
+
+	indexes, err = introspector.Indexes(context.Background(), "tags")
+	require.NoError(t, err)
+	require.Len(t, indexes, 1)
+	assert.True(t, indexes[0].Primary)
+	assert.Equal(t, []string{"post_id", "tag"}, indexes[0].Columns)
+}
+
+func TestSqliteForeignKeys(t *testing.T) {
+	db := createSchemaDB(t)
+	introspector, err := NewIntrospector(db, "")
+	require.NoError(t, err)
+
+	fks, err := introspector.ForeignKeys(context.Background(), "")
+	require.NoError(t, err)
+	require.Len(t, fks, 2)
+
+	assert.Equal(t, "posts", fks[0].Table)
+	assert.Equal(t, []string{"user_id"}, fks[0].Columns)
+	assert.Equal(t, "users", fks[0].ReferencedTable)
+	assert.Equal(t, []string{"id"}, fks[0].ReferencedColumns)
+	assert.Equal(t, "CASCADE", fks[0].OnDelete)
+
+	// the referenced columns default to the primary key of the referenced table
+	assert.Equal(t, "tags", fks[1].Table)
+	assert.Equal(t, "posts", fks[1].ReferencedTable)
+	assert.Equal(t, []string{"id"}, fks[1].ReferencedColumns)
+}
+
+func TestRowShapes(t *testing.T) {
+	row := (&Column{Table: "users", Position: 1, Name: "id", Type: "INTEGER", PrimaryKey: true}).ToRow()
+	keys := []string{}
+	for pair := row.Oldest(); pair != nil; pair = pair.Next() {
+		keys = append(keys, pair.Key)
+	}
+	assert.Equal(t, []string{"table", "position", "name", "type", "nullable", "default", "primary_key"}, keys)
+}
@@ -0,0 +1,223 @@
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// sqliteIntrospector reads the schema from sqlite_master and the table-valued pragma functions.
+type sqliteIntrospector struct {
+	db *sqlx.DB
+}
+
+func (s *sqliteIntrospector) tableNames(ctx context.Context, table string) ([]string, error) {
+	if table != "" {
+		return []string{table}, nil
+	}
+
+	tables, err := s.Tables(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ret := []string{}
+	for _, t := range tables {
+		ret = append(ret, t.Name)
+	}
+	return ret, nil
+}
+
+func (s *sqliteIntrospector) Tables(ctx context.Context) ([]*Table, error) {
+	names := []string{}
+	err := s.db.SelectContext(ctx, &names, `
+		SELECT name FROM sqlite_master
+		WHERE type = 'table' AND name NOT LIKE 'sqlite_%'
+		ORDER BY name`)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list tables")
+	}
+
+	ret := []*Table{}
+	for _, name := range names {
+		ret = append(ret, &Table{Schema: "main", Name: name})
+	}
+	return ret, nil
+}
+
+func (s *sqliteIntrospector) Columns(ctx context.Context, table string) ([]*Column, error) {
+	tables, err := s.tableNames(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []*Column{}
+	for _, t := range tables {
+		columns := []struct {
+			Cid       int            `db:"cid"`
+			Name      string         `db:"name"`
+			Type      string         `db:"type"`
+			NotNull   bool           `db:"notnull"`
+			DfltValue sql.NullString `db:"dflt_value"`
+			Pk        int            `db:"pk"`
+		}{}
+		err = s.db.SelectContext(ctx, &columns,
+			`SELECT cid, name, type, "notnull", dflt_value, pk FROM pragma_table_info(?) ORDER BY cid`, t)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not list columns of table %s", t)
+		}
+		if len(columns) == 0 && table != "" {
+			return nil, errors.Errorf("table %s not found", table)
+		}
+
+		for _, c := range columns {
+			column := &Column{
+				Table:      t,
+				Position:   c.Cid + 1,
+				Name:       c.Name,
+				Type:       c.Type,
+				Nullable:   !c.NotNull && c.Pk == 0,
+				PrimaryKey: c.Pk > 0,
+			}
+			if c.DfltValue.Valid {
+				column.Default = &c.DfltValue.String
+			}
+			ret = append(ret, column)
+		}
+	}
+
+	return ret, nil
+}
+
+func (s *sqliteIntrospector) Indexes(ctx context.Context, table string) ([]*Index, error) {
+	tables, err := s.tableNames(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []*Index{}
+	for _, t := range tables {
+		indexes := []struct {
+			Name   string `db:"name"`
+			Unique bool   `db:"unique"`
+			Origin string `db:"origin"`
+		}{}
+		err = s.db.SelectContext(ctx, &indexes,
+			`SELECT name, "unique", origin FROM pragma_index_list(?) ORDER BY name`, t)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not list indexes of table %s", t)
+		}
+
+		for _, i := range indexes {
+			columns := []sql.NullString{}
+			err = s.db.SelectContext(ctx, &columns,
+				`SELECT name FROM pragma_index_info(?) ORDER BY seqno`, i.Name)
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not list columns of index %s", i.Name)
+			}
+
+			index := &Index{
+				Table:   t,
+				Name:    i.Name,
+				Columns: []string{},
+				Unique:  i.Unique,
+				Primary: i.Origin == "pk",
+			}
+			for _, c := range columns {
+				// expression indexes have no column name
+				if c.Valid {
+					index.Columns = append(index.Columns, c.String)
+				} else {
+					index.Columns = append(index.Columns, "<expression>")
+				}
+			}
+			ret = append(ret, index)
+		}
+	}
+
+	return ret, nil
+}
+
+func (s *sqliteIntrospector) ForeignKeys(ctx context.Context, table string) ([]*ForeignKey, error) {
+	tables, err := s.tableNames(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []*ForeignKey{}
+	for _, t := range tables {
+		rows := []struct {
+			Id       int            `db:"id"`
+			Seq      int            `db:"seq"`
+			Table    string         `db:"table"`
+			From     string         `db:"from"`
+			To       sql.NullString `db:"to"`
+			OnUpdate string         `db:"on_update"`
+			OnDelete string         `db:"on_delete"`
+		}{}
+		err = s.db.SelectContext(ctx, &rows,
+			`SELECT id, seq, "table", "from", "to", on_update, on_delete
+			FROM pragma_foreign_key_list(?) ORDER BY id, seq`, t)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not list foreign keys of table %s", t)
+		}
+
+		// sqlite foreign keys are unnamed, and spread over one row per column
+		var fk *ForeignKey
+		lastId := -1
+		for _, r := range rows {
+			if r.Id != lastId {
+				fk = &ForeignKey{
+					Table:             t,
+					Name:              fmt.Sprintf("fk_%s_%d", t, r.Id),
+					Columns:           []string{},
+					ReferencedTable:   r.Table,
+					ReferencedColumns: []string{},
+					OnUpdate:          r.OnUpdate,
+					OnDelete:          r.OnDelete,
+				}
+				ret = append(ret, fk)
+				lastId = r.Id
+			}
+			fk.Columns = append(fk.Columns, r.From)
+			fk.ReferencedColumns = append(fk.ReferencedColumns, r.To.String)
+		}
+
+		// a foreign key without target columns references the primary key of the referenced table
+		for _, fk := range ret {
+			if fk.Table != t || len(fk.ReferencedColumns) == 0 || fk.ReferencedColumns[0] != "" {
+				continue
+			}
+			columns, err := s.Columns(ctx, fk.ReferencedTable)
+			if err != nil {
+				return nil, err
+			}
+			fk.ReferencedColumns = []string{}
+			for _, c := range columns {
+				if c.PrimaryKey {
+					fk.ReferencedColumns = append(fk.ReferencedColumns, c.Name)
+				}
+			}
+		}
+	}
+
+	return ret, nil
+}
+
+func (s *sqliteIntrospector) Views(ctx context.Context) ([]*View, error) {
+	views := []struct {
+		Name string         `db:"name"`
+		Sql  sql.NullString `db:"sql"`
+	}{}
+	err := s.db.SelectContext(ctx, &views, `SELECT name, sql FROM sqlite_master WHERE type = 'view' ORDER BY name`)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list views")
+	}
+
+	ret := []*View{}
+	for _, v := range views {
+		ret = append(ret, &View{Schema: "main", Name: v.Name, Definition: v.Sql.String})
+	}
+	return ret, nil
+}
@@ -0,0 +1,36 @@
+package shell
+
+import (
+	"context"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/types"
+)
+
+// ExpandedRowMiddleware turns each row into one row per column, in the style of
+// the expanded display of psql (`\x`). This makes wide rows readable in a terminal.
+type ExpandedRowMiddleware struct {
+	record int
+}
+
+var _ middlewares.RowMiddleware = (*ExpandedRowMiddleware)(nil)
+
+func NewExpandedRowMiddleware() *ExpandedRowMiddleware {
+	return &ExpandedRowMiddleware{}
+}
+
+func (e *ExpandedRowMiddleware) Process(ctx context.Context, row types.Row) ([]types.Row, error) {
+	e.record++
+	ret := []types.Row{}
+	for pair := row.Oldest(); pair != nil; pair = pair.Next() {
+		ret = append(ret, types.NewRow(
+			types.MRP("record", e.record),
+			types.MRP("column", pair.Key),
+			types.MRP("value", pair.Value),
+		))
+	}
+	return ret, nil
+}
+
+func (e *ExpandedRowMiddleware) Close(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,172 @@
+package shell
+
+import (
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/sqleton/pkg/statements"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// Buffer accumulates the lines entered into the shell until they form one or more
+// complete statements.
+type Buffer struct {
+	lines   []string
+	options []statements.SplitOption
+}
+
+func NewBuffer(options ...statements.SplitOption) *Buffer {
+	return &Buffer{
+		lines:   []string{},
+		options: options,
+	}
+}
+
+func (b *Buffer) IsEmpty() bool {
+	return strings.TrimSpace(b.String()) == ""
+}
+
+func (b *Buffer) String() string {
+	return strings.Join(b.lines, "\n")
+}
+
+func (b *Buffer) Reset() {
+	b.lines = []string{}
+}
+
+// AddLine appends a line to the buffer, and returns true if the buffer now ends with
+// a terminated statement, outside of any quoted string or comment.
+func (b *Buffer) AddLine(line string) bool {
+	b.lines = append(b.lines, line)
+	return !b.IsEmpty() && statements.IsComplete(b.String(), b.options...)
+}
+
+// Flush splits the buffer into its statements and resets it.
+// Unterminated statements are returned as well, which is what `\g` uses.
+func (b *Buffer) Flush() ([]string, error) {
+	defer b.Reset()
+	return statements.Split(b.String(), b.options...)
+}
+
+// MetaCommand is a backslash command such as `\d users` or `\o json`.
+type MetaCommand struct {
+	Name string
+	Args []string
+}
+
+// IsMetaCommand returns true if the line is a backslash meta command.
+func IsMetaCommand(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "\\")
+}
+
+// ParseMetaCommand parses a line such as `\run wp posts --status 'draft post'`.
+// Arguments are separated by whitespace and can be quoted with single or double quotes.
+// A trailing `;` is ignored, since it often gets typed out of habit.
+func ParseMetaCommand(line string) (*MetaCommand, error) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "\\") {
+		return nil, errors.Errorf("not a meta command: %s", line)
+	}
+	line = strings.TrimSuffix(line, ";")
+
+	args, err := SplitArgs(line[1:])
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, errors.New("empty meta command")
+	}
+
+	return &MetaCommand{
+		Name: args[0],
+		Args: args[1:],
+	}, nil
+}
+
+// SplitArgs splits a line into whitespace separated arguments, honoring single and double quotes
+// as well as backslash escapes outside of single quotes.
+func SplitArgs(line string) ([]string, error) {
+	ret := []string{}
+	current := strings.Builder{}
+	inArg := false
+	var quote rune
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' && i+1 < len(runes) {
+				i++
+				current.WriteRune(runes[i])
+			} else {
+				current.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			inArg = true
+		case c == '\\' && i+1 < len(runes):
+			i++
+			current.WriteRune(runes[i])
+			inArg = true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inArg {
+				ret = append(ret, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(c)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, errors.Errorf("unterminated %c quote", quote)
+	}
+	if inArg {
+		ret = append(ret, current.String())
+	}
+
+	return ret, nil
+}
+
+// CommandPath returns the full path of a repository command, as used on the command line,
+// for example `wp posts`.
+func CommandPath(command cmds.Command) []string {
+	description := command.Description()
+	return append(append([]string{}, description.Parents...), description.Name)
+}
+
+// FindCommand looks up the repository command whose path matches the start of args,
+// and returns it along with the remaining arguments.
+// The longest matching path wins, so that `mysql ps` is preferred over a `mysql` command.
+func FindCommand(commands []cmds.Command, args []string) (cmds.Command, []string, error) {
+	var ret cmds.Command
+	matchLength := 0
+
+	for _, command := range commands {
+		path := CommandPath(command)
+		if len(path) > len(args) || len(path) <= matchLength {
+			continue
+		}
+		matches := true
+		for i, p := range path {
+			if p != args[i] {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			ret = command
+			matchLength = len(path)
+		}
+	}
+
+	if ret == nil {
+		return nil, nil, errors.Errorf("command %s not found", strings.Join(args, " "))
+	}
+
+	return ret, args[matchLength:], nil
+}
@@ -0,0 +1,82 @@
+package shell
+
+import (
+	"context"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestBufferMultiLine(t *testing.T) {
+	b := NewBuffer()
+	assert.False(t, b.AddLine("SELECT *"))
+	assert.False(t, b.AddLine("FROM users WHERE name = 'a;"))
+	assert.True(t, b.AddLine("b';"))
+
+	statements, err := b.Flush()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"SELECT *\nFROM users WHERE name = 'a;\nb'"}, statements)
+	assert.True(t, b.IsEmpty())
+
+	assert.False(t, b.AddLine(""))
+	assert.True(t, b.IsEmpty())
+}
+
+func TestParseMetaCommand(t *testing.T) {
+	mc, err := ParseMetaCommand(`\d users;`)
+	require.NoError(t, err)
+	assert.Equal(t, "d", mc.Name)
+	assert.Equal(t, []string{"users"}, mc.Args)
+
+	mc, err = ParseMetaCommand(`  \run wp posts --status 'draft post' --title "a \"b\""`)
+	require.NoError(t, err)
+	assert.Equal(t, "run", mc.Name)
+	assert.Equal(t, []string{"wp", "posts", "--status", "draft post", "--title", `a "b"`}, mc.Args)
+
+	_, err = ParseMetaCommand(`\o 'json`)
+	assert.Error(t, err)
+	_, err = ParseMetaCommand(`\`)
+	assert.Error(t, err)
+}
+
+func TestFindCommand(t *testing.T) {
+	commands := []cmds.Command{
+		&cmds.CommandDescription{Name: "ps", Parents: []string{"mysql"}},
+		&cmds.CommandDescription{Name: "mysql"},
+		&cmds.CommandDescription{Name: "posts", Parents: []string{"wp"}},
+	}
+
+	c, rest, err := FindCommand(commands, []string{"mysql", "ps", "--full"})
+	require.NoError(t, err)
+	assert.Equal(t, "ps", c.Description().Name)
+	assert.Equal(t, []string{"--full"}, rest)
+
+	c, rest, err = FindCommand(commands, []string{"mysql", "other"})
+	require.NoError(t, err)
+	assert.Equal(t, "mysql", c.Description().Name)
+	assert.Equal(t, []string{"other"}, rest)
+
+	_, _, err = FindCommand(commands, []string{"wp"})
+	assert.Error(t, err)
+}
+
+func TestExpandedRowMiddleware(t *testing.T) {
+	mw := NewExpandedRowMiddleware()
+	ctx := context.Background()
+
+	rows, err := mw.Process(ctx, types.NewRow(types.MRP("id", 1), types.MRP("name", "foo")))
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	rows, err = mw.Process(ctx, types.NewRow(types.MRP("id", 2), types.MRP("name", "bar")))
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+
+	v, _ := rows[1].Get("record")
+	assert.Equal(t, 2, v)
+	v, _ = rows[1].Get("column")
+	assert.Equal(t, "name", v)
+	v, _ = rows[1].Get("value")
+	assert.Equal(t, "bar", v)
+}
@@ -0,0 +1,285 @@
+package statements
+
+import (
+	"github.com/pkg/errors"
+	"strings"
+	"unicode"
+)
+
+const DefaultDelimiter = ";"
+
+type splitOptions struct {
+	hashComments     bool
+	backslashEscapes bool
+}
+
+type SplitOption func(*splitOptions)
+
+// WithHashComments treats `#` as the start of a line comment, like MySQL does.
+func WithHashComments(hashComments bool) SplitOption {
+	return func(o *splitOptions) {
+		o.hashComments = hashComments
+	}
+}
+
+// WithBackslashEscapes treats `\` as an escape character in single quoted strings, like MySQL does.
+// PostgreSQL (with standard_conforming_strings) and SQLite treat it as a regular character.
+func WithBackslashEscapes(backslashEscapes bool) SplitOption {
+	return func(o *splitOptions) {
+		o.backslashEscapes = backslashEscapes
+	}
+}
+
+// DriverOptions returns the options matching the SQL dialect of the database driver.
+func DriverOptions(driverName string) []SplitOption {
+	mysql := driverName == "mysql"
+	return []SplitOption{
+		WithHashComments(mysql),
+		WithBackslashEscapes(mysql),
+	}
+}
+
+type splitter struct {
+	script    string
+	pos       int
+	line      int
+	delimiter string
+	options   splitOptions
+
+	current    strings.Builder
+	hasContent bool
+	statements []string
+}
+
+// Split splits a SQL script into individual statements.
+//
+// Statements are separated by `;`, unless the delimiter is changed with a MySQL style
+// `DELIMITER` line. Delimiters inside single quoted strings, double quoted and backtick quoted
+// identifiers, line and block comments, and PostgreSQL dollar quoted strings are ignored.
+//
+// The returned statements are trimmed and don't include their delimiter.
+// Statements that only consist of comments are dropped.
+func Split(script string, options ...SplitOption) ([]string, error) {
+	s := newSplitter(script, options...)
+	err := s.scan()
+	if err != nil {
+		return nil, err
+	}
+	s.endStatement()
+
+	return s.statements, nil
+}
+
+// IsComplete returns true if the script doesn't end in the middle of a statement,
+// quoted string or comment, meaning that all its statements have been terminated by a delimiter.
+// This is used to know when to stop reading lines in interactive mode.
+func IsComplete(script string, options ...SplitOption) bool {
+	s := newSplitter(script, options...)
+	err := s.scan()
+	if err != nil {
+		return false
+	}
+
+	return !s.hasContent
+}
+
+func newSplitter(script string, options ...SplitOption) *splitter {
+	s := &splitter{
+		script:    script,
+		line:      1,
+		delimiter: DefaultDelimiter,
+	}
+	for _, option := range options {
+		option(&s.options)
+	}
+	return s
+}
+
+// scan goes over the whole script and collects the terminated statements.
+// The content after the last delimiter is left in current.
+func (s *splitter) scan() error {
+	for s.pos < len(s.script) {
+		// DELIMITER directives are only recognized at the start of a new statement
+		if !s.hasContent && s.atLineStart() && s.parseDelimiterDirective() {
+			continue
+		}
+
+		if strings.HasPrefix(s.script[s.pos:], s.delimiter) {
+			s.pos += len(s.delimiter)
+			s.endStatement()
+			continue
+		}
+
+		c := s.script[s.pos]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			err := s.consumeQuoted(c)
+			if err != nil {
+				return err
+			}
+			s.hasContent = true
+		case c == '-' && strings.HasPrefix(s.script[s.pos:], "--"):
+			s.consumeLineComment()
+		case c == '#' && s.options.hashComments:
+			s.consumeLineComment()
+		case c == '/' && strings.HasPrefix(s.script[s.pos:], "/*"):
+			err := s.consumeBlockComment()
+			if err != nil {
+				return err
+			}
+		case c == '$':
+			tag, ok := s.dollarQuoteTag()
+			if ok {
+				err := s.consumeDollarQuoted(tag)
+				if err != nil {
+					return err
+				}
+			} else {
+				s.consume(1)
+			}
+			s.hasContent = true
+		default:
+			if !unicode.IsSpace(rune(c)) {
+				s.hasContent = true
+			}
+			s.consume(1)
+		}
+	}
+
+	return nil
+}
+
+func (s *splitter) consume(n int) {
+	chunk := s.script[s.pos : s.pos+n]
+	s.line += strings.Count(chunk, "\n")
+	s.current.WriteString(chunk)
+	s.pos += n
+}
+
+func (s *splitter) endStatement() {
+	statement := strings.TrimSpace(s.current.String())
+	if s.hasContent && statement != "" {
+		s.statements = append(s.statements, statement)
+	}
+	s.current.Reset()
+	s.hasContent = false
+}
+
+func (s *splitter) atLineStart() bool {
+	for i := s.pos - 1; i >= 0; i-- {
+		switch s.script[i] {
+		case '\n':
+			return true
+		case ' ', '\t', '\r':
+			continue
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// parseDelimiterDirective handles a `DELIMITER xx` line, which changes the delimiter
+// for the following statements.
+func (s *splitter) parseDelimiterDirective() bool {
+	rest := s.script[s.pos:]
+	const keyword = "DELIMITER"
+	if len(rest) <= len(keyword) || !strings.EqualFold(rest[:len(keyword)], keyword) {
+		return false
+	}
+	if rest[len(keyword)] != ' ' && rest[len(keyword)] != '\t' {
+		return false
+	}
+
+	line := rest
+	if idx := strings.IndexByte(rest, '\n'); idx >= 0 {
+		line = rest[:idx]
+	}
+	delimiter := strings.TrimSpace(line[len(keyword):])
+	if delimiter == "" {
+		return false
+	}
+
+	s.delimiter = delimiter
+	s.pos += len(line)
+	s.current.Reset()
+	return true
+}
+
+func (s *splitter) consumeQuoted(quote byte) error {
+	startLine := s.line
+	s.consume(1)
+	for s.pos < len(s.script) {
+		c := s.script[s.pos]
+		switch {
+		case c == '\\' && quote == '\'' && s.options.backslashEscapes && s.pos+1 < len(s.script):
+			s.consume(2)
+		case c == quote:
+			// doubled quotes are an escaped quote
+			if s.pos+1 < len(s.script) && s.script[s.pos+1] == quote {
+				s.consume(2)
+				continue
+			}
+			s.consume(1)
+			return nil
+		default:
+			s.consume(1)
+		}
+	}
+
+	return errors.Errorf("unterminated %c quoted string starting on line %d", quote, startLine)
+}
+
+func (s *splitter) consumeLineComment() {
+	idx := strings.IndexByte(s.script[s.pos:], '\n')
+	if idx < 0 {
+		s.consume(len(s.script) - s.pos)
+		return
+	}
+	s.consume(idx)
+}
+
+func (s *splitter) consumeBlockComment() error {
+	startLine := s.line
+	idx := strings.Index(s.script[s.pos+2:], "*/")
+	if idx < 0 {
+		return errors.Errorf("unterminated block comment starting on line %d", startLine)
+	}
+	s.consume(idx + 4)
+	return nil
+}
+
+// dollarQuoteTag checks if the current position starts a dollar quote ($$ or $tag$)
+// and returns the full opening tag.
+func (s *splitter) dollarQuoteTag() (string, bool) {
+	if s.pos > 0 {
+		prev := rune(s.script[s.pos-1])
+		if unicode.IsLetter(prev) || unicode.IsDigit(prev) || prev == '_' {
+			return "", false
+		}
+	}
+
+	rest := s.script[s.pos+1:]
+	end := strings.IndexByte(rest, '$')
+	if end < 0 {
+		return "", false
+	}
+	tag := rest[:end]
+	for i, r := range tag {
+		if !(unicode.IsLetter(r) || r == '_' || (i > 0 && unicode.IsDigit(r))) {
+			return "", false
+		}
+	}
+
+	return "$" + tag + "$", true
+}
+
+func (s *splitter) consumeDollarQuoted(tag string) error {
+	startLine := s.line
+	idx := strings.Index(s.script[s.pos+len(tag):], tag)
+	if idx < 0 {
+		return errors.Errorf("unterminated dollar quoted string %s starting on line %d", tag, startLine)
+	}
+	s.consume(len(tag) + idx + len(tag))
+	return nil
+}
@@ -0,0 +1,108 @@
+package statements
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSplitSimple(t *testing.T) {
+	statements, err := Split("SELECT 1; SELECT 2;\nSELECT 3")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"SELECT 1", "SELECT 2", "SELECT 3"}, statements)
+}
+
+func TestSplitQuotes(t *testing.T) {
+	statements, err := Split(`SELECT 'a;b', "c;d", ` + "`e;f`" + `; SELECT 'it''s; here'`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		`SELECT 'a;b', "c;d", ` + "`e;f`",
+		`SELECT 'it''s; here'`,
+	}, statements)
+}
+
+func TestSplitBackslashes(t *testing.T) {
+	// backslashes are regular characters in standard SQL strings
+	statements, err := Split(`SELECT 'C:\'; SELECT 1;`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{`SELECT 'C:\'`, "SELECT 1"}, statements)
+
+	statements, err = Split(`SELECT 'back\'slash;'; SELECT 1;`, DriverOptions("mysql")...)
+	require.NoError(t, err)
+	assert.Equal(t, []string{`SELECT 'back\'slash;'`, "SELECT 1"}, statements)
+}
+
+func TestSplitComments(t *testing.T) {
+	statements, err := Split(`
+-- first statement; with a comment
+SELECT 1; /* a block
+comment; */ SELECT 2;
+-- trailing comment;
+`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"-- first statement; with a comment\nSELECT 1",
+		"/* a block\ncomment; */ SELECT 2",
+	}, statements)
+}
+
+func TestSplitHashComments(t *testing.T) {
+	statements, err := Split("SELECT 1 # it's; a comment\n; SELECT 2", WithHashComments(true))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"SELECT 1 # it's; a comment", "SELECT 2"}, statements)
+}
+
+func TestSplitDollarQuoting(t *testing.T) {
+	statements, err := Split(`
+CREATE FUNCTION f() RETURNS int AS $$
+BEGIN
+  RETURN 1;
+END;
+$$ LANGUAGE plpgsql;
+SELECT $tag$;$tag$, $1;
+`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"CREATE FUNCTION f() RETURNS int AS $$\nBEGIN\n  RETURN 1;\nEND;\n$$ LANGUAGE plpgsql",
+		"SELECT $tag$;$tag$, $1",
+	}, statements)
+}
+
+func TestSplitDelimiter(t *testing.T) {
+	statements, err := Split(`
+DROP PROCEDURE IF EXISTS p;
+DELIMITER //
+CREATE PROCEDURE p()
+BEGIN
+  SELECT 1;
+END //
+DELIMITER ;
+CALL p();
+`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"DROP PROCEDURE IF EXISTS p",
+		"CREATE PROCEDURE p()\nBEGIN\n  SELECT 1;\nEND",
+		"CALL p()",
+	}, statements)
+}
+
+func TestSplitUnterminated(t *testing.T) {
+	_, err := Split("SELECT 1;\nSELECT 'foo")
+	assert.Error(t, err)
+	_, err = Split("SELECT 1 /* foo")
+	assert.Error(t, err)
+	_, err = Split("SELECT $$ foo")
+	assert.Error(t, err)
+}
+
+func TestIsComplete(t *testing.T) {
+	assert.True(t, IsComplete(""))
+	assert.True(t, IsComplete("SELECT 1;"))
+	assert.True(t, IsComplete("SELECT 1;\n-- trailing comment"))
+	assert.False(t, IsComplete("SELECT 1"))
+	assert.False(t, IsComplete("SELECT 1; SELECT"))
+	assert.False(t, IsComplete("SELECT 'a;"))
+	assert.False(t, IsComplete("SELECT 1 /* ; "))
+	assert.False(t, IsComplete("SELECT $$ ; "))
+}